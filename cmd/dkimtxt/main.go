@@ -0,0 +1,67 @@
+// dkimtxt 打印 DKIM 公钥对应的 DNS TXT 记录
+//
+// 用法:
+//
+//	dkimtxt -config local.yaml
+//	dkimtxt -domain example.com -selector mail -key dkim.pem
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"email-service/internal/config"
+	"email-service/internal/dkim"
+)
+
+func main() {
+	configFile := flag.String("config", "", "配置文件路径，打印其中所有 DKIM 密钥的记录")
+	domain := flag.String("domain", "", "发件域")
+	selector := flag.String("selector", "", "DKIM 选择器")
+	keyFile := flag.String("key", "", "PEM 私钥文件路径")
+	flag.Parse()
+
+	var keyConfigs []dkim.KeyConfig
+	if *configFile != "" {
+		cfg, err := config.LoadWithViper(*configFile)
+		if err != nil {
+			log.Fatalf("FATAL: Could not load config from file %s: %v", *configFile, err)
+		}
+		keyConfigs = cfg.DKIM.Keys
+	} else {
+		if *domain == "" || *selector == "" || *keyFile == "" {
+			flag.Usage()
+			log.Fatal("FATAL: -config or -domain, -selector and -key are required")
+		}
+		keyConfigs = []dkim.KeyConfig{{Domain: *domain, Selector: *selector, PrivateKeyFile: *keyFile}}
+	}
+
+	for _, kc := range keyConfigs {
+		key, err := dkim.LoadKey(kc)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		record, err := key.DNSRecord()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		fmt.Printf("%s. IN TXT %s\n", key.RecordName(), quoteTXT(record))
+	}
+}
+
+// quoteTXT 按 DNS 单个字符串 255 字节的限制拆分并加引号
+func quoteTXT(record string) string {
+	const limit = 255
+	var parts []string
+	for len(record) > limit {
+		parts = append(parts, `"`+record[:limit]+`"`)
+		record = record[limit:]
+	}
+	parts = append(parts, `"`+record+`"`)
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "( " + strings.Join(parts, " ") + " )"
+}
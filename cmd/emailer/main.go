@@ -1,13 +1,35 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"log"
+	"strings"
+	"time"
 
 	"email-service/internal/api"
+	"email-service/internal/attachment"
+	"email-service/internal/bounce"
+	"email-service/internal/campaign"
 	"email-service/internal/config"
+	"email-service/internal/digest"
+	"email-service/internal/dkim"
+	"email-service/internal/events"
+	"email-service/internal/feedback"
+	"email-service/internal/idempotency"
 	"email-service/internal/mailer"
+	"email-service/internal/outbox"
+	"email-service/internal/pgp"
 	"email-service/internal/queue"
+	"email-service/internal/quiethours"
+	"email-service/internal/schedule"
+	"email-service/internal/smime"
+	"email-service/internal/smtpd"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+	"email-service/internal/throttle"
+	"email-service/internal/unsubscribe"
+	"email-service/internal/verp"
 
 	"gopkg.in/gomail.v2"
 )
@@ -48,13 +70,253 @@ func main() {
 	}
 	log.Printf("Job queue created: type=%s", cfg.Queue.Type)
 
+	// 创建附件存储并启动过期附件清理
+	// 多个实例共同消费的队列中任务可能由其他实例发送，本地目录中的附件对其不可见
+	if cfg.Attachment.Type != attachment.TypeRedis && cfg.Queue.Type.Distributed() {
+		log.Fatalf("FATAL: Attachment store type %q is local to this instance and cannot be used with the %s queue, use a redis attachment store", cfg.Attachment.Type, cfg.Queue.Type)
+	}
+	attachmentStore, err := attachment.NewStore(cfg.Attachment)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to create attachment store: %v", err)
+	}
+	go attachment.RunGC(context.Background(), attachmentStore, cfg.Attachment.GCInterval, func(err error) {
+		log.Printf("ERROR: Attachment cleanup failed: %v", err)
+	})
+
+	// 创建任务状态存储，SQL 队列直接使用任务表记录状态
+	var statusStore status.Store
+	if sqlQueue, ok := jobQueue.(*queue.SQLQueue); ok {
+		sqlQueue.SetStatusRetention(cfg.Status.Retention)
+		go sqlQueue.RunPrune(context.Background(), time.Hour)
+		statusStore = sqlQueue
+	} else {
+		memoryStore := status.NewMemoryStore(cfg.Status)
+		go memoryStore.RunPrune(context.Background(), time.Hour)
+		statusStore = memoryStore
+	}
+
 	// 创建调度器
 	dispatcher := mailer.NewDispatcher(dialer, cfg.MaxWorkers, jobQueue)
+
+	// 创建批量发送活动服务，活动任务的状态变化同步到活动进度
+	var campaignService *campaign.Service
+	if cfg.Campaign.Enabled {
+		campaignService, err = campaign.New(cfg.Campaign, dispatcher)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create campaign service: %v", err)
+		}
+		campaignService.SetStatusStore(statusStore)
+		statusStore = campaignService.TrackStatuses(statusStore)
+		dispatcher.SetCampaigns(campaignService)
+	}
+
+	dispatcher.SetAttachmentStore(attachmentStore)
+	dispatcher.SetStatusStore(statusStore)
+	if cfg.DKIM.Enabled {
+		signer, err := dkim.NewSigner(cfg.DKIM)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to load DKIM keys: %v", err)
+		}
+		dispatcher.SetDKIMSigner(signer)
+		log.Printf("DKIM signing enabled: keys=%d", len(cfg.DKIM.Keys))
+	}
+	var smimeService *smime.Service
+	if cfg.SMIME.Enabled() {
+		smimeService, err = smime.New(cfg.SMIME)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to load S/MIME certificates: %v", err)
+		}
+		dispatcher.SetSMIME(smimeService)
+		log.Println("S/MIME support enabled")
+	}
+
+	// 创建 PGP 公钥登记表
+	pgpKeyStore, err := pgp.NewFileKeyStore(cfg.PGP.KeyStoreDir)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to create PGP key store: %v", err)
+	}
+	pgpService, err := pgp.New(cfg.PGP, pgpKeyStore)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to initialize PGP: %v", err)
+	}
+	dispatcher.SetPGP(pgpService)
+
+	// 创建屏蔽名单和退订服务
+	suppressionStore, err := suppression.NewStore(cfg.Suppression)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to create suppression store: %v", err)
+	}
+	if cfg.Suppression.AutoSuppressHardBounces {
+		dispatcher.SetSuppressionStore(suppressionStore)
+		log.Println("Hard-bounced addresses will be suppressed automatically")
+	}
+	if cfg.Unsubscribe.Enabled {
+		unsubscribeService, err := unsubscribe.New(cfg.Unsubscribe)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to initialize unsubscribe: %v", err)
+		}
+		dispatcher.SetUnsubscribe(unsubscribeService)
+		api.SetUnsubscribe(unsubscribeService)
+		log.Printf("List-Unsubscribe enabled: base_url=%s", cfg.Unsubscribe.BaseURL)
+	}
+	// 创建 VERP 编码器，未配置域名时使用发件地址的域名
+	var verpEncoder *verp.Encoder
+	if cfg.VERP.Enabled {
+		if cfg.VERP.Domain == "" {
+			if at := strings.LastIndex(cfg.SMTPUser, "@"); at >= 0 {
+				cfg.VERP.Domain = cfg.SMTPUser[at+1:]
+			}
+		}
+		verpEncoder, err = verp.New(cfg.VERP)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to initialize VERP: %v", err)
+		}
+		dispatcher.SetVERP(verpEncoder)
+		log.Printf("VERP enabled: envelope sender %s", verpEncoder.Address("<job_id>"))
+	}
+
+	// 创建免打扰服务，非紧急邮件推迟到收件人当地的投递时段
+	if cfg.QuietHours.Enabled {
+		quietHours, err := quiethours.New(cfg.QuietHours)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create quiet hours service: %v", err)
+		}
+		dispatcher.SetQuietHours(quietHours)
+		api.SetQuietHours(quietHours)
+		log.Printf("Quiet hours enabled: windows=%d bypass=%v", len(cfg.QuietHours.Windows), cfg.QuietHours.Bypass)
+	}
+
+	// 创建幂等记录存储，用于识别调用方重试的发送请求
+	idempotencyStore, err := idempotency.NewStore(cfg.Idempotency)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to create idempotency store: %v", err)
+	}
+	if memoryStore, ok := idempotencyStore.(*idempotency.MemoryStore); ok {
+		go memoryStore.RunPrune(context.Background(), time.Hour)
+	}
+
+	// 创建限流引擎，过滤重复内容并限制收件人的接收频率
+	throttleEngine, err := throttle.New(cfg.Throttle)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to create throttle engine: %v", err)
+	}
+	if memoryStore, ok := throttleEngine.Store().(*throttle.MemoryStore); ok {
+		go memoryStore.RunPrune(context.Background(), 10*time.Minute)
+	}
+	if throttleEngine.Enabled() {
+		log.Printf("Throttle rules enabled: duplicates=%t caps=%d", cfg.Throttle.Duplicates.Enabled, len(cfg.Throttle.Caps))
+	}
+
+	// 创建投递事件发送器
+	eventEmitter := events.NewEmitter(cfg.Events)
+
+	// 启动退信邮箱轮询
+	if cfg.Bounce.Enabled {
+		mailbox, err := bounce.NewMailbox(cfg.Bounce)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create bounce mailbox: %v", err)
+		}
+		bounceProcessor := bounce.NewProcessor(mailbox, statusStore)
+		bounceProcessor.SetSuppressionStore(suppressionStore)
+		bounceProcessor.SetEventEmitter(eventEmitter)
+		if verpEncoder != nil {
+			bounceProcessor.SetReturnPathDecoder(verpEncoder.Decode)
+		}
+		go bounceProcessor.Run(context.Background(), cfg.Bounce.Interval)
+		log.Printf("Bounce processing enabled: protocol=%s addr=%s", cfg.Bounce.Protocol, cfg.Bounce.Addr)
+	}
+
 	// 启动调度器
 	dispatcher.Run()
 
+	// 启动发件箱中继，将业务系统事务中写入的任务转发到队列
+	if cfg.Outbox.Enabled {
+		relay, err := outbox.NewRelay(cfg.Outbox, dispatcher)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create outbox relay: %v", err)
+		}
+		relay.SetStatusStore(statusStore)
+		go relay.Run(context.Background())
+		log.Printf("Outbox relay enabled: driver=%s table=%s", cfg.Outbox.Driver, cfg.Outbox.Table)
+	}
+
+	// 启动摘要服务，按窗口合并发送摘要模式的通知
+	if cfg.Digest.Enabled {
+		digestService, err := digest.New(cfg.Digest, dispatcher)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create digest service: %v", err)
+		}
+		go digestService.Run(context.Background())
+		api.SetDigest(digestService)
+		log.Printf("Digest mode enabled: store=%s window=%s max_items=%d", cfg.Digest.Store, cfg.Digest.Window, cfg.Digest.MaxItems)
+	}
+
+	// 启动定时任务调度器，多实例时只有主节点触发到期的定时任务
+	if cfg.Schedule.Enabled {
+		scheduler, err := schedule.New(cfg.Schedule, dispatcher)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create scheduler: %v", err)
+		}
+		scheduler.SetSuppressionStore(suppressionStore)
+		go scheduler.Run(context.Background())
+		api.SetScheduler(scheduler)
+		log.Printf("Schedules enabled: store=%s", cfg.Schedule.Store)
+	}
+
+	// 启动批量发送活动的后台入队，多实例时只有主节点入队
+	if campaignService != nil {
+		campaignService.SetSuppressionStore(suppressionStore)
+		go campaignService.Run(context.Background())
+		api.SetCampaigns(campaignService)
+		log.Printf("Campaigns enabled: store=%s batch_size=%d", cfg.Campaign.Store, cfg.Campaign.BatchSize)
+	}
+
+	// 创建投诉报告处理器，按配置轮询投诉邮箱
+	feedbackProcessor := feedback.NewProcessor(statusStore, suppressionStore)
+	feedbackProcessor.SetEventEmitter(eventEmitter)
+	if verpEncoder != nil {
+		feedbackProcessor.SetReturnPathDecoder(verpEncoder.Decode)
+	}
+	if cfg.Feedback.Poll.Enabled {
+		mailbox, err := bounce.NewMailbox(cfg.Feedback.Poll)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create feedback mailbox: %v", err)
+		}
+		go feedbackProcessor.Run(context.Background(), mailbox, cfg.Feedback.Poll.Interval)
+		log.Printf("Feedback report polling enabled: protocol=%s addr=%s", cfg.Feedback.Poll.Protocol, cfg.Feedback.Poll.Addr)
+	}
+
+	// 启动 SMTP 提交服务
+	if cfg.SMTPServer.Enabled {
+		smtpServer, err := smtpd.NewServer(cfg.SMTPServer, dispatcher)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create SMTP server: %v", err)
+		}
+		smtpServer.SetSuppressionStore(suppressionStore)
+		smtpServer.SetAttachmentStore(attachmentStore)
+		go func() {
+			if err := smtpServer.ListenAndServe(); err != nil {
+				log.Printf("ERROR: SMTP server stopped: %v", err)
+			}
+		}()
+	}
+
+	// 管理接口令牌
+	api.SetAdminToken(cfg.AdminToken)
+	if cfg.AdminToken == "" {
+		log.Printf("WARNING: Admin token not configured, admin API endpoints are disabled")
+	}
+
 	// 设置全局调度器
 	api.SetDispatcher(dispatcher)
+	api.SetAttachmentStore(attachmentStore)
+	api.SetPGP(pgpService)
+	api.SetSMIME(smimeService)
+	api.SetStatusStore(statusStore)
+	api.SetSuppressionStore(suppressionStore)
+	api.SetFeedbackProcessor(feedbackProcessor)
+	api.SetIdempotencyStore(idempotencyStore)
+	api.SetThrottle(throttleEngine)
 
 	// 启动 API 服务
 	api.RunGinServer(cfg.ServerPort)
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"email-service/internal/attachment"
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadAttachmentHandler 以 multipart/form-data 上传附件，返回附件ID
+// 表单字段: file 为附件内容，ttl 为可选有效期（如 "2h" 或秒数）
+func UploadAttachmentHandler(c *gin.Context) {
+	apiLogger := logger.GetDefault().WithComponent("api")
+
+	if GlobalAttachmentStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Attachment store not configured"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field"})
+		return
+	}
+
+	ttl, err := parseTTL(c.PostForm("ttl"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	meta, err := GlobalAttachmentStore.Save(c.Request.Context(), fileHeader.Filename,
+		fileHeader.Header.Get("Content-Type"), file, ttl)
+	if err != nil {
+		if errors.Is(err, attachment.ErrTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment too large"})
+			return
+		}
+		apiLogger.Error("Failed to save attachment", "filename", fileHeader.Filename, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	apiLogger.Info("Attachment uploaded",
+		"id", meta.ID,
+		"filename", meta.Filename,
+		"size", meta.Size,
+		"expires_at", meta.ExpiresAt,
+		"remote_addr", c.ClientIP())
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         meta.ID,
+		"filename":   meta.Filename,
+		"size":       meta.Size,
+		"expires_at": meta.ExpiresAt,
+	})
+}
+
+// parseTTL 解析有效期，支持 Go duration 格式或秒数
+func parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// validateAttachments 检查请求中通过ID引用的附件是否存在且未过期
+func validateAttachments(ctx context.Context, attachments []jobqueue.Attachment) error {
+	for _, att := range attachments {
+		if att.ID == "" {
+			continue
+		}
+		if GlobalAttachmentStore == nil {
+			return errors.New("attachment store not configured")
+		}
+		if _, err := GlobalAttachmentStore.Stat(ctx, att.ID); err != nil {
+			return fmt.Errorf("attachment %s: %w", att.ID, err)
+		}
+	}
+	return nil
+}
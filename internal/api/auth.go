@@ -0,0 +1,33 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GlobalAdminToken 管理接口使用的 Bearer 令牌，为空时管理接口不可用
+var GlobalAdminToken string
+
+// SetAdminToken 设置管理接口使用的 Bearer 令牌
+func SetAdminToken(token string) {
+	GlobalAdminToken = token
+}
+
+// RequireAdminToken 校验请求携带的管理令牌
+// 管理接口与公开接口共用监听端口，未配置令牌时拒绝全部管理请求
+func RequireAdminToken(c *gin.Context) {
+	if GlobalAdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin API disabled: no admin token configured"})
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(GlobalAdminToken)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing admin token"})
+		return
+	}
+	c.Next()
+}
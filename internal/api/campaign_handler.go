@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"email-service/internal/campaign"
+	"email-service/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCampaignHandler 以 multipart/form-data 上传收件人列表并创建活动，返回 201 后由后台入队
+// 表单字段: file 为 CSV 或 NDJSON 收件人列表，subject、template_id、category、priority、name 同发送接口，
+// template_data 为所有收件人共用的 JSON 模板数据，send_at 为 RFC 3339 格式的开始时间，format 可指定列表格式
+func CreateCampaignHandler(c *gin.Context) {
+	apiLogger := logger.GetDefault().WithComponent("api")
+
+	if GlobalCampaigns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Campaigns not configured"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, GlobalCampaigns.MaxUploadSize())
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Recipient list too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field"})
+		return
+	}
+
+	camp := &campaign.Campaign{
+		Name:       c.PostForm("name"),
+		Subject:    c.PostForm("subject"),
+		Category:   c.PostForm("category"),
+		Priority:   c.PostForm("priority"),
+		TemplateID: c.PostForm("template_id"),
+	}
+	if data := c.PostForm("template_data"); data != "" {
+		if err := json.Unmarshal([]byte(data), &camp.TemplateData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "template_data must be a JSON object"})
+			return
+		}
+	}
+	if sendAt := c.PostForm("send_at"); sendAt != "" {
+		if camp.SendAt, err = time.Parse(time.RFC3339, sendAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "send_at must be an RFC 3339 time"})
+			return
+		}
+	}
+
+	if err := camp.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	list, err := campaign.ParseRecipients(file, c.PostForm("format"), GlobalCampaigns.MaxRows())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := GlobalCampaigns.Create(c.Request.Context(), camp, list)
+	if err != nil {
+		respondCampaignError(c, err)
+		return
+	}
+
+	apiLogger.Info("Campaign created",
+		"campaign_id", created.ID,
+		"filename", fileHeader.Filename,
+		"total", created.Total,
+		"duplicates", created.Duplicates,
+		"send_at", created.SendAt,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListCampaignsHandler 列出全部活动及其进度
+func ListCampaignsHandler(c *gin.Context) {
+	if GlobalCampaigns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Campaigns not configured"})
+		return
+	}
+
+	campaigns, err := GlobalCampaigns.List(c.Request.Context())
+	if err != nil {
+		respondCampaignError(c, err)
+		return
+	}
+	if campaigns == nil {
+		campaigns = []*campaign.Campaign{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(campaigns), "campaigns": campaigns})
+}
+
+// GetCampaignHandler 返回活动及其进度
+func GetCampaignHandler(c *gin.Context) {
+	if GlobalCampaigns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Campaigns not configured"})
+		return
+	}
+
+	camp, err := GlobalCampaigns.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondCampaignError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, camp)
+}
+
+// PauseCampaignHandler 暂停活动
+func PauseCampaignHandler(c *gin.Context) {
+	changeCampaignState(c, "paused", func(id string) (*campaign.Campaign, error) {
+		return GlobalCampaigns.Pause(c.Request.Context(), id)
+	})
+}
+
+// ResumeCampaignHandler 恢复暂停的活动
+func ResumeCampaignHandler(c *gin.Context) {
+	changeCampaignState(c, "resumed", func(id string) (*campaign.Campaign, error) {
+		return GlobalCampaigns.Resume(c.Request.Context(), id)
+	})
+}
+
+// CancelCampaignHandler 取消活动
+func CancelCampaignHandler(c *gin.Context) {
+	changeCampaignState(c, "cancelled", func(id string) (*campaign.Campaign, error) {
+		return GlobalCampaigns.Cancel(c.Request.Context(), id)
+	})
+}
+
+// changeCampaignState 执行暂停、恢复或取消操作
+func changeCampaignState(c *gin.Context, action string, fn func(id string) (*campaign.Campaign, error)) {
+	if GlobalCampaigns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Campaigns not configured"})
+		return
+	}
+
+	camp, err := fn(c.Param("id"))
+	if err != nil {
+		respondCampaignError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Campaign "+action,
+		"campaign_id", camp.ID,
+		"state", camp.State,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusOK, camp)
+}
+
+// respondCampaignError 将活动存储错误转换为 HTTP 响应
+func respondCampaignError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, campaign.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, campaign.ErrInvalidState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.GetDefault().WithComponent("api").Error("Campaign store error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Campaign store error"})
+	}
+}
@@ -1,15 +1,89 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"email-service/internal/attachment"
+	"email-service/internal/digest"
+	"email-service/internal/idempotency"
 	"email-service/internal/logger"
 	"email-service/internal/mailer"
-	"time"
+	"email-service/internal/pgp"
+	"email-service/internal/suppression"
+	"email-service/internal/throttle"
+	"email-service/pkg/jobqueue"
 )
 
 // EmailService 邮件服务
 type EmailService struct {
-	dispatcher MailDispatcher
-	logger     *logger.Logger
+	dispatcher  MailDispatcher
+	attachments attachment.Store
+	pgp         *pgp.Service
+	suppression suppression.Store
+	idempotency idempotency.Store
+	throttle    *throttle.Engine
+	digest      *digest.Service
+	logger      *logger.Logger
+}
+
+// QueuedJob 已入队的任务
+type QueuedJob struct {
+	JobID     string `json:"job_id"`
+	Recipient string `json:"recipient"`
+}
+
+// SkippedRecipient 未入队的收件人及原因
+type SkippedRecipient struct {
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason"`
+	Detail    string `json:"detail,omitempty"`
+	JobID     string `json:"job_id,omitempty"` // 合并时为相同内容的已有任务
+}
+
+// DigestedRecipient 并入摘要的收件人
+type DigestedRecipient struct {
+	Recipient string    `json:"recipient"`
+	DigestKey string    `json:"digest_key"`
+	Items     int       `json:"items"`            // 摘要当前的条目数
+	SendAt    time.Time `json:"send_at"`          // 摘要的发送时间
+	JobID     string    `json:"job_id,omitempty"` // 达到条目上限立即发送时为摘要邮件的任务ID
+}
+
+// QueueResult 入队结果
+type QueueResult struct {
+	Queued   int                 // 成功入队的任务数
+	Jobs     []QueuedJob         // 成功入队的任务
+	Skipped  []SkippedRecipient  // 按策略跳过的收件人
+	Digested []DigestedRecipient // 并入摘要的收件人
+	Errors   []error             // 入队失败的错误
+}
+
+// 跳过收件人的原因
+const (
+	SkipReasonNoPGPKey   = "no_pgp_key"
+	SkipReasonSuppressed = "suppressed"
+)
+
+// MissingKeysError 收件人缺少 PGP 公钥且策略为 fail 时返回
+type MissingKeysError struct {
+	Recipients []string
+}
+
+func (e *MissingKeysError) Error() string {
+	return fmt.Sprintf("no pgp public key for recipients: %s", strings.Join(e.Recipients, ", "))
+}
+
+// DedupeConflictError 去重键被内容不同的请求复用，或使用相同去重键的请求仍在处理中
+type DedupeConflictError struct {
+	Recipients []string
+}
+
+func (e *DedupeConflictError) Error() string {
+	return fmt.Sprintf("dedupe_key conflicts for recipients: %s", strings.Join(e.Recipients, ", "))
 }
 
 // MailDispatcher 定义了邮件作业分发器的接口
@@ -25,28 +99,400 @@ func NewEmailService(dispatcher MailDispatcher) *EmailService {
 	}
 }
 
-func (s *EmailService) QueueEmailJobs(req SendEmailRequest) (int, []error) {
-	var errs []error
-	var successfulCount int
+// SetAttachmentStore 设置附件存储，用于维护已上传附件的引用计数
+func (s *EmailService) SetAttachmentStore(store attachment.Store) {
+	s.attachments = store
+}
+
+// SetPGP 设置 PGP 服务，用于在入队前检查收件人公钥
+func (s *EmailService) SetPGP(service *pgp.Service) {
+	s.pgp = service
+}
+
+// SetSuppressionStore 设置屏蔽名单，被屏蔽的收件人不会入队
+func (s *EmailService) SetSuppressionStore(store suppression.Store) {
+	s.suppression = store
+}
+
+// SetIdempotencyStore 设置幂等记录存储，用于按 dedupe_key 对收件人去重
+func (s *EmailService) SetIdempotencyStore(store idempotency.Store) {
+	s.idempotency = store
+}
+
+// SetThrottle 设置限流引擎，重复内容和超过频率上限的收件人不会入队
+func (s *EmailService) SetThrottle(engine *throttle.Engine) {
+	s.throttle = engine
+}
+
+// SetDigest 设置摘要服务，用于处理摘要模式的请求
+func (s *EmailService) SetDigest(service *digest.Service) {
+	s.digest = service
+}
+
+// QueueEmailJobs 为每个收件人创建邮件任务并推入队列
+// 请求整体被拒绝时返回错误，单个收件人的失败记录在结果中
+func (s *EmailService) QueueEmailJobs(req SendEmailRequest) (*QueueResult, error) {
+	result := &QueueResult{}
+
+	recipients, err := s.filterPGPRecipients(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Digest != nil && s.digest != nil {
+		s.queueDigest(req, recipients, result)
+		return result, nil
+	}
+
+	replayed, fingerprint, err := s.reserveDedupeKeys(req, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	handled := make(map[string]bool)
+	for _, email := range recipients {
+		dedupeKey := s.dedupeKey(req, email)
+		if dedupeKey != "" {
+			// 同一请求中仅大小写不同的重复收件人只入队一次
+			if handled[dedupeKey] {
+				continue
+			}
+			handled[dedupeKey] = true
+		}
+		if job, ok := replayed[dedupeKey]; ok {
+			s.logger.Info("Replaying deduplicated recipient", "recipient", email, "job_id", job.JobID)
+			job.Recipient = email
+			result.Queued++
+			result.Jobs = append(result.Jobs, job)
+			continue
+		}
+
+		if entry, err := s.checkSuppression(email, req.Category); err != nil {
+			s.logger.Error("Failed to check suppression list", "recipient", email, "error", err)
+			s.releaseDedupeKey(dedupeKey)
+			result.Errors = append(result.Errors, err)
+			continue
+		} else if entry != nil {
+			s.logger.Info("Skipping suppressed recipient",
+				"recipient", email, "category", req.Category, "reason", entry.Reason)
+			result.Skipped = append(result.Skipped, SkippedRecipient{
+				Recipient: email,
+				Reason:    SkipReasonSuppressed,
+				Detail:    string(entry.Reason),
+			})
+			s.releaseDedupeKey(dedupeKey)
+			continue
+		}
 
-	for _, email := range req.Recipients {
 		job := mailer.EmailJob{
+			ID:           jobqueue.NewJobID(),
 			To:           email,
 			Subject:      req.Subject,
+			Category:     req.Category,
+			Priority:     req.Priority,
 			MaxRetries:   3,
 			NextRetryAt:  time.Now(),
 			CreatedAt:    time.Now(),
 			Attachments:  req.Attachments,
 			TemplateID:   req.TemplateID,
 			TemplateData: req.TemplateData,
+			Sign:         req.Sign,
+			Encrypt:      req.Encrypt,
+			InReplyTo:    req.InReplyTo,
+			References:   req.References,
+			Timezone:     recipientTimezone(req, email),
+		}
+
+		if skipped, err := s.admit(job); err != nil {
+			s.logger.Error("Failed to apply throttle rules", "recipient", email, "error", err)
+			s.releaseDedupeKey(dedupeKey)
+			result.Errors = append(result.Errors, err)
+			continue
+		} else if skipped != nil {
+			result.Skipped = append(result.Skipped, *skipped)
+			s.releaseDedupeKey(dedupeKey)
+			continue
+		}
+
+		if err := s.retainAttachments(job); err != nil {
+			s.logger.Error("Failed to retain attachments", "recipient", email, "error", err)
+			s.revoke(job)
+			s.releaseDedupeKey(dedupeKey)
+			result.Errors = append(result.Errors, err)
+			continue
 		}
 
 		if err := s.dispatcher.PushJob(job); err != nil {
 			s.logger.Error("Failed to push job to queue", "recipient", email, "error", err)
-			errs = append(errs, err)
+			s.releaseAttachments(job, len(job.Attachments))
+			s.revoke(job)
+			s.releaseDedupeKey(dedupeKey)
+			result.Errors = append(result.Errors, err)
 		} else {
-			successfulCount++
+			queued := QueuedJob{JobID: job.ID, Recipient: email}
+			result.Queued++
+			result.Jobs = append(result.Jobs, queued)
+			s.completeDedupeKey(dedupeKey, fingerprint, queued)
+		}
+	}
+	return result, nil
+}
+
+// queueDigest 将请求的模板数据作为一个条目并入每个收件人的摘要
+// 摘要邮件在窗口结束或条目达到上限时才生成，因此不经过限流规则
+func (s *EmailService) queueDigest(req SendEmailRequest, recipients []string, result *QueueResult) {
+	meta := digest.Meta{
+		Subject:    req.Subject,
+		TemplateID: req.TemplateID,
+		Category:   req.Category,
+		Priority:   req.Priority,
+		Sign:       req.Sign,
+		Encrypt:    req.Encrypt,
+		Window:     req.Digest.window,
+	}
+	item := req.TemplateData
+	if item == nil {
+		item = map[string]any{}
+	}
+
+	handled := make(map[string]bool)
+	for _, email := range recipients {
+		// 同一请求中仅大小写不同的重复收件人只加入一次
+		if handled[strings.ToLower(email)] {
+			continue
+		}
+		handled[strings.ToLower(email)] = true
+
+		if entry, err := s.checkSuppression(email, req.Category); err != nil {
+			s.logger.Error("Failed to check suppression list", "recipient", email, "error", err)
+			result.Errors = append(result.Errors, err)
+			continue
+		} else if entry != nil {
+			s.logger.Info("Skipping suppressed recipient",
+				"recipient", email, "category", req.Category, "reason", entry.Reason)
+			result.Skipped = append(result.Skipped, SkippedRecipient{
+				Recipient: email,
+				Reason:    SkipReasonSuppressed,
+				Detail:    string(entry.Reason),
+			})
+			continue
+		}
+
+		id := digest.ID{Key: req.Digest.Key, Recipient: email}
+		added, err := s.digest.Add(context.Background(), id, meta, item, req.Digest.MaxItems)
+		if err != nil {
+			s.logger.Error("Failed to add digest item", "recipient", email, "digest_key", req.Digest.Key, "error", err)
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Digested = append(result.Digested, DigestedRecipient{
+			Recipient: email,
+			DigestKey: req.Digest.Key,
+			Items:     added.Items,
+			SendAt:    added.DueAt,
+			JobID:     added.JobID,
+		})
+		if added.JobID != "" {
+			result.Queued++
+			result.Jobs = append(result.Jobs, QueuedJob{JobID: added.JobID, Recipient: email})
+		}
+	}
+}
+
+// filterPGPRecipients 对要求 PGP 加密的请求检查收件人公钥，按策略跳过或拒绝缺少公钥的收件人
+func (s *EmailService) filterPGPRecipients(req SendEmailRequest, result *QueueResult) ([]string, error) {
+	if req.Encrypt != jobqueue.SecurityPGP || s.pgp == nil {
+		return req.Recipients, nil
+	}
+
+	recipients := make([]string, 0, len(req.Recipients))
+	var missing []string
+	for _, email := range req.Recipients {
+		ok, err := s.pgp.HasKey(email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up pgp key for %s: %w", email, err)
+		}
+		if ok {
+			recipients = append(recipients, email)
+		} else {
+			missing = append(missing, email)
+		}
+	}
+	if len(missing) == 0 {
+		return recipients, nil
+	}
+
+	if s.pgp.MissingKeyPolicy() == pgp.PolicyFail {
+		return nil, &MissingKeysError{Recipients: missing}
+	}
+	for _, email := range missing {
+		s.logger.Warn("Skipping recipient without pgp key", "recipient", email)
+		result.Skipped = append(result.Skipped, SkippedRecipient{Recipient: email, Reason: SkipReasonNoPGPKey})
+	}
+	return recipients, nil
+}
+
+// recipientTimezone 返回收件人的时区，recipient_timezones 中的设置优先
+func recipientTimezone(req SendEmailRequest, email string) string {
+	for recipient, tz := range req.RecipientTimezones {
+		if strings.EqualFold(recipient, email) {
+			return tz
+		}
+	}
+	return req.Timezone
+}
+
+// dedupeKey 返回收件人的去重键，请求未设置 dedupe_key 时为空
+func (s *EmailService) dedupeKey(req SendEmailRequest, email string) string {
+	if req.DedupeKey == "" || s.idempotency == nil {
+		return ""
+	}
+	return "dedupe:" + req.DedupeKey + ":" + strings.ToLower(email)
+}
+
+// reserveDedupeKeys 为每个收件人占用去重键，返回窗口内已入队过的任务（按去重键索引）以及请求内容的摘要
+// 任一收件人的键被不同内容的请求使用或仍在处理中时，释放本次占用的键并返回 DedupeConflictError
+func (s *EmailService) reserveDedupeKeys(req SendEmailRequest, recipients []string) (map[string]QueuedJob, string, error) {
+	if req.DedupeKey == "" || s.idempotency == nil {
+		return nil, "", nil
+	}
+	// 收件人是键的一部分，摘要只覆盖其余字段
+	content := req
+	content.Recipients = nil
+	fingerprint, err := idempotency.Fingerprint(content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx := context.Background()
+	replayed := make(map[string]QueuedJob)
+	reserved := make(map[string]bool)
+	var conflicts []string
+	for _, email := range recipients {
+		key := s.dedupeKey(req, email)
+		if _, ok := replayed[key]; ok || reserved[key] {
+			continue
+		}
+		record, err := s.idempotency.Reserve(ctx, key, fingerprint)
+		if err != nil {
+			s.releaseDedupeKeys(reserved)
+			return nil, "", err
+		}
+		switch {
+		case record == nil:
+			reserved[key] = true
+		case record.Fingerprint != fingerprint || !record.Completed:
+			conflicts = append(conflicts, email)
+		default:
+			var job QueuedJob
+			if err := json.Unmarshal(record.Body, &job); err != nil {
+				s.releaseDedupeKeys(reserved)
+				return nil, "", fmt.Errorf("invalid dedupe record for %s: %w", email, err)
+			}
+			replayed[key] = job
+		}
+	}
+	if len(conflicts) > 0 {
+		s.releaseDedupeKeys(reserved)
+		return nil, "", &DedupeConflictError{Recipients: conflicts}
+	}
+	return replayed, fingerprint, nil
+}
+
+// completeDedupeKey 保存收件人的任务，窗口内的重复请求直接返回该任务
+func (s *EmailService) completeDedupeKey(key, fingerprint string, job QueuedJob) {
+	if key == "" {
+		return
+	}
+	body, err := json.Marshal(job)
+	if err == nil {
+		err = s.idempotency.Complete(context.Background(), key, idempotency.Record{Fingerprint: fingerprint, Body: body})
+	}
+	if err != nil {
+		s.logger.Warn("Failed to save dedupe record", "recipient", job.Recipient, "job_id", job.JobID, "error", err)
+	}
+}
+
+// releaseDedupeKey 释放没有入队的收件人的去重键，使重试可以再次入队
+func (s *EmailService) releaseDedupeKey(key string) {
+	if key == "" {
+		return
+	}
+	if err := s.idempotency.Release(context.Background(), key); err != nil {
+		s.logger.Warn("Failed to release dedupe key", "key", key, "error", err)
+	}
+}
+
+// releaseDedupeKeys 释放本次请求占用的全部去重键
+func (s *EmailService) releaseDedupeKeys(keys map[string]bool) {
+	for key := range keys {
+		s.releaseDedupeKey(key)
+	}
+}
+
+// admit 按限流规则检查任务，不能入队时返回跳过原因
+func (s *EmailService) admit(job mailer.EmailJob) (*SkippedRecipient, error) {
+	if s.throttle == nil || !s.throttle.Enabled() {
+		return nil, nil
+	}
+	decision, err := s.throttle.Admit(context.Background(), job)
+	if err != nil || decision == nil {
+		return nil, err
+	}
+	s.logger.Info("Skipping throttled recipient",
+		"recipient", job.To, "category", job.Category, "reason", decision.Reason, "detail", decision.Detail)
+	return &SkippedRecipient{
+		Recipient: job.To,
+		Reason:    decision.Reason,
+		Detail:    decision.Detail,
+		JobID:     decision.JobID,
+	}, nil
+}
+
+// revoke 撤销未能入队的任务在限流规则中的记录
+func (s *EmailService) revoke(job mailer.EmailJob) {
+	if s.throttle == nil || !s.throttle.Enabled() {
+		return
+	}
+	s.throttle.Revoke(context.Background(), job)
+}
+
+// checkSuppression 检查收件人在请求类别下是否被屏蔽
+func (s *EmailService) checkSuppression(email, category string) (*suppression.Entry, error) {
+	if s.suppression == nil {
+		return nil, nil
+	}
+	return s.suppression.Check(context.Background(), email, category)
+}
+
+// retainAttachments 为任务引用的每个已上传附件增加引用计数
+func (s *EmailService) retainAttachments(job mailer.EmailJob) error {
+	if s.attachments == nil {
+		return nil
+	}
+	for i, att := range job.Attachments {
+		if att.ID == "" {
+			continue
+		}
+		if err := s.attachments.Retain(context.Background(), att.ID); err != nil {
+			s.releaseAttachments(job, i)
+			return fmt.Errorf("attachment %s: %w", att.ID, err)
+		}
+	}
+	return nil
+}
+
+// releaseAttachments 释放任务前 n 个附件中已上传附件的引用
+func (s *EmailService) releaseAttachments(job mailer.EmailJob, n int) {
+	if s.attachments == nil {
+		return
+	}
+	for _, att := range job.Attachments[:n] {
+		if att.ID == "" {
+			continue
+		}
+		if err := s.attachments.Release(context.Background(), att.ID); err != nil {
+			s.logger.Warn("Failed to release attachment", "id", att.ID, "error", err)
 		}
 	}
-	return successfulCount, errs
 }
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"email-service/internal/feedback"
+	"email-service/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFeedbackReportSize 投诉报告的最大字节数
+const maxFeedbackReportSize = 10 << 20
+
+// FeedbackReportHandler 接收 ARF 投诉报告，请求体为完整的报告邮件原文 (message/rfc822)
+func FeedbackReportHandler(c *gin.Context) {
+	apiLogger := logger.GetDefault().WithComponent("api")
+
+	if GlobalFeedback == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Feedback processing not configured"})
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxFeedbackReportSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Report too large"})
+		return
+	}
+
+	result, err := GlobalFeedback.Process(c.Request.Context(), raw)
+	if err != nil {
+		if errors.Is(err, feedback.ErrNotARF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		apiLogger.Error("Failed to process feedback report", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process report"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, result)
+}
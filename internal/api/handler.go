@@ -3,13 +3,16 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"time"
 
+	"email-service/internal/digest"
 	"email-service/internal/logger"
 	"email-service/internal/mailer"
+	"email-service/internal/status"
 	"email-service/pkg/jobqueue"
 
 	"github.com/gin-gonic/gin"
@@ -47,6 +50,7 @@ func HandleSendNotification(w http.ResponseWriter, r *http.Request) {
 	// 通过全局调度器推送任务到队列
 	for _, email := range payload.Recipients {
 		job := mailer.EmailJob{
+			ID:          jobqueue.NewJobID(),
 			To:          email,
 			Subject:     payload.Subject,
 			Body:        payload.Body,
@@ -89,9 +93,29 @@ func HandleSendNotification(w http.ResponseWriter, r *http.Request) {
 type SendEmailRequest struct {
 	Subject      string                `json:"subject" binding:"required"`
 	Recipients   []string              `json:"recipients" binding:"required"`
+	Category     string                `json:"category"` // 邮件类别，用于退订和屏蔽
+	Priority     string                `json:"priority"` // 优先级: high, normal, bulk
 	TemplateID   string                `json:"template_id"`
 	TemplateData map[string]any        `json:"template_data"`
 	Attachments  []jobqueue.Attachment `json:"attachments"`
+	Sign         string                `json:"sign"`        // 签名方式: smime, pgp
+	Encrypt      string                `json:"encrypt"`     // 加密方式: smime, pgp
+	InReplyTo    string                `json:"in_reply_to"` // 回复的 Message-ID，用于邮件会话
+	References   []string              `json:"references"`  // 会话中此前邮件的 Message-ID
+	DedupeKey    string                `json:"dedupe_key"`  // 按收件人去重的键，窗口内重复的收件人返回原任务
+	Digest       *DigestOptions        `json:"digest"`      // 设置后不立即发送，模板数据作为一个条目并入收件人的摘要
+	Timezone     string                `json:"timezone"`    // 收件人所在的 IANA 时区，用于计算投递时段，为空时使用收件人档案
+	// RecipientTimezones 按收件人指定时区，覆盖 timezone，地址不区分大小写
+	RecipientTimezones map[string]string `json:"recipient_timezones"`
+}
+
+// DigestOptions 摘要模式选项
+type DigestOptions struct {
+	Key      string `json:"key"`       // 摘要键，相同键的通知合并为一封邮件
+	Window   string `json:"window"`    // 窗口长度，如 30m，为空时使用配置的窗口
+	MaxItems int    `json:"max_items"` // 条目上限，达到后立即发送，为 0 时使用配置的上限
+
+	window time.Duration // 校验时解析出的窗口长度
 }
 
 // SendEmailHandler 基于 Gin 的邮件发送接口
@@ -105,30 +129,210 @@ func SendEmailHandler(c *gin.Context) {
 		return
 	}
 
+	if err := validateSecurity(req.Sign, req.Encrypt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !jobqueue.ValidPriority(req.Priority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported priority: %s", req.Priority)})
+		return
+	}
+
+	if err := validateDigest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateTimezones(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateAttachments(c.Request.Context(), req.Attachments); err != nil {
+		apiLogger.Warn("Invalid attachment reference", "error", err, "remote_addr", c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 重复请求直接返回首次请求的响应
+	idem, ok := beginIdempotentRequest(c, req)
+	if !ok {
+		return
+	}
+
 	emailService := NewEmailService(GlobalDispatcher)
-	successfulCount, errs := emailService.QueueEmailJobs(req)
+	emailService.SetAttachmentStore(GlobalAttachmentStore)
+	emailService.SetPGP(GlobalPGP)
+	emailService.SetSuppressionStore(GlobalSuppressionStore)
+	emailService.SetIdempotencyStore(GlobalIdempotencyStore)
+	emailService.SetThrottle(GlobalThrottle)
+	emailService.SetDigest(GlobalDigest)
+	result, err := emailService.QueueEmailJobs(req)
+	if err != nil {
+		var missingKeys *MissingKeysError
+		if errors.As(err, &missingKeys) {
+			apiLogger.Warn("Rejected request with recipients lacking pgp keys",
+				"recipients", missingKeys.Recipients, "remote_addr", c.ClientIP())
+			idem.respond(c, http.StatusUnprocessableEntity, gin.H{
+				"error":        "Some recipients have no registered PGP public key",
+				"missing_keys": missingKeys.Recipients,
+			}, false)
+			return
+		}
+		var conflict *DedupeConflictError
+		if errors.As(err, &conflict) {
+			apiLogger.Warn("Rejected request with conflicting dedupe_key",
+				"dedupe_key", req.DedupeKey, "recipients", conflict.Recipients, "remote_addr", c.ClientIP())
+			idem.respond(c, http.StatusConflict, gin.H{
+				"error":      "dedupe_key was already used with a different request, or that request is still being processed",
+				"recipients": conflict.Recipients,
+			}, false)
+			return
+		}
+		apiLogger.Error("Failed to queue email jobs", "error", err, "remote_addr", c.ClientIP())
+		idem.respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to queue email jobs"}, false)
+		return
+	}
 
 	apiLogger.Info("Email jobs queued successfully (gin)",
 		"job_count", len(req.Recipients),
-		"successful_count", successfulCount,
-		"failed_count", len(errs),
+		"successful_count", result.Queued,
+		"skipped_count", len(result.Skipped),
+		"digested_count", len(result.Digested),
+		"failed_count", len(result.Errors),
 		"subject", req.Subject,
 		"remote_addr", c.ClientIP())
 
-	if len(errs) > 0 {
-		apiLogger.Error("Failed to queue email jobs", "errors", errs, "remote_addr", c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if len(result.Errors) > 0 {
+		apiLogger.Error("Failed to queue email jobs", "errors", result.Errors, "remote_addr", c.ClientIP())
+		// 已有任务入队或并入摘要时保存响应，重试不会重复发送已处理的收件人
+		idem.respond(c, http.StatusInternalServerError, gin.H{
 			"message":          "Some jobs were accepted, but failures occurred.",
-			"successful_count": successfulCount,
-			"failed_count":     len(errs),
-		})
+			"successful_count": result.Queued,
+			"failed_count":     len(result.Errors),
+			"jobs":             result.Jobs,
+			"skipped":          result.Skipped,
+			"digested":         result.Digested,
+		}, result.Queued > 0 || len(result.Digested) > 0)
 		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{
+	resp := gin.H{
 		"message": "Jobs accepted for processing.",
-		"count":   len(req.Recipients),
-	})
+		"count":   result.Queued,
+		"jobs":    result.Jobs,
+	}
+	if len(result.Skipped) > 0 {
+		resp["skipped"] = result.Skipped
+	}
+	if len(result.Digested) > 0 {
+		resp["digested"] = result.Digested
+	}
+	idem.respond(c, http.StatusAccepted, resp, true)
+}
+
+// GetJobStatusHandler 查询任务状态
+func GetJobStatusHandler(c *gin.Context) {
+	if GlobalStatusStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Status store not configured"})
+		return
+	}
+
+	st, err := GlobalStatusStore.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, status.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		logger.GetDefault().WithComponent("api").Error("Failed to get job status", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job status"})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// validateSecurity 校验签名和加密方式，以及对应的服务是否已配置
+func validateSecurity(sign, encrypt string) error {
+	switch sign {
+	case "", jobqueue.SecuritySMIME, jobqueue.SecurityPGP:
+	default:
+		return fmt.Errorf("unsupported sign method: %s", sign)
+	}
+	switch encrypt {
+	case "", jobqueue.SecuritySMIME, jobqueue.SecurityPGP:
+	default:
+		return fmt.Errorf("unsupported encrypt method: %s", encrypt)
+	}
+	if sign != "" && encrypt != "" && sign != encrypt {
+		return fmt.Errorf("cannot combine %s signing with %s encryption", sign, encrypt)
+	}
+
+	// 未配置对应的服务时发送必然失败，在入队前拒绝
+	if sign == jobqueue.SecurityPGP || encrypt == jobqueue.SecurityPGP {
+		if GlobalPGP == nil {
+			return errors.New("pgp is not configured")
+		}
+		if sign == jobqueue.SecurityPGP && !GlobalPGP.CanSign() {
+			return errors.New("pgp signing key is not configured")
+		}
+	}
+	if sign == jobqueue.SecuritySMIME || encrypt == jobqueue.SecuritySMIME {
+		if GlobalSMIME == nil {
+			return errors.New("s/mime is not configured")
+		}
+		if sign == jobqueue.SecuritySMIME && !GlobalSMIME.CanSign() {
+			return errors.New("s/mime signing certificate is not configured")
+		}
+		if encrypt == jobqueue.SecuritySMIME && !GlobalSMIME.CanEncrypt() {
+			return errors.New("s/mime certificate store is not configured")
+		}
+	}
+	return nil
+}
+
+// validateDigest 校验摘要模式选项并保存解析出的窗口长度
+func validateDigest(req SendEmailRequest) error {
+	if req.Digest == nil {
+		return nil
+	}
+	if GlobalDigest == nil {
+		return errors.New("digest mode is not enabled")
+	}
+	if err := digest.ValidKey(req.Digest.Key); err != nil {
+		return err
+	}
+	if req.Digest.Window != "" {
+		window, err := time.ParseDuration(req.Digest.Window)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("invalid digest window: %s", req.Digest.Window)
+		}
+		req.Digest.window = window
+	}
+	if req.Digest.MaxItems < 0 {
+		return errors.New("digest max_items must not be negative")
+	}
+	// 附件引用计数和去重记录都以单个任务为单位，摘要的任务在发送时才生成
+	if len(req.Attachments) > 0 {
+		return errors.New("attachments cannot be used in digest mode")
+	}
+	if req.DedupeKey != "" {
+		return errors.New("dedupe_key cannot be used in digest mode")
+	}
+	return nil
+}
+
+// validateTimezones 校验请求中的收件人时区
+func validateTimezones(req SendEmailRequest) error {
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", req.Timezone)
+	}
+	for recipient, tz := range req.RecipientTimezones {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("invalid timezone for %s: %s", recipient, tz)
+		}
+	}
+	return nil
 }
 
 type PreviewTemplateRequest struct {
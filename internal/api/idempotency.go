@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"email-service/internal/idempotency"
+	"email-service/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader 请求的幂等键头部
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength 幂等键的最大长度
+const maxIdempotencyKeyLength = 255
+
+// idempotentRequest 已占用幂等键的请求
+type idempotentRequest struct {
+	store       idempotency.Store
+	key         string
+	fingerprint string
+	logger      *logger.Logger
+}
+
+// beginIdempotentRequest 按 Idempotency-Key 头部占用幂等键
+// 重复请求时直接写出首次请求的响应并返回 false；未带头部或未配置存储时返回 nil 和 true
+func beginIdempotentRequest(c *gin.Context, req SendEmailRequest) (*idempotentRequest, bool) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" || GlobalIdempotencyStore == nil {
+		return nil, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+		return nil, false
+	}
+
+	apiLogger := logger.GetDefault().WithComponent("api")
+	fingerprint, err := idempotency.Fingerprint(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return nil, false
+	}
+	record, err := GlobalIdempotencyStore.Reserve(c.Request.Context(), "request:"+key, fingerprint)
+	if err != nil {
+		apiLogger.Error("Failed to reserve idempotency key", "error", err, "remote_addr", c.ClientIP())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+		return nil, false
+	}
+
+	switch {
+	case record == nil:
+		return &idempotentRequest{
+			store:       GlobalIdempotencyStore,
+			key:         "request:" + key,
+			fingerprint: fingerprint,
+			logger:      apiLogger,
+		}, true
+	case record.Fingerprint != fingerprint:
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+	case !record.Completed:
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+	default:
+		apiLogger.Info("Replaying idempotent request", "remote_addr", c.ClientIP())
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", record.Body)
+	}
+	return nil, false
+}
+
+// respond 写出响应；keep 为 true 时保存响应供重复请求返回，否则释放幂等键使调用方可以重试
+func (r *idempotentRequest) respond(c *gin.Context, code int, body gin.H, keep bool) {
+	c.JSON(code, body)
+	if r == nil {
+		return
+	}
+
+	// 调用方超时断开时请求的 context 已取消，结果仍需保存
+	ctx := context.Background()
+	if !keep {
+		if err := r.store.Release(ctx, r.key); err != nil {
+			r.logger.Warn("Failed to release idempotency key", "error", err)
+		}
+		return
+	}
+	data, err := json.Marshal(body)
+	if err == nil {
+		err = r.store.Complete(ctx, r.key, idempotency.Record{
+			Fingerprint: r.fingerprint,
+			StatusCode:  code,
+			Body:        data,
+		})
+	}
+	if err != nil {
+		r.logger.Warn("Failed to save idempotent response", "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"email-service/internal/logger"
+	"email-service/internal/pgp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PutPGPKeyRequest 登记公钥请求
+type PutPGPKeyRequest struct {
+	PublicKey string `json:"public_key" binding:"required"` // ASCII-armored 公钥
+}
+
+// pgpKeyStore 返回公钥登记表，未配置 PGP 时返回 nil
+func pgpKeyStore() pgp.KeyStore {
+	if GlobalPGP == nil {
+		return nil
+	}
+	return GlobalPGP.KeyStore()
+}
+
+// ListPGPKeysHandler 列出已登记的公钥
+func ListPGPKeysHandler(c *gin.Context) {
+	store := pgpKeyStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PGP not configured"})
+		return
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		logger.GetDefault().WithComponent("api").Error("Failed to list pgp keys", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// GetPGPKeyHandler 返回地址对应的公钥
+func GetPGPKeyHandler(c *gin.Context) {
+	store := pgpKeyStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PGP not configured"})
+		return
+	}
+
+	entity, armored, err := store.Get(c.Param("address"))
+	if err != nil {
+		respondPGPKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"address":     pgp.NormalizeAddress(c.Param("address")),
+		"fingerprint": fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+		"public_key":  armored,
+	})
+}
+
+// PutPGPKeyHandler 登记或替换地址对应的公钥
+func PutPGPKeyHandler(c *gin.Context) {
+	apiLogger := logger.GetDefault().WithComponent("api")
+
+	store := pgpKeyStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PGP not configured"})
+		return
+	}
+
+	var req PutPGPKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	info, err := store.Put(c.Param("address"), req.PublicKey)
+	if err != nil {
+		respondPGPKeyError(c, err)
+		return
+	}
+
+	apiLogger.Info("PGP public key registered",
+		"address", info.Address,
+		"fingerprint", info.Fingerprint,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusOK, info)
+}
+
+// DeletePGPKeyHandler 删除地址对应的公钥
+func DeletePGPKeyHandler(c *gin.Context) {
+	store := pgpKeyStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PGP not configured"})
+		return
+	}
+
+	if err := store.Delete(c.Param("address")); err != nil {
+		respondPGPKeyError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("PGP public key deleted",
+		"address", pgp.NormalizeAddress(c.Param("address")),
+		"remote_addr", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// respondPGPKeyError 将公钥登记表错误转换为 HTTP 响应
+func respondPGPKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, pgp.ErrNoPublicKey):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, pgp.ErrInvalidKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.GetDefault().WithComponent("api").Error("PGP key store error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Key store error"})
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/quiethours"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PutRecipientProfileRequest 设置收件人档案请求
+type PutRecipientProfileRequest struct {
+	Timezone string `json:"timezone" binding:"required"` // IANA 时区，如 Asia/Shanghai
+}
+
+// GetRecipientProfileHandler 返回收件人档案
+func GetRecipientProfileHandler(c *gin.Context) {
+	if GlobalQuietHours == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recipient profiles not configured"})
+		return
+	}
+
+	profile, err := GlobalQuietHours.Profiles().Get(c.Request.Context(), c.Param("address"))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// PutRecipientProfileHandler 新增或覆盖收件人档案
+func PutRecipientProfileHandler(c *gin.Context) {
+	if GlobalQuietHours == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recipient profiles not configured"})
+		return
+	}
+
+	var req PutRecipientProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	address := c.Param("address")
+	if _, err := mail.ParseAddress(address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid address %q", address)})
+		return
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid timezone: %s", req.Timezone)})
+		return
+	}
+
+	profile := quiethours.Profile{
+		Address:   quiethours.NormalizeAddress(address),
+		Timezone:  req.Timezone,
+		UpdatedAt: time.Now(),
+	}
+	if err := GlobalQuietHours.Profiles().Put(c.Request.Context(), profile); err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Recipient profile saved",
+		"address", profile.Address,
+		"timezone", profile.Timezone,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteRecipientProfileHandler 删除收件人档案
+func DeleteRecipientProfileHandler(c *gin.Context) {
+	if GlobalQuietHours == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recipient profiles not configured"})
+		return
+	}
+
+	if err := GlobalQuietHours.Profiles().Delete(c.Request.Context(), c.Param("address")); err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Recipient profile deleted",
+		"address", quiethours.NormalizeAddress(c.Param("address")),
+		"remote_addr", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// respondProfileError 将收件人档案存储错误转换为 HTTP 响应
+func respondProfileError(c *gin.Context, err error) {
+	if errors.Is(err, quiethours.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.GetDefault().WithComponent("api").Error("Recipient profile store error", "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Recipient profile store error"})
+}
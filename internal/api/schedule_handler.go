@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"email-service/internal/logger"
+	"email-service/internal/schedule"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleRequest 创建或替换定时任务请求
+type ScheduleRequest struct {
+	Name          string         `json:"name"`
+	Cron          string         `json:"cron" binding:"required"` // 如 "0 9 * * 1" 表示每周一 9:00
+	Timezone      string         `json:"timezone"`                // IANA 时区，为空时为 UTC
+	Subject       string         `json:"subject" binding:"required"`
+	Category      string         `json:"category"`
+	Priority      string         `json:"priority"`
+	TemplateID    string         `json:"template_id"`
+	TemplateData  map[string]any `json:"template_data"`
+	Recipients    []string       `json:"recipients"`
+	RecipientsURL string         `json:"recipients_url"` // 每次触发时从该地址获取收件人，与 recipients 二选一
+	Paused        bool           `json:"paused"`
+}
+
+// toSchedule 转换为定时任务并校验
+func (r ScheduleRequest) toSchedule() (*schedule.Schedule, error) {
+	sch := &schedule.Schedule{
+		Name:          r.Name,
+		Cron:          r.Cron,
+		Timezone:      r.Timezone,
+		Subject:       r.Subject,
+		Category:      r.Category,
+		Priority:      r.Priority,
+		TemplateID:    r.TemplateID,
+		TemplateData:  r.TemplateData,
+		Recipients:    r.Recipients,
+		RecipientsURL: r.RecipientsURL,
+		Paused:        r.Paused,
+	}
+	if err := sch.Validate(); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// ListSchedulesHandler 列出全部定时任务
+func ListSchedulesHandler(c *gin.Context) {
+	if GlobalScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Schedules not configured"})
+		return
+	}
+
+	schedules, err := GlobalScheduler.List(c.Request.Context())
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	if schedules == nil {
+		schedules = []*schedule.Schedule{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(schedules), "schedules": schedules})
+}
+
+// GetScheduleHandler 返回定时任务
+func GetScheduleHandler(c *gin.Context) {
+	if GlobalScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Schedules not configured"})
+		return
+	}
+
+	sch, err := GlobalScheduler.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sch)
+}
+
+// CreateScheduleHandler 创建定时任务
+func CreateScheduleHandler(c *gin.Context) {
+	if GlobalScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Schedules not configured"})
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	sch, err := req.toSchedule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := GlobalScheduler.Create(c.Request.Context(), sch)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Schedule created",
+		"schedule_id", created.ID,
+		"cron", created.Cron,
+		"timezone", created.Timezone,
+		"next_run_at", created.NextRunAt,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateScheduleHandler 替换定时任务的配置，下次触发时间按新的表达式重新计算
+func UpdateScheduleHandler(c *gin.Context) {
+	if GlobalScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Schedules not configured"})
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	sch, err := req.toSchedule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := GlobalScheduler.Replace(c.Request.Context(), c.Param("id"), sch)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Schedule updated",
+		"schedule_id", updated.ID,
+		"cron", updated.Cron,
+		"paused", updated.Paused,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteScheduleHandler 删除定时任务
+func DeleteScheduleHandler(c *gin.Context) {
+	if GlobalScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Schedules not configured"})
+		return
+	}
+
+	if err := GlobalScheduler.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Schedule deleted",
+		"schedule_id", c.Param("id"),
+		"remote_addr", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// respondScheduleError 将定时任务存储错误转换为 HTTP 响应
+func respondScheduleError(c *gin.Context, err error) {
+	if errors.Is(err, schedule.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.GetDefault().WithComponent("api").Error("Schedule store error", "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Schedule store error"})
+}
@@ -25,6 +25,43 @@ func RunGinServer(port string) {
 
 	r.POST("/v1/send-event-email", SendEmailHandler)
 	r.POST("/v1/preview-template", PreviewTemplateHandler)
+	r.POST("/v1/attachments", UploadAttachmentHandler)
+	r.GET("/v1/jobs/:id", GetJobStatusHandler)
+
+	r.GET("/v1/unsubscribe", UnsubscribePageHandler)
+	r.POST("/v1/unsubscribe", UnsubscribeHandler)
+
+	// 管理接口需要 Bearer 令牌
+	admin := r.Group("/v1", RequireAdminToken)
+	admin.POST("/feedback-reports", FeedbackReportHandler)
+
+	admin.GET("/suppressions", ListSuppressionsHandler)
+	admin.POST("/suppressions", AddSuppressionHandler)
+	admin.POST("/suppressions/import", ImportSuppressionsHandler)
+	admin.GET("/suppressions/:address", GetSuppressionHandler)
+	admin.DELETE("/suppressions/:address", DeleteSuppressionHandler)
+
+	admin.GET("/pgp-keys", ListPGPKeysHandler)
+	admin.GET("/pgp-keys/:address", GetPGPKeyHandler)
+	admin.PUT("/pgp-keys/:address", PutPGPKeyHandler)
+	admin.DELETE("/pgp-keys/:address", DeletePGPKeyHandler)
+
+	admin.GET("/schedules", ListSchedulesHandler)
+	admin.POST("/schedules", CreateScheduleHandler)
+	admin.GET("/schedules/:id", GetScheduleHandler)
+	admin.PUT("/schedules/:id", UpdateScheduleHandler)
+	admin.DELETE("/schedules/:id", DeleteScheduleHandler)
+
+	admin.GET("/recipient-profiles/:address", GetRecipientProfileHandler)
+	admin.PUT("/recipient-profiles/:address", PutRecipientProfileHandler)
+	admin.DELETE("/recipient-profiles/:address", DeleteRecipientProfileHandler)
+
+	admin.GET("/campaigns", ListCampaignsHandler)
+	admin.POST("/campaigns", CreateCampaignHandler)
+	admin.GET("/campaigns/:id", GetCampaignHandler)
+	admin.POST("/campaigns/:id/pause", PauseCampaignHandler)
+	admin.POST("/campaigns/:id/resume", ResumeCampaignHandler)
+	admin.POST("/campaigns/:id/cancel", CancelCampaignHandler)
 
 	addr := fmt.Sprintf(":%s", port)
 	if err := r.Run(addr); err != nil {
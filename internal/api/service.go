@@ -1,13 +1,130 @@
 package api
 
 import (
+	"email-service/internal/attachment"
+	"email-service/internal/campaign"
+	"email-service/internal/digest"
+	"email-service/internal/feedback"
+	"email-service/internal/idempotency"
 	"email-service/internal/mailer"
+	"email-service/internal/pgp"
+	"email-service/internal/quiethours"
+	"email-service/internal/schedule"
+	"email-service/internal/smime"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+	"email-service/internal/throttle"
+	"email-service/internal/unsubscribe"
 )
 
 // GlobalDispatcher 全局调度器实例
 var GlobalDispatcher *mailer.Dispatcher
 
+// GlobalAttachmentStore 全局附件存储实例
+var GlobalAttachmentStore attachment.Store
+
+// GlobalPGP 全局 PGP 服务实例
+var GlobalPGP *pgp.Service
+
+// GlobalSMIME 全局 S/MIME 服务实例，未配置 S/MIME 时为 nil
+var GlobalSMIME *smime.Service
+
+// GlobalStatusStore 全局任务状态存储实例
+var GlobalStatusStore status.Store
+
+// GlobalSuppressionStore 全局屏蔽名单实例
+var GlobalSuppressionStore suppression.Store
+
+// GlobalUnsubscribe 全局退订服务实例
+var GlobalUnsubscribe *unsubscribe.Service
+
+// GlobalFeedback 全局投诉报告处理器实例
+var GlobalFeedback *feedback.Processor
+
+// GlobalIdempotencyStore 全局幂等记录存储实例
+var GlobalIdempotencyStore idempotency.Store
+
+// GlobalThrottle 全局限流引擎实例
+var GlobalThrottle *throttle.Engine
+
+// GlobalDigest 全局摘要服务实例
+var GlobalDigest *digest.Service
+
+// GlobalScheduler 全局定时任务调度器实例
+var GlobalScheduler *schedule.Scheduler
+
+// GlobalQuietHours 全局免打扰服务实例
+var GlobalQuietHours *quiethours.Service
+
+// GlobalCampaigns 全局活动服务实例
+var GlobalCampaigns *campaign.Service
+
 // SetDispatcher 设置全局调度器实例
 func SetDispatcher(dispatcher *mailer.Dispatcher) {
 	GlobalDispatcher = dispatcher
 }
+
+// SetAttachmentStore 设置全局附件存储实例
+func SetAttachmentStore(store attachment.Store) {
+	GlobalAttachmentStore = store
+}
+
+// SetPGP 设置全局 PGP 服务实例
+func SetPGP(service *pgp.Service) {
+	GlobalPGP = service
+}
+
+// SetSMIME 设置全局 S/MIME 服务实例
+func SetSMIME(service *smime.Service) {
+	GlobalSMIME = service
+}
+
+// SetStatusStore 设置全局任务状态存储实例
+func SetStatusStore(store status.Store) {
+	GlobalStatusStore = store
+}
+
+// SetSuppressionStore 设置全局屏蔽名单实例
+func SetSuppressionStore(store suppression.Store) {
+	GlobalSuppressionStore = store
+}
+
+// SetUnsubscribe 设置全局退订服务实例
+func SetUnsubscribe(service *unsubscribe.Service) {
+	GlobalUnsubscribe = service
+}
+
+// SetFeedbackProcessor 设置全局投诉报告处理器实例
+func SetFeedbackProcessor(processor *feedback.Processor) {
+	GlobalFeedback = processor
+}
+
+// SetIdempotencyStore 设置全局幂等记录存储实例
+func SetIdempotencyStore(store idempotency.Store) {
+	GlobalIdempotencyStore = store
+}
+
+// SetThrottle 设置全局限流引擎实例
+func SetThrottle(engine *throttle.Engine) {
+	GlobalThrottle = engine
+}
+
+// SetDigest 设置全局摘要服务实例
+func SetDigest(service *digest.Service) {
+	GlobalDigest = service
+}
+
+// SetScheduler 设置全局定时任务调度器实例
+func SetScheduler(scheduler *schedule.Scheduler) {
+	GlobalScheduler = scheduler
+}
+
+// SetQuietHours 设置全局免打扰服务实例
+func SetQuietHours(service *quiethours.Service) {
+	GlobalQuietHours = service
+}
+
+// SetCampaigns 设置全局活动服务实例
+func SetCampaigns(service *campaign.Service) {
+	GlobalCampaigns = service
+}
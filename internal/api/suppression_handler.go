@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"email-service/internal/logger"
+	"email-service/internal/suppression"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportEntries 单次批量导入的最大记录数
+const maxImportEntries = 100000
+
+// SuppressionRequest 添加屏蔽记录请求
+type SuppressionRequest struct {
+	Address  string             `json:"address" binding:"required"`
+	Category string             `json:"category"` // 为空表示屏蔽全部类别
+	Reason   suppression.Reason `json:"reason"`   // 默认为 manual
+	Detail   string             `json:"detail"`
+}
+
+// ImportSuppressionsRequest 批量导入请求（JSON 格式）
+type ImportSuppressionsRequest struct {
+	Entries []SuppressionRequest `json:"entries" binding:"required"`
+}
+
+// InvalidImportRow 导入失败的行
+type InvalidImportRow struct {
+	Row   int    `json:"row"` // 从 1 开始
+	Error string `json:"error"`
+}
+
+// ListSuppressionsHandler 列出屏蔽记录，可按 address 和 reason 过滤
+func ListSuppressionsHandler(c *gin.Context) {
+	if GlobalSuppressionStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Suppression list not configured"})
+		return
+	}
+
+	entries, err := GlobalSuppressionStore.List(c.Request.Context(), c.Query("address"))
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	if reason := c.Query("reason"); reason != "" {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if string(entry.Reason) == reason {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(entries), "entries": entries})
+}
+
+// GetSuppressionHandler 返回地址的全部屏蔽记录
+func GetSuppressionHandler(c *gin.Context) {
+	if GlobalSuppressionStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Suppression list not configured"})
+		return
+	}
+
+	entries, err := GlobalSuppressionStore.List(c.Request.Context(), c.Param("address"))
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	if len(entries) == 0 {
+		respondSuppressionError(c, suppression.ErrNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"address": suppression.NormalizeAddress(c.Param("address")),
+		"entries": entries,
+	})
+}
+
+// AddSuppressionHandler 添加或覆盖一条屏蔽记录
+func AddSuppressionHandler(c *gin.Context) {
+	if GlobalSuppressionStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Suppression list not configured"})
+		return
+	}
+
+	var req SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	entry, err := req.toEntry()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := GlobalSuppressionStore.Add(c.Request.Context(), entry); err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Address suppressed",
+		"address", entry.Address,
+		"category", entry.Category,
+		"reason", entry.Reason,
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteSuppressionHandler 删除地址在 category 查询参数指定类别下的屏蔽记录
+func DeleteSuppressionHandler(c *gin.Context) {
+	if GlobalSuppressionStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Suppression list not configured"})
+		return
+	}
+
+	address, category := c.Param("address"), c.Query("category")
+	if err := GlobalSuppressionStore.Remove(c.Request.Context(), address, category); err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Address unsuppressed",
+		"address", suppression.NormalizeAddress(address),
+		"category", category,
+		"remote_addr", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// ImportSuppressionsHandler 批量导入屏蔽记录
+// 支持 JSON（{"entries": [...]}）和 CSV（Content-Type: text/csv，列为 address,category,reason,detail，
+// 首行可为表头）两种格式；无效行会被跳过并在响应中列出
+func ImportSuppressionsHandler(c *gin.Context) {
+	if GlobalSuppressionStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Suppression list not configured"})
+		return
+	}
+
+	var (
+		reqs []SuppressionRequest
+		err  error
+	)
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		reqs, err = parseSuppressionCSV(c.Request.Body)
+	} else {
+		var body ImportSuppressionsRequest
+		err = c.ShouldBindJSON(&body)
+		reqs = body.Entries
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if len(reqs) > maxImportEntries {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("Too many entries, at most %d per import", maxImportEntries),
+		})
+		return
+	}
+
+	entries := make([]suppression.Entry, 0, len(reqs))
+	invalid := make([]InvalidImportRow, 0)
+	for i, req := range reqs {
+		entry, err := req.toEntry()
+		if err != nil {
+			invalid = append(invalid, InvalidImportRow{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := GlobalSuppressionStore.AddBatch(c.Request.Context(), entries); err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+
+	logger.GetDefault().WithComponent("api").Info("Suppression list imported",
+		"imported", len(entries),
+		"invalid", len(invalid),
+		"remote_addr", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"imported": len(entries), "invalid": invalid})
+}
+
+// parseSuppressionCSV 解析 CSV 导入内容
+func parseSuppressionCSV(r io.Reader) ([]SuppressionRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var reqs []SuppressionRequest
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+
+		req := SuppressionRequest{Address: record[0]}
+		if len(record) > 1 {
+			req.Category = record[1]
+		}
+		if len(record) > 2 {
+			req.Reason = suppression.Reason(record[2])
+		}
+		if len(record) > 3 {
+			req.Detail = record[3]
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// toEntry 校验请求并转换为名单记录
+func (r SuppressionRequest) toEntry() (suppression.Entry, error) {
+	if _, err := mail.ParseAddress(r.Address); err != nil {
+		return suppression.Entry{}, fmt.Errorf("invalid address %q", r.Address)
+	}
+	reason := r.Reason
+	if reason == "" {
+		reason = suppression.ReasonManual
+	}
+	if !suppression.ValidReason(reason) {
+		return suppression.Entry{}, fmt.Errorf("invalid reason %q", reason)
+	}
+	return suppression.Entry{
+		Address:  r.Address,
+		Category: strings.TrimSpace(r.Category),
+		Reason:   reason,
+		Detail:   r.Detail,
+	}, nil
+}
+
+// respondSuppressionError 将名单存储错误转换为 HTTP 响应
+func respondSuppressionError(c *gin.Context, err error) {
+	if errors.Is(err, suppression.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.GetDefault().WithComponent("api").Error("Suppression store error", "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Suppression store error"})
+}
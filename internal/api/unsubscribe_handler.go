@@ -0,0 +1,97 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"email-service/internal/logger"
+	"email-service/internal/suppression"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unsubscribePage 退订确认页和结果页
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>退订</title></head>
+<body>
+{{if .Done}}
+<p>{{.Address}} 已退订{{if .Category}}「{{.Category}}」类{{end}}邮件。</p>
+{{else if .Error}}
+<p>{{.Error}}</p>
+{{else}}
+<p>确定要为 {{.Address}} 退订{{if .Category}}「{{.Category}}」类{{end}}邮件吗？</p>
+<form method="post" action="/v1/unsubscribe?token={{.Token}}">
+<button type="submit">确认退订</button>
+</form>
+{{end}}
+</body>
+</html>`))
+
+type unsubscribeView struct {
+	Token    string
+	Address  string
+	Category string
+	Done     bool
+	Error    string
+}
+
+// UnsubscribePageHandler 展示退订确认页，GET 请求不会修改退订状态
+// 避免邮件安全网关预取链接时误退订
+func UnsubscribePageHandler(c *gin.Context) {
+	if GlobalUnsubscribe == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	token := c.Query("token")
+	address, category, err := GlobalUnsubscribe.Parse(token)
+	if err != nil {
+		renderUnsubscribePage(c, http.StatusBadRequest, unsubscribeView{Error: "退订链接无效或已损坏。"})
+		return
+	}
+	renderUnsubscribePage(c, http.StatusOK, unsubscribeView{Token: token, Address: address, Category: category})
+}
+
+// UnsubscribeHandler 处理退订请求，同时支持 RFC 8058 一键退订和确认页表单提交
+func UnsubscribeHandler(c *gin.Context) {
+	apiLogger := logger.GetDefault().WithComponent("api")
+
+	if GlobalUnsubscribe == nil || GlobalSuppressionStore == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	address, category, err := GlobalUnsubscribe.Parse(c.Query("token"))
+	if err != nil {
+		apiLogger.Warn("Invalid unsubscribe token", "remote_addr", c.ClientIP())
+		renderUnsubscribePage(c, http.StatusBadRequest, unsubscribeView{Error: "退订链接无效或已损坏。"})
+		return
+	}
+
+	err = GlobalSuppressionStore.Add(c.Request.Context(), suppression.Entry{
+		Address:  address,
+		Category: category,
+		Reason:   suppression.ReasonUnsubscribe,
+	})
+	if err != nil {
+		apiLogger.Error("Failed to record unsubscribe", "address", address, "category", category, "error", err)
+		renderUnsubscribePage(c, http.StatusInternalServerError, unsubscribeView{Error: "退订失败，请稍后重试。"})
+		return
+	}
+
+	apiLogger.Info("Recipient unsubscribed",
+		"address", address,
+		"category", category,
+		"one_click", c.PostForm("List-Unsubscribe") == "One-Click",
+		"remote_addr", c.ClientIP())
+	renderUnsubscribePage(c, http.StatusOK, unsubscribeView{Address: address, Category: category, Done: true})
+}
+
+func renderUnsubscribePage(c *gin.Context, code int, view unsubscribeView) {
+	c.Status(code)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := unsubscribePage.Execute(c.Writer, view); err != nil {
+		logger.GetDefault().WithComponent("api").Error("Failed to render unsubscribe page", "error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package attachment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore 基于本地目录的附件存储，只能由本实例读取
+// 每个附件由内容文件 <id>.bin 和元数据文件 <id>.json 组成
+type FileStore struct {
+	config *Config
+	mu     sync.Mutex
+	metas  map[string]*Meta
+}
+
+// NewFileStore 创建文件附件存储，并加载目录中已有的附件元数据
+func NewFileStore(config *Config) (*FileStore, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create attachment dir: %w", err)
+	}
+
+	s := &FileStore{
+		config: config,
+		metas:  make(map[string]*Meta),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 从目录中加载元数据
+func (s *FileStore) load() error {
+	files, err := filepath.Glob(filepath.Join(s.config.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment meta %s: %w", file, err)
+		}
+		var meta Meta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("invalid attachment meta %s: %w", file, err)
+		}
+		s.metas[meta.ID] = &meta
+	}
+	return nil
+}
+
+// Save 保存附件内容
+func (s *FileStore) Save(ctx context.Context, filename, contentType string, r io.Reader, ttl time.Duration) (*Meta, error) {
+	if ttl <= 0 {
+		ttl = s.config.TTL
+	}
+	if s.config.MaxTTL > 0 && ttl > s.config.MaxTTL {
+		ttl = s.config.MaxTTL
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(s.config.Dir, id+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	src := r
+	if s.config.MaxSize > 0 {
+		src = io.LimitReader(r, s.config.MaxSize+1)
+	}
+	size, err := io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write attachment: %w", err)
+	}
+	if s.config.MaxSize > 0 && size > s.config.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	now := time.Now()
+	meta := &Meta{
+		ID:          id,
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Rename(file.Name(), s.blobPath(id)); err != nil {
+		return nil, err
+	}
+	if err := s.writeMeta(meta); err != nil {
+		_ = os.Remove(s.blobPath(id))
+		return nil, err
+	}
+	s.metas[id] = meta
+
+	copied := *meta
+	return &copied, nil
+}
+
+// Stat 返回附件元数据
+func (s *FileStore) Stat(ctx context.Context, id string) (*Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.metas[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if meta.Expired(time.Now()) {
+		return nil, ErrExpired
+	}
+	copied := *meta
+	return &copied, nil
+}
+
+// Open 读取附件内容
+func (s *FileStore) Open(ctx context.Context, id string) (*Meta, []byte, error) {
+	s.mu.Lock()
+	meta, ok := s.metas[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, nil, ErrNotFound
+	}
+	now := time.Now()
+	if (meta.Refs == 0 && meta.Expired(now)) || meta.Outlived(now, s.config.MaxLifetime) {
+		s.mu.Unlock()
+		return nil, nil, ErrExpired
+	}
+	copied := *meta
+	s.mu.Unlock()
+
+	data, err := os.ReadFile(s.blobPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return &copied, data, nil
+}
+
+// Retain 增加附件引用计数
+func (s *FileStore) Retain(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.metas[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if meta.Expired(time.Now()) {
+		return ErrExpired
+	}
+	meta.Refs++
+	return s.writeMeta(meta)
+}
+
+// Release 减少附件引用计数
+func (s *FileStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.metas[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if meta.Refs > 0 {
+		meta.Refs--
+	}
+	return s.writeMeta(meta)
+}
+
+// Cleanup 删除已过期且无引用的附件，以及超过最长保留时间的附件
+// 任务丢失或写入死信后引用计数不会归零，最长保留时间保证这些附件最终被删除
+func (s *FileStore) Cleanup(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	var errs []error
+	for id, meta := range s.metas {
+		if (meta.Refs > 0 || !meta.Expired(now)) && !meta.Outlived(now, s.config.MaxLifetime) {
+			continue
+		}
+		if err := os.Remove(s.blobPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Remove(s.metaPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		delete(s.metas, id)
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// writeMeta 持久化元数据，调用方需持有锁
+func (s *FileStore) writeMeta(meta *Meta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	tmp := s.metaPath(meta.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.metaPath(meta.ID))
+}
+
+func (s *FileStore) blobPath(id string) string {
+	return filepath.Join(s.config.Dir, id+".bin")
+}
+
+func (s *FileStore) metaPath(id string) string {
+	return filepath.Join(s.config.Dir, id+".json")
+}
+
+// newID 生成随机附件ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "att_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,245 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于 Redis 的附件存储，多个实例共享
+// 每个附件由内容键 <prefix>:<id>:data 和元数据哈希 <prefix>:<id> 组成，
+// 两个键在最长保留时间到达时由 Redis 删除；有序集合 <prefix>:index 按过期时间索引附件供 Cleanup 扫描
+type RedisStore struct {
+	config *Config
+	client *redis.Client
+	prefix string
+}
+
+// retainScript 附件存在且未过期时增加引用计数，返回 -1 表示不存在，-2 表示已过期
+var retainScript = redis.NewScript(`
+local expires = redis.call('HGET', KEYS[1], 'expires_at')
+if not expires then return -1 end
+if tonumber(expires) <= tonumber(ARGV[1]) then return -2 end
+return redis.call('HINCRBY', KEYS[1], 'refs', 1)
+`)
+
+// releaseScript 减少引用计数，不会减到负数，返回 -1 表示不存在
+var releaseScript = redis.NewScript(`
+local refs = redis.call('HGET', KEYS[1], 'refs')
+if not refs then return -1 end
+if tonumber(refs) > 0 then return redis.call('HINCRBY', KEYS[1], 'refs', -1) end
+return 0
+`)
+
+// cleanupScript 删除无引用的附件，返回 1 表示已删除；元数据已被 Redis 删除时只移除索引
+var cleanupScript = redis.NewScript(`
+local refs = redis.call('HGET', KEYS[1], 'refs')
+if refs and tonumber(refs) > 0 then return 0 end
+redis.call('DEL', KEYS[1], KEYS[2])
+redis.call('ZREM', KEYS[3], ARGV[1])
+if refs then return 1 end
+return 0
+`)
+
+// NewRedisStore 创建 Redis 附件存储
+func NewRedisStore(config *Config) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Addr,
+		Password: config.Redis.Password,
+		DB:       config.Redis.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Redis.Prefix
+	if prefix == "" {
+		prefix = "email:attachment"
+	}
+	return &RedisStore{config: config, client: client, prefix: prefix}, nil
+}
+
+// Save 保存附件内容
+func (s *RedisStore) Save(ctx context.Context, filename, contentType string, r io.Reader, ttl time.Duration) (*Meta, error) {
+	if ttl <= 0 {
+		ttl = s.config.TTL
+	}
+	if s.config.MaxTTL > 0 && ttl > s.config.MaxTTL {
+		ttl = s.config.MaxTTL
+	}
+
+	src := r
+	if s.config.MaxSize > 0 {
+		src = io.LimitReader(r, s.config.MaxSize+1)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if s.config.MaxSize > 0 && int64(len(data)) > s.config.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	meta := &Meta{
+		ID:          id,
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.dataKey(id), data, 0)
+		pipe.HSet(ctx, s.metaKey(id),
+			"filename", meta.Filename,
+			"content_type", meta.ContentType,
+			"size", meta.Size,
+			"created_at", meta.CreatedAt.UnixMilli(),
+			"expires_at", meta.ExpiresAt.UnixMilli(),
+			"refs", 0)
+		if s.config.MaxLifetime > 0 {
+			deadline := now.Add(s.config.MaxLifetime)
+			pipe.PExpireAt(ctx, s.dataKey(id), deadline)
+			pipe.PExpireAt(ctx, s.metaKey(id), deadline)
+		}
+		pipe.ZAdd(ctx, s.indexKey(), redis.Z{Score: float64(meta.ExpiresAt.UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write attachment: %w", err)
+	}
+	return meta, nil
+}
+
+// Stat 返回附件元数据
+func (s *RedisStore) Stat(ctx context.Context, id string) (*Meta, error) {
+	meta, err := s.meta(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Expired(time.Now()) {
+		return nil, ErrExpired
+	}
+	return meta, nil
+}
+
+// Open 读取附件内容
+func (s *RedisStore) Open(ctx context.Context, id string) (*Meta, []byte, error) {
+	meta, err := s.meta(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.Refs == 0 && meta.Expired(time.Now()) {
+		return nil, nil, ErrExpired
+	}
+	data, err := s.client.Get(ctx, s.dataKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, data, nil
+}
+
+// Retain 增加附件引用计数
+func (s *RedisStore) Retain(ctx context.Context, id string) error {
+	n, err := retainScript.Run(ctx, s.client, []string{s.metaKey(id)}, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return err
+	}
+	switch n {
+	case -1:
+		return ErrNotFound
+	case -2:
+		return ErrExpired
+	}
+	return nil
+}
+
+// Release 减少附件引用计数
+func (s *RedisStore) Release(ctx context.Context, id string) error {
+	n, err := releaseScript.Run(ctx, s.client, []string{s.metaKey(id)}).Int()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Cleanup 删除已过期且无引用的附件；超过最长保留时间的附件由 Redis 删除，这里只移除其索引
+func (s *RedisStore) Cleanup(ctx context.Context) (int, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.indexKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var errs []error
+	for _, id := range ids {
+		keys := []string{s.metaKey(id), s.dataKey(id), s.indexKey()}
+		n, err := cleanupScript.Run(ctx, s.client, keys, id).Int()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed += n
+	}
+	return removed, errors.Join(errs...)
+}
+
+// meta 读取附件元数据
+func (s *RedisStore) meta(ctx context.Context, id string) (*Meta, error) {
+	values, err := s.client.HGetAll(ctx, s.metaKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+
+	meta := &Meta{
+		ID:          id,
+		Filename:    values["filename"],
+		ContentType: values["content_type"],
+	}
+	meta.Size, _ = strconv.ParseInt(values["size"], 10, 64)
+	meta.Refs, _ = strconv.Atoi(values["refs"])
+	createdAt, _ := strconv.ParseInt(values["created_at"], 10, 64)
+	expiresAt, _ := strconv.ParseInt(values["expires_at"], 10, 64)
+	meta.CreatedAt = time.UnixMilli(createdAt)
+	meta.ExpiresAt = time.UnixMilli(expiresAt)
+	return meta, nil
+}
+
+func (s *RedisStore) metaKey(id string) string {
+	return s.prefix + ":" + id
+}
+
+func (s *RedisStore) dataKey(id string) string {
+	return s.prefix + ":" + id + ":data"
+}
+
+func (s *RedisStore) indexKey() string {
+	return s.prefix + ":index"
+}
@@ -0,0 +1,137 @@
+// Package attachment 附件存储，支持先上传附件再在发送请求中通过ID引用
+package attachment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound 附件不存在
+	ErrNotFound = errors.New("attachment not found")
+
+	// ErrExpired 附件已过期
+	ErrExpired = errors.New("attachment expired")
+
+	// ErrTooLarge 附件超过大小限制
+	ErrTooLarge = errors.New("attachment too large")
+)
+
+// Config 附件存储配置
+type Config struct {
+	Type        string        `mapstructure:"type"`         // file 或 redis
+	Dir         string        `mapstructure:"dir"`          // file 类型的附件存放目录
+	Redis       *RedisConfig  `mapstructure:"redis"`        // redis 类型的连接配置
+	TTL         time.Duration `mapstructure:"ttl"`          // 附件默认有效期
+	MaxTTL      time.Duration `mapstructure:"max_ttl"`      // 上传时允许指定的最大有效期
+	MaxLifetime time.Duration `mapstructure:"max_lifetime"` // 附件最长保留时间，超过后即使仍被引用也会删除，0 表示不限
+	MaxSize     int64         `mapstructure:"max_size"`     // 单个附件最大字节数
+	GCInterval  time.Duration `mapstructure:"gc_interval"`  // 垃圾回收间隔
+}
+
+// RedisConfig Redis 附件存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	TypeFile  = "file"  // 本地目录，只能由本实例读取
+	TypeRedis = "redis" // 多个实例共享
+)
+
+// DefaultConfig 返回默认附件存储配置
+func DefaultConfig() *Config {
+	return &Config{
+		Type:        TypeFile,
+		Dir:         "data/attachments",
+		TTL:         24 * time.Hour,
+		MaxTTL:      7 * 24 * time.Hour,
+		MaxLifetime: 30 * 24 * time.Hour,
+		MaxSize:     25 << 20,
+		GCInterval:  10 * time.Minute,
+	}
+}
+
+// NewStore 根据配置创建附件存储
+func NewStore(cfg *Config) (Store, error) {
+	switch cfg.Type {
+	case TypeFile, "":
+		return NewFileStore(cfg)
+	case TypeRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("attachment: redis config is required")
+		}
+		return NewRedisStore(cfg)
+	default:
+		return nil, fmt.Errorf("attachment: unsupported store type %q", cfg.Type)
+	}
+}
+
+// Meta 附件元数据
+type Meta struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Refs        int       `json:"refs"` // 引用该附件且尚未结束的任务数
+}
+
+// Expired 判断附件是否已过期
+func (m *Meta) Expired(now time.Time) bool {
+	return !now.Before(m.ExpiresAt)
+}
+
+// Outlived 判断附件是否超过最长保留时间，lifetime 为 0 时不限
+func (m *Meta) Outlived(now time.Time, lifetime time.Duration) bool {
+	return lifetime > 0 && !now.Before(m.CreatedAt.Add(lifetime))
+}
+
+// Store 定义附件存储接口
+type Store interface {
+	// Save 保存附件内容，ttl 为 0 时使用默认有效期
+	Save(ctx context.Context, filename, contentType string, r io.Reader, ttl time.Duration) (*Meta, error)
+
+	// Stat 返回附件元数据，附件不存在或已过期时返回错误
+	Stat(ctx context.Context, id string) (*Meta, error)
+
+	// Open 读取附件内容，已被任务引用的附件即使过期也可读取
+	Open(ctx context.Context, id string) (*Meta, []byte, error)
+
+	// Retain 增加附件引用计数，任务入队前调用
+	Retain(ctx context.Context, id string) error
+
+	// Release 减少附件引用计数，任务结束（成功或永久失败）后调用
+	Release(ctx context.Context, id string) error
+
+	// Cleanup 删除已过期且无引用的附件，以及超过最长保留时间的附件，返回删除数量
+	Cleanup(ctx context.Context) (int, error)
+}
+
+// RunGC 按间隔周期性清理附件，直到 ctx 结束
+func RunGC(ctx context.Context, store Store, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultConfig().GCInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.Cleanup(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
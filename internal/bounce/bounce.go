@@ -0,0 +1,74 @@
+// Package bounce 轮询退信邮箱，解析退信并回写任务状态和屏蔽名单
+package bounce
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// 邮箱协议
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+)
+
+// Config 退信邮箱配置
+type Config struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	Protocol           string        `mapstructure:"protocol"` // imap 或 pop3
+	Addr               string        `mapstructure:"addr"`     // host:port
+	TLS                bool          `mapstructure:"tls"`      // 隐式 TLS（993/995 端口）
+	StartTLS           bool          `mapstructure:"starttls"` // 明文连接后升级 TLS
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"`
+	Username           string        `mapstructure:"username"`
+	Password           string        `mapstructure:"password"`
+	Mailbox            string        `mapstructure:"mailbox"`          // IMAP 文件夹
+	Interval           time.Duration `mapstructure:"interval"`         // 轮询间隔
+	DeleteProcessed    bool          `mapstructure:"delete_processed"` // 处理后删除邮件，否则 IMAP 标记为已读
+}
+
+// DefaultConfig 返回默认退信邮箱配置
+func DefaultConfig() *Config {
+	return &Config{
+		Protocol: ProtocolIMAP,
+		Mailbox:  "INBOX",
+		Interval: time.Minute,
+	}
+}
+
+// Mailbox 定义退信邮箱接口
+type Mailbox interface {
+	// Poll 连接邮箱，对每封未处理的邮件调用 handle
+	// handle 返回 nil 的邮件会被标记为已处理，返回错误的邮件留待下次轮询
+	Poll(ctx context.Context, handle func(raw []byte) error) error
+}
+
+// NewMailbox 根据配置创建邮箱客户端
+func NewMailbox(cfg *Config) (Mailbox, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("bounce: mailbox addr is required")
+	}
+	switch cfg.Protocol {
+	case ProtocolIMAP, "":
+		return &IMAPMailbox{config: cfg}, nil
+	case ProtocolPOP3:
+		return &POP3Mailbox{config: cfg, seen: make(map[string]bool)}, nil
+	default:
+		return nil, fmt.Errorf("bounce: unsupported protocol %q", cfg.Protocol)
+	}
+}
+
+// tlsConfig 返回连接邮箱使用的 TLS 配置
+func tlsConfig(cfg *Config) *tls.Config {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		host = cfg.Addr
+	}
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+}
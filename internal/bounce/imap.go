@@ -0,0 +1,121 @@
+package bounce
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// maxFetch 每次轮询最多处理的邮件数
+const maxFetch = 500
+
+// IMAPMailbox IMAP 退信邮箱，处理未读邮件
+type IMAPMailbox struct {
+	config *Config
+}
+
+// Poll 处理未读邮件，成功的邮件标记为已读或删除
+func (m *IMAPMailbox) Poll(ctx context.Context, handle func(raw []byte) error) error {
+	c, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	mailbox := m.config.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := c.Select(mailbox, false); err != nil {
+		return fmt.Errorf("imap: select %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag, imap.DeletedFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return fmt.Errorf("imap: search: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+	if len(uids) > maxFetch {
+		uids = uids[:maxFetch]
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 10)
+	fetchDone := make(chan error, 1)
+	go func() {
+		fetchDone <- c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	}()
+
+	processed := new(imap.SeqSet)
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil || ctx.Err() != nil {
+			continue
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			continue
+		}
+		if handle(raw) == nil {
+			processed.AddNum(msg.Uid)
+		}
+	}
+	if err := <-fetchDone; err != nil {
+		return fmt.Errorf("imap: fetch: %w", err)
+	}
+	if processed.Empty() {
+		return nil
+	}
+
+	flag := imap.SeenFlag
+	if m.config.DeleteProcessed {
+		flag = imap.DeletedFlag
+	}
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.UidStore(processed, item, []interface{}{flag}, nil); err != nil {
+		return fmt.Errorf("imap: store flags: %w", err)
+	}
+	if m.config.DeleteProcessed {
+		if err := c.Expunge(nil); err != nil {
+			return fmt.Errorf("imap: expunge: %w", err)
+		}
+	}
+	return nil
+}
+
+// connect 连接并登录 IMAP 服务器
+func (m *IMAPMailbox) connect() (*client.Client, error) {
+	var (
+		c   *client.Client
+		err error
+	)
+	if m.config.TLS {
+		c, err = client.DialTLS(m.config.Addr, tlsConfig(m.config))
+	} else {
+		c, err = client.Dial(m.config.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap: dial %s: %w", m.config.Addr, err)
+	}
+
+	if m.config.StartTLS && !m.config.TLS {
+		if err := c.StartTLS(tlsConfig(m.config)); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("imap: starttls: %w", err)
+		}
+	}
+	if err := c.Login(m.config.Username, m.config.Password); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("imap: login: %w", err)
+	}
+	return c, nil
+}
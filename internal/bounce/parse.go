@@ -0,0 +1,306 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"email-service/internal/mimeutil"
+	"email-service/internal/smtpstatus"
+)
+
+// ErrNotBounce 邮件不是退信
+var ErrNotBounce = errors.New("not a bounce message")
+
+// Action 退信中收件人的投递结果
+type Action string
+
+const (
+	ActionFailed  Action = "failed"  // 投递失败
+	ActionDelayed Action = "delayed" // 投递延迟，MTA 仍在重试
+)
+
+// Recipient 退信中单个收件人的投递结果
+type Recipient struct {
+	Address    string `json:"address"`
+	Action     Action `json:"action"`
+	Status     string `json:"status,omitempty"`     // 增强状态码，如 5.1.1
+	Diagnostic string `json:"diagnostic,omitempty"` // 远端服务器的诊断信息
+}
+
+// Permanent 判断是否为永久性失败
+func (r Recipient) Permanent() bool {
+	if r.Action != ActionFailed {
+		return false
+	}
+	if r.Status == "" {
+		return true
+	}
+	return strings.HasPrefix(r.Status, "5.")
+}
+
+// HardBounce 判断是否为指向收件地址本身的永久性失败
+func (r Recipient) HardBounce() bool {
+	if !r.Permanent() {
+		return false
+	}
+	if r.Status == "" && r.Diagnostic == "" {
+		return true
+	}
+	return smtpstatus.IsAddressFailure(smtpstatus.Code(r.Diagnostic), r.Status)
+}
+
+// Report 解析后的退信
+type Report struct {
+	MessageID  string      // 原始邮件的 Message-ID
+	Recipients []Recipient // 投递失败或延迟的收件人
+	ReturnPath []string    // 退信的收件地址（To、Delivered-To 等），用于解析 VERP
+	Standard   bool        // 是否为 RFC 3464 格式
+}
+
+var (
+	messageIDPattern   = regexp.MustCompile(`(?mi)^message-id:\s*(<[^>\s]+>)`)
+	bracketedRecipient = regexp.MustCompile(`(?m)^\s*<([^<>\s@]+@[^<>\s]+)>:`)
+	failedAddressLine  = regexp.MustCompile(`(?i)following (?:address|recipient)\(?e?s?\)?\s+failed:?\s*\n\s*<?([^\s<>@]+@[^\s<>:]+)>?`)
+	genericRecipient   = regexp.MustCompile(`(?i)(?:delivery to|recipient address|could not be delivered to|following recipients?)[^\n]*?<?([\w.+'=-]+@[\w-]+(?:\.[\w-]+)+)>?`)
+	bounceSubject      = regexp.MustCompile(`(?i)undeliver|undelivered|delivery (?:status|failure|has failed|failed)|returned mail|failure notice|mail delivery failed|non-?delivery`)
+	bounceSender       = regexp.MustCompile(`(?i)mailer-daemon|postmaster|mail delivery (?:system|subsystem)`)
+)
+
+// Parse 解析退信，支持 RFC 3464 投递状态通知以及 Postfix、qmail、Exim 等常见的非标准格式
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{ReturnPath: returnPath(msg.Header)}
+	var text strings.Builder
+	if err := walk(textproto.MIMEHeader(msg.Header), msg.Body, report, &text); err != nil {
+		return nil, err
+	}
+
+	if len(report.Recipients) > 0 {
+		report.Standard = true
+	} else {
+		if !looksLikeBounce(msg.Header) {
+			return nil, ErrNotBounce
+		}
+		parseText(text.String(), msg.Header, report)
+		if len(report.Recipients) == 0 {
+			return nil, ErrNotBounce
+		}
+	}
+
+	if report.MessageID == "" {
+		if m := messageIDPattern.FindStringSubmatch(text.String()); m != nil {
+			report.MessageID = m[1]
+		}
+	}
+	return report, nil
+}
+
+// walk 遍历 MIME 结构，解析投递状态和原始邮件头部，并收集纯文本内容
+func walk(header textproto.MIMEHeader, body io.Reader, report *Report, text *strings.Builder) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walk(part.Header, part, report, text); err != nil {
+				return err
+			}
+		}
+	}
+
+	body = mimeutil.DecodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+	switch mediaType {
+	case "message/delivery-status", "message/global-delivery-status":
+		return parseDeliveryStatus(body, report)
+	case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+		return parseOriginal(body, report)
+	case "text/plain", "text/html":
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		text.Write(data)
+		text.WriteByte('\n')
+	}
+	return nil
+}
+
+// parseDeliveryStatus 解析 message/delivery-status 内容：首个字段组描述报告本身，其后每组对应一个收件人
+func parseDeliveryStatus(body io.Reader, report *Report) error {
+	tp := textproto.NewReader(bufio.NewReader(body))
+	first := true
+	for {
+		fields, err := tp.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(fields) > 0 {
+			if !first {
+				if rcpt, ok := dsnRecipient(fields); ok {
+					report.Recipients = append(report.Recipients, rcpt)
+				}
+			}
+			first = false
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// dsnRecipient 将收件人字段组转换为 Recipient，只保留失败和延迟的记录
+func dsnRecipient(fields textproto.MIMEHeader) (Recipient, bool) {
+	address := typedValue(fields.Get("Final-Recipient"))
+	if address == "" {
+		address = typedValue(fields.Get("Original-Recipient"))
+	}
+	action := Action(strings.ToLower(strings.TrimSpace(fields.Get("Action"))))
+	if address == "" || (action != ActionFailed && action != ActionDelayed) {
+		return Recipient{}, false
+	}
+
+	rcpt := Recipient{
+		Address:    strings.Trim(address, "<>"),
+		Action:     action,
+		Status:     smtpstatus.Enhanced(fields.Get("Status")),
+		Diagnostic: typedValue(fields.Get("Diagnostic-Code")),
+	}
+	if rcpt.Status == "" {
+		rcpt.Status = smtpstatus.Enhanced(rcpt.Diagnostic)
+	}
+	return rcpt, true
+}
+
+// typedValue 去掉 "rfc822; user@example.com" 形式字段的类型前缀
+func typedValue(v string) string {
+	if i := strings.Index(v, ";"); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// parseOriginal 从附带的原始邮件或其头部中提取 Message-ID
+func parseOriginal(body io.Reader, report *Report) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if report.MessageID != "" {
+		return nil
+	}
+	// text/rfc822-headers 只有头部，补一个空行使其成为完整邮件
+	msg, err := mail.ReadMessage(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n")))
+	if err == nil {
+		report.MessageID = strings.TrimSpace(msg.Header.Get("Message-ID"))
+	}
+	return nil
+}
+
+// parseText 从非标准退信的文本中提取失败收件人和状态码
+func parseText(text string, header mail.Header, report *Report) {
+	var addresses []string
+	if failed := header.Get("X-Failed-Recipients"); failed != "" {
+		for _, addr := range strings.Split(failed, ",") {
+			addresses = append(addresses, strings.TrimSpace(addr))
+		}
+	}
+	if len(addresses) == 0 {
+		for _, m := range bracketedRecipient.FindAllStringSubmatch(text, -1) {
+			addresses = append(addresses, m[1])
+		}
+	}
+	if len(addresses) == 0 {
+		if m := failedAddressLine.FindStringSubmatch(text); m != nil {
+			addresses = append(addresses, m[1])
+		}
+	}
+	if len(addresses) == 0 {
+		if m := genericRecipient.FindStringSubmatch(text); m != nil {
+			addresses = append(addresses, m[1])
+		}
+	}
+
+	enhanced := smtpstatus.Enhanced(text)
+	diagnostic := diagnosticLine(text, enhanced)
+	action := ActionFailed
+	if strings.HasPrefix(enhanced, "4.") || strings.Contains(strings.ToLower(text), "will retry") ||
+		strings.Contains(strings.ToLower(text), "delayed") {
+		action = ActionDelayed
+	}
+
+	seen := make(map[string]bool)
+	for _, addr := range addresses {
+		addr = strings.ToLower(strings.TrimRight(addr, ".,;"))
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		report.Recipients = append(report.Recipients, Recipient{
+			Address:    addr,
+			Action:     action,
+			Status:     enhanced,
+			Diagnostic: diagnostic,
+		})
+	}
+}
+
+// diagnosticLine 返回包含状态码的那一行，作为诊断信息
+func diagnosticLine(text, enhanced string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if enhanced != "" && strings.Contains(line, enhanced) {
+			return line
+		}
+		if enhanced == "" && smtpstatus.Code(line) >= 500 {
+			return line
+		}
+	}
+	return ""
+}
+
+// looksLikeBounce 根据发件人和主题判断非标准邮件是否为退信
+func looksLikeBounce(header mail.Header) bool {
+	return header.Get("X-Failed-Recipients") != "" ||
+		bounceSender.MatchString(header.Get("From")) ||
+		bounceSubject.MatchString(header.Get("Subject"))
+}
+
+// returnPath 收集退信的收件地址
+func returnPath(header mail.Header) []string {
+	var addrs []string
+	for _, key := range []string{"Delivered-To", "X-Original-To", "Envelope-To", "To"} {
+		for _, v := range header[textproto.CanonicalMIMEHeaderKey(key)] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				addrs = append(addrs, strings.Trim(strings.TrimSpace(v), "<>"))
+				continue
+			}
+			for _, a := range list {
+				addrs = append(addrs, a.Address)
+			}
+		}
+	}
+	return addrs
+}
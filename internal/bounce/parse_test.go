@@ -0,0 +1,317 @@
+package bounce
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// crlf 将测试样例中的换行转换为 CRLF
+func crlf(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+const rfc3464Bounce = `From: Mail Delivery System <MAILER-DAEMON@mx.example.net>
+To: bounces+0123456789abcdef@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain; charset=us-ascii
+
+This is the mail system at host mx.example.net.
+
+I'm sorry to have to inform you that your message could not
+be delivered to one or more recipients.
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+Arrival-Date: Mon, 19 Oct 2026 10:00:00 +0800
+
+Final-Recipient: rfc822; alice@example.org
+Original-Recipient: rfc822;alice@example.org
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <alice@example.org>: Recipient address rejected: User unknown
+
+Final-Recipient: rfc822; bob@example.org
+Action: delayed
+Status: 4.4.1
+Diagnostic-Code: smtp; 421 4.4.1 Connection timed out
+
+Final-Recipient: rfc822; carol@example.org
+Action: delivered
+Status: 2.0.0
+
+--BOUNDARY
+Content-Type: message/rfc822
+
+From: sender@example.com
+To: alice@example.org
+Subject: Hello
+Message-ID: <original-1@example.com>
+
+Hello Alice
+--BOUNDARY--
+`
+
+func TestParseRFC3464(t *testing.T) {
+	report, err := Parse(crlf(rfc3464Bounce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Standard {
+		t.Error("report not recognized as RFC 3464")
+	}
+	if report.MessageID != "<original-1@example.com>" {
+		t.Errorf("MessageID = %q", report.MessageID)
+	}
+	if len(report.ReturnPath) != 1 || report.ReturnPath[0] != "bounces+0123456789abcdef@example.com" {
+		t.Errorf("ReturnPath = %v", report.ReturnPath)
+	}
+
+	// 已投递的收件人不计入退信
+	if len(report.Recipients) != 2 {
+		t.Fatalf("got %d recipients, want 2: %+v", len(report.Recipients), report.Recipients)
+	}
+	alice, bob := report.Recipients[0], report.Recipients[1]
+	if alice.Address != "alice@example.org" || alice.Action != ActionFailed || alice.Status != "5.1.1" ||
+		!strings.Contains(alice.Diagnostic, "User unknown") {
+		t.Errorf("alice = %+v", alice)
+	}
+	if bob.Address != "bob@example.org" || bob.Action != ActionDelayed || bob.Status != "4.4.1" {
+		t.Errorf("bob = %+v", bob)
+	}
+}
+
+func TestParseRFC3464Variants(t *testing.T) {
+	// 只附带原始邮件头部，状态码只出现在诊断信息中，内容经过 base64 编码
+	raw := `From: postmaster@mx.example.net
+To: sender@example.com
+Subject: Delivery Status Notification (Failure)
+Content-Type: multipart/report; report-type=delivery-status; boundary=b1
+
+--b1
+Content-Type: text/plain
+
+Delivery failed.
+--b1
+Content-Type: message/delivery-status
+Content-Transfer-Encoding: base64
+
+UmVwb3J0aW5nLU1UQTogZG5zOyBteC5leGFtcGxlLm5ldA0KDQpGaW5hbC1SZWNpcGllbnQ6IHJm
+YzgyMjsgPERhdmVARXhhbXBsZS5vcmc+DQpBY3Rpb246IEZhaWxlZA0KRGlhZ25vc3RpYy1Db2Rl
+OiBzbXRwOyA1NTAgNS4yLjEgTWFpbGJveCBkaXNhYmxlZA0K
+--b1
+Content-Type: text/rfc822-headers
+
+From: sender@example.com
+Message-ID: <original-2@example.com>
+--b1--
+`
+	report, err := Parse(crlf(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MessageID != "<original-2@example.com>" {
+		t.Errorf("MessageID = %q", report.MessageID)
+	}
+	if len(report.Recipients) != 1 {
+		t.Fatalf("recipients = %+v", report.Recipients)
+	}
+	rcpt := report.Recipients[0]
+	if rcpt.Address != "Dave@Example.org" || rcpt.Action != ActionFailed || rcpt.Status != "5.2.1" {
+		t.Errorf("recipient = %+v", rcpt)
+	}
+}
+
+func TestParseNonStandard(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		address   string
+		action    Action
+		status    string
+		messageID string
+	}{
+		{
+			name: "postfix",
+			raw: `From: MAILER-DAEMON@mx.example.net (Mail Delivery System)
+To: sender@example.com
+Subject: Undelivered Mail Returned to Sender
+
+This is the mail system at host mx.example.net.
+
+<alice@example.org>: host mx.example.org[192.0.2.1] said: 550 5.1.1
+    <alice@example.org>: Recipient address rejected: User unknown (in reply to
+    RCPT TO command)
+
+Message-ID: <postfix-1@example.com>
+`,
+			address:   "alice@example.org",
+			action:    ActionFailed,
+			status:    "5.1.1",
+			messageID: "<postfix-1@example.com>",
+		},
+		{
+			name: "qmail",
+			raw: `From: MAILER-DAEMON@qmail.example.net
+To: sender@example.com
+Subject: failure notice
+
+Hi. This is the qmail-send program at qmail.example.net.
+I'm afraid I wasn't able to deliver your message to the following addresses.
+This is a permanent error; I've given up. Sorry it didn't work out.
+
+<bob@example.org>:
+192.0.2.2 does not like recipient.
+Remote host said: 550 sorry, no mailbox here by that name
+Giving up on 192.0.2.2.
+`,
+			address: "bob@example.org",
+			action:  ActionFailed,
+		},
+		{
+			name: "exim",
+			raw: `From: Mail Delivery System <Mailer-Daemon@exim.example.net>
+To: sender@example.com
+Subject: Mail delivery failed: returning message to sender
+X-Failed-Recipients: carol@example.org
+
+This message was created automatically by mail delivery software.
+
+A message that you sent could not be delivered to one or more of its
+recipients. This is a permanent error. The following address(es) failed:
+
+  carol@example.org
+    SMTP error from remote mail server after RCPT TO:<carol@example.org>:
+    550 5.2.1 The email account that you tried to reach is disabled.
+`,
+			address: "carol@example.org",
+			action:  ActionFailed,
+			status:  "5.2.1",
+		},
+		{
+			name: "exim without header",
+			raw: `From: Mail Delivery System <Mailer-Daemon@exim.example.net>
+To: sender@example.com
+Subject: Mail delivery failed: returning message to sender
+
+A message that you sent could not be delivered to one or more of its
+recipients. This is a permanent error. The following address(es) failed:
+
+  Dave@Example.org
+    550 5.1.1 No such user
+`,
+			address: "dave@example.org",
+			action:  ActionFailed,
+			status:  "5.1.1",
+		},
+		{
+			name: "delayed",
+			raw: `From: postmaster@mx.example.net
+To: sender@example.com
+Subject: Delivery Status Notification (Delay)
+
+Delivery to the following recipient has been delayed: <erin@example.org>
+451 4.2.2 Mailbox full, will retry for 4 more days
+`,
+			address: "erin@example.org",
+			action:  ActionDelayed,
+			status:  "4.2.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Parse(crlf(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Standard {
+				t.Error("non-standard bounce reported as RFC 3464")
+			}
+			if len(report.Recipients) != 1 {
+				t.Fatalf("recipients = %+v", report.Recipients)
+			}
+			rcpt := report.Recipients[0]
+			if rcpt.Address != tt.address || rcpt.Action != tt.action || rcpt.Status != tt.status {
+				t.Errorf("recipient = %+v, want %s %s %s", rcpt, tt.address, tt.action, tt.status)
+			}
+			if rcpt.Action == ActionFailed && rcpt.Diagnostic == "" {
+				t.Error("missing diagnostic")
+			}
+			if report.MessageID != tt.messageID {
+				t.Errorf("MessageID = %q, want %q", report.MessageID, tt.messageID)
+			}
+		})
+	}
+}
+
+func TestParseNotBounce(t *testing.T) {
+	tests := map[string]string{
+		"ordinary": `From: alice@example.org
+To: sender@example.com
+Subject: Re: Hello
+
+Thanks, see you soon.
+`,
+		// 主题像退信但找不到收件人
+		"no recipient": `From: alice@example.org
+To: sender@example.com
+Subject: Re: undelivered parcel
+
+Where is my parcel?
+`,
+		// RFC 3464 报告中只有投递成功的记录
+		"delivered only": `From: postmaster@mx.example.net
+To: sender@example.com
+Subject: Delivery Status Notification (Success)
+Content-Type: multipart/report; report-type=delivery-status; boundary=b1
+
+--b1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+Final-Recipient: rfc822; alice@example.org
+Action: delivered
+Status: 2.0.0
+--b1--
+`,
+	}
+	for name, raw := range tests {
+		if _, err := Parse(crlf(raw)); !errors.Is(err, ErrNotBounce) {
+			t.Errorf("%s: err = %v, want ErrNotBounce", name, err)
+		}
+	}
+}
+
+func TestRecipientClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		rcpt       Recipient
+		permanent  bool
+		hardBounce bool
+	}{
+		{"unknown user", Recipient{Action: ActionFailed, Status: "5.1.1"}, true, true},
+		{"mailbox disabled", Recipient{Action: ActionFailed, Status: "5.2.1"}, true, true},
+		{"mailbox full", Recipient{Action: ActionFailed, Status: "5.2.2"}, true, false},
+		{"policy rejection", Recipient{Action: ActionFailed, Status: "5.7.1", Diagnostic: "550 5.7.1 Message rejected as spam"}, true, false},
+		{"transient failure", Recipient{Action: ActionFailed, Status: "4.4.1"}, false, false},
+		{"delayed", Recipient{Action: ActionDelayed, Status: "5.1.1"}, false, false},
+		{"no status", Recipient{Action: ActionFailed}, true, true},
+		{"reply code 550 only", Recipient{Action: ActionFailed, Diagnostic: "550 no such user"}, true, true},
+		{"reply code 554 only", Recipient{Action: ActionFailed, Diagnostic: "554 transaction failed"}, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rcpt.Permanent(); got != tt.permanent {
+			t.Errorf("%s: Permanent = %v, want %v", tt.name, got, tt.permanent)
+		}
+		if got := tt.rcpt.HardBounce(); got != tt.hardBounce {
+			t.Errorf("%s: HardBounce = %v, want %v", tt.name, got, tt.hardBounce)
+		}
+	}
+}
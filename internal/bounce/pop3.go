@@ -0,0 +1,174 @@
+package bounce
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// POP3Mailbox POP3 退信邮箱
+// POP3 没有已读标记，未开启 DeleteProcessed 时按 UIDL 在内存中记录已处理的邮件
+type POP3Mailbox struct {
+	config *Config
+	mu     sync.Mutex
+	seen   map[string]bool
+}
+
+// Poll 处理尚未处理过的邮件
+func (m *POP3Mailbox) Poll(ctx context.Context, handle func(raw []byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	messages, err := m.uidl(conn)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, len(messages))
+	processed := 0
+	for _, msg := range messages {
+		current[msg.uid] = true
+		if m.seen[msg.uid] || processed >= maxFetch || ctx.Err() != nil {
+			continue
+		}
+		processed++
+
+		if _, err := pop3Cmd(conn, "RETR %d", msg.num); err != nil {
+			return err
+		}
+		raw, err := conn.ReadDotBytes()
+		if err != nil {
+			return fmt.Errorf("pop3: retr %d: %w", msg.num, err)
+		}
+		if handle(raw) != nil {
+			continue
+		}
+
+		if m.config.DeleteProcessed {
+			if _, err := pop3Cmd(conn, "DELE %d", msg.num); err != nil {
+				return err
+			}
+		} else {
+			m.seen[msg.uid] = true
+		}
+	}
+
+	// 已不在邮箱中的邮件无需继续记录
+	for uid := range m.seen {
+		if !current[uid] {
+			delete(m.seen, uid)
+		}
+	}
+
+	// DELE 在 QUIT 后才生效
+	_, err = pop3Cmd(conn, "QUIT")
+	return err
+}
+
+type pop3Message struct {
+	num int
+	uid string
+}
+
+// uidl 列出邮件编号和唯一标识
+func (m *POP3Mailbox) uidl(conn *textproto.Conn) ([]pop3Message, error) {
+	if _, err := pop3Cmd(conn, "UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := conn.ReadDotLines()
+	if err != nil {
+		return nil, fmt.Errorf("pop3: uidl: %w", err)
+	}
+
+	messages := make([]pop3Message, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		num, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		messages = append(messages, pop3Message{num: num, uid: fields[1]})
+	}
+	return messages, nil
+}
+
+// connect 连接并登录 POP3 服务器
+func (m *POP3Mailbox) connect() (*textproto.Conn, error) {
+	var (
+		raw net.Conn
+		err error
+	)
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if m.config.TLS {
+		raw, err = tls.DialWithDialer(dialer, "tcp", m.config.Addr, tlsConfig(m.config))
+	} else {
+		raw, err = dialer.Dial("tcp", m.config.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pop3: dial %s: %w", m.config.Addr, err)
+	}
+
+	conn := textproto.NewConn(raw)
+	if _, err := pop3Response(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if m.config.StartTLS && !m.config.TLS {
+		if _, err := pop3Cmd(conn, "STLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(raw, tlsConfig(m.config))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("pop3: stls: %w", err)
+		}
+		conn = textproto.NewConn(tlsConn)
+	}
+
+	if _, err := pop3Cmd(conn, "USER %s", m.config.Username); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := pop3Cmd(conn, "PASS %s", m.config.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// pop3Cmd 发送命令并读取单行响应
+func pop3Cmd(conn *textproto.Conn, format string, args ...any) (string, error) {
+	if err := conn.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return pop3Response(conn)
+}
+
+// pop3Response 读取单行响应，-ERR 转换为错误
+func pop3Response(conn *textproto.Conn) (string, error) {
+	line, err := conn.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "+OK") {
+		return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+	}
+	return "", fmt.Errorf("pop3: %s", line)
+}
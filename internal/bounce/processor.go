@@ -0,0 +1,199 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"email-service/internal/events"
+	"email-service/internal/logger"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+)
+
+// ReturnPathDecoder 从退信的收件地址（如 VERP 地址）解析出任务ID
+type ReturnPathDecoder func(address string) (jobID string, ok bool)
+
+// Processor 处理退信：关联任务、更新任务状态并将硬退信地址加入屏蔽名单
+type Processor struct {
+	mailbox     Mailbox
+	statuses    status.Store
+	suppression suppression.Store
+	decoder     ReturnPathDecoder
+	emitter     events.Emitter
+	logger      *logger.Logger
+}
+
+// NewProcessor 创建退信处理器
+func NewProcessor(mailbox Mailbox, statuses status.Store) *Processor {
+	return &Processor{
+		mailbox:  mailbox,
+		statuses: statuses,
+		logger:   logger.GetDefault().WithComponent("bounce"),
+	}
+}
+
+// SetSuppressionStore 设置屏蔽名单，硬退信的地址会被加入名单
+func (p *Processor) SetSuppressionStore(store suppression.Store) {
+	p.suppression = store
+}
+
+// SetReturnPathDecoder 设置退信收件地址的解析函数，用于按 VERP 地址关联任务
+func (p *Processor) SetReturnPathDecoder(decoder ReturnPathDecoder) {
+	p.decoder = decoder
+}
+
+// SetEventEmitter 设置事件发送器，永久性退信会发出 bounced 事件
+func (p *Processor) SetEventEmitter(emitter events.Emitter) {
+	p.emitter = emitter
+}
+
+// Run 按间隔轮询退信邮箱，直到 ctx 取消
+func (p *Processor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.mailbox.Poll(ctx, func(raw []byte) error {
+			return p.Process(ctx, raw)
+		}); err != nil {
+			p.logger.Error("Failed to poll bounce mailbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process 处理一封退信邮件
+// 不是退信或无法解析的邮件同样视为已处理；只有写入存储失败时返回错误，使邮件留待下次重试
+func (p *Processor) Process(ctx context.Context, raw []byte) error {
+	report, err := Parse(raw)
+	if errors.Is(err, ErrNotBounce) {
+		p.logger.Debug("Ignoring non-bounce message")
+		return nil
+	}
+	if err != nil {
+		p.logger.Warn("Failed to parse bounce message", "error", err)
+		return nil
+	}
+
+	job := p.correlate(ctx, report)
+	for _, rcpt := range report.Recipients {
+		p.logger.Info("Bounce received",
+			"recipient", rcpt.Address,
+			"action", rcpt.Action,
+			"status", rcpt.Status,
+			"diagnostic", rcpt.Diagnostic,
+			"message_id", report.MessageID,
+			"job_id", jobID(job))
+
+		if !rcpt.Permanent() {
+			continue
+		}
+		matched := job != nil && (len(report.Recipients) == 1 || strings.EqualFold(job.Recipient, rcpt.Address))
+		if matched {
+			if err := p.markBounced(ctx, job, rcpt); err != nil {
+				return err
+			}
+		}
+		if rcpt.HardBounce() {
+			if err := p.suppress(ctx, rcpt); err != nil {
+				return err
+			}
+		}
+		if p.emitter != nil {
+			event := events.Event{
+				Type:       events.TypeBounced,
+				Recipient:  rcpt.Address,
+				MessageID:  report.MessageID,
+				Detail:     describe(rcpt),
+				OccurredAt: time.Now(),
+			}
+			if matched {
+				event.JobID = job.JobID
+			}
+			p.emitter.Emit(ctx, event)
+		}
+	}
+	return nil
+}
+
+// correlate 按原始 Message-ID 或退信收件地址关联任务，无法关联时返回 nil
+func (p *Processor) correlate(ctx context.Context, report *Report) *status.JobStatus {
+	if p.statuses == nil {
+		return nil
+	}
+	if report.MessageID != "" {
+		if st, err := p.statuses.GetByMessageID(ctx, report.MessageID); err == nil {
+			return st
+		}
+	}
+	if p.decoder != nil {
+		for _, addr := range report.ReturnPath {
+			id, ok := p.decoder(addr)
+			if !ok {
+				continue
+			}
+			if st, err := p.statuses.Get(ctx, id); err == nil {
+				return st
+			}
+		}
+	}
+	return nil
+}
+
+// markBounced 将任务状态更新为已退信
+func (p *Processor) markBounced(ctx context.Context, job *status.JobStatus, rcpt Recipient) error {
+	job.State = status.StateBounced
+	job.LastError = describe(rcpt)
+	job.UpdatedAt = time.Now()
+	if err := p.statuses.Save(ctx, job); err != nil {
+		p.logger.Error("Failed to record bounce", "job_id", job.JobID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// suppress 将硬退信地址加入屏蔽名单
+func (p *Processor) suppress(ctx context.Context, rcpt Recipient) error {
+	if p.suppression == nil {
+		return nil
+	}
+	err := p.suppression.Add(ctx, suppression.Entry{
+		Address: rcpt.Address,
+		Reason:  suppression.ReasonHardBounce,
+		Detail:  describe(rcpt),
+	})
+	if err != nil {
+		p.logger.Error("Failed to suppress bounced address", "recipient", rcpt.Address, "error", err)
+		return err
+	}
+	p.logger.Info("Address suppressed after bounce", "recipient", rcpt.Address)
+	return nil
+}
+
+// describe 返回退信原因描述
+func describe(rcpt Recipient) string {
+	if rcpt.Diagnostic != "" {
+		return rcpt.Diagnostic
+	}
+	if rcpt.Status != "" {
+		return "bounced with status " + rcpt.Status
+	}
+	return "bounced"
+}
+
+func jobID(job *status.JobStatus) string {
+	if job == nil {
+		return ""
+	}
+	return job.JobID
+}
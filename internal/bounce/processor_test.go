@@ -0,0 +1,414 @@
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/internal/events"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+)
+
+// fakeMailbox 保存待处理的邮件，handle 成功的邮件被移除
+type fakeMailbox struct {
+	mu       sync.Mutex
+	messages [][]byte
+	polls    int
+}
+
+func (m *fakeMailbox) Poll(ctx context.Context, handle func(raw []byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.polls++
+	var remaining [][]byte
+	for _, raw := range m.messages {
+		if handle(raw) != nil {
+			remaining = append(remaining, raw)
+		}
+	}
+	m.messages = remaining
+	return nil
+}
+
+func (m *fakeMailbox) pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+// recordingEmitter 记录发出的事件
+type recordingEmitter struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (e *recordingEmitter) Emit(ctx context.Context, event events.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+// failingStatusStore 保存状态失败的存储
+type failingStatusStore struct {
+	status.Store
+}
+
+func (s failingStatusStore) Save(ctx context.Context, st *status.JobStatus) error {
+	return errors.New("store unavailable")
+}
+
+// bounceFor 生成指定收件人和状态码的 RFC 3464 退信
+func bounceFor(address, statusCode, messageID, returnPath string) []byte {
+	return crlf(`From: MAILER-DAEMON@mx.example.net
+To: ` + returnPath + `
+Subject: Undelivered Mail Returned to Sender
+Content-Type: multipart/report; report-type=delivery-status; boundary=b1
+
+--b1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+Final-Recipient: rfc822; ` + address + `
+Action: failed
+Status: ` + statusCode + `
+Diagnostic-Code: smtp; 550 ` + statusCode + ` rejected
+
+--b1
+Content-Type: text/rfc822-headers
+
+Message-ID: ` + messageID + `
+--b1--
+`)
+}
+
+// newTestProcessor 创建带有一个已发送任务的处理器
+func newTestProcessor(t *testing.T, mailbox Mailbox, jobs ...*status.JobStatus) (*Processor, *status.MemoryStore, *suppression.MemoryStore, *recordingEmitter) {
+	t.Helper()
+	statuses := status.NewMemoryStore(status.DefaultConfig())
+	for _, job := range jobs {
+		if err := statuses.Save(context.Background(), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	suppressions := suppression.NewMemoryStore()
+	emitter := &recordingEmitter{}
+	p := NewProcessor(mailbox, statuses)
+	p.SetSuppressionStore(suppressions)
+	p.SetEventEmitter(emitter)
+	return p, statuses, suppressions, emitter
+}
+
+func sentJob(id, recipient, messageID string) *status.JobStatus {
+	return &status.JobStatus{JobID: id, Recipient: recipient, State: status.StateSent, MessageID: messageID}
+}
+
+func TestProcessHardBounce(t *testing.T) {
+	ctx := context.Background()
+	p, statuses, suppressions, emitter := newTestProcessor(t, nil, sentJob("job-1", "alice@example.org", "<m1@example.com>"))
+
+	if err := p.Process(ctx, bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com")); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := statuses.Get(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != status.StateBounced || !strings.Contains(st.LastError, "5.1.1") {
+		t.Errorf("status = %+v", st)
+	}
+	entry, err := suppressions.Check(ctx, "alice@example.org", "")
+	if err != nil || entry == nil || entry.Reason != suppression.ReasonHardBounce {
+		t.Errorf("suppression entry = %+v, %v", entry, err)
+	}
+	if len(emitter.events) != 1 || emitter.events[0].Type != events.TypeBounced || emitter.events[0].JobID != "job-1" {
+		t.Errorf("events = %+v", emitter.events)
+	}
+}
+
+func TestProcessSoftBounceDoesNotSuppress(t *testing.T) {
+	ctx := context.Background()
+	p, statuses, suppressions, _ := newTestProcessor(t, nil, sentJob("job-1", "alice@example.org", "<m1@example.com>"))
+
+	// 内容被拒绝是永久失败，但不是地址本身的问题
+	if err := p.Process(ctx, bounceFor("alice@example.org", "5.7.1", "<m1@example.com>", "sender@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := statuses.Get(ctx, "job-1"); st.State != status.StateBounced {
+		t.Errorf("state = %s, want bounced", st.State)
+	}
+	if entry, _ := suppressions.Check(ctx, "alice@example.org", ""); entry != nil {
+		t.Errorf("soft bounce suppressed address: %+v", entry)
+	}
+}
+
+func TestProcessDelayedIsIgnored(t *testing.T) {
+	ctx := context.Background()
+	p, statuses, suppressions, emitter := newTestProcessor(t, nil, sentJob("job-1", "alice@example.org", "<m1@example.com>"))
+
+	raw := bytes.Replace(bounceFor("alice@example.org", "4.4.1", "<m1@example.com>", "sender@example.com"),
+		[]byte("Action: failed"), []byte("Action: delayed"), 1)
+	if err := p.Process(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := statuses.Get(ctx, "job-1"); st.State != status.StateSent {
+		t.Errorf("state = %s, want sent", st.State)
+	}
+	if entry, _ := suppressions.Check(ctx, "alice@example.org", ""); entry != nil {
+		t.Errorf("delayed bounce suppressed address: %+v", entry)
+	}
+	if len(emitter.events) != 0 {
+		t.Errorf("events = %+v", emitter.events)
+	}
+}
+
+func TestProcessCorrelatesByReturnPath(t *testing.T) {
+	ctx := context.Background()
+	p, statuses, _, _ := newTestProcessor(t, nil, sentJob("0123456789abcdef", "alice@example.org", ""))
+	p.SetReturnPathDecoder(func(address string) (string, bool) {
+		local, _, _ := strings.Cut(address, "@")
+		id, ok := strings.CutPrefix(local, "bounces+")
+		return id, ok
+	})
+
+	// 退信中的 Message-ID 无法关联时按 VERP 地址关联
+	raw := bounceFor("alice@example.org", "5.1.1", "<unknown@example.com>", "bounces+0123456789abcdef@example.com")
+	if err := p.Process(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := statuses.Get(ctx, "0123456789abcdef"); st.State != status.StateBounced {
+		t.Errorf("state = %s, want bounced", st.State)
+	}
+}
+
+func TestProcessUnmatchedStillSuppresses(t *testing.T) {
+	ctx := context.Background()
+	p, _, suppressions, emitter := newTestProcessor(t, nil)
+
+	if err := p.Process(ctx, bounceFor("zoe@example.org", "5.1.1", "<unknown@example.com>", "sender@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := suppressions.Check(ctx, "zoe@example.org", ""); entry == nil {
+		t.Error("hard bounce without matching job not suppressed")
+	}
+	if len(emitter.events) != 1 || emitter.events[0].JobID != "" {
+		t.Errorf("events = %+v", emitter.events)
+	}
+}
+
+func TestRunPollsAndKeepsFailedMessages(t *testing.T) {
+	mailbox := &fakeMailbox{messages: [][]byte{
+		bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com"),
+		crlf("From: alice@example.org\nSubject: hi\n\nnot a bounce\n"),
+		[]byte("garbage without header separator"),
+	}}
+	p, statuses, _, _ := newTestProcessor(t, mailbox, sentJob("job-1", "alice@example.org", "<m1@example.com>"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, time.Hour)
+		close(done)
+	}()
+	waitFor(t, func() bool { return mailbox.pending() == 0 })
+	cancel()
+	<-done
+
+	if st, _ := statuses.Get(context.Background(), "job-1"); st.State != status.StateBounced {
+		t.Errorf("state = %s, want bounced", st.State)
+	}
+
+	// 写入状态失败的退信留在邮箱中等待下次轮询
+	failing := &fakeMailbox{messages: [][]byte{
+		bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com"),
+	}}
+	p, statuses, _, _ = newTestProcessor(t, failing, sentJob("job-1", "alice@example.org", "<m1@example.com>"))
+	p.statuses = failingStatusStore{Store: statuses}
+	if err := failing.Poll(context.Background(), func(raw []byte) error { return p.Process(context.Background(), raw) }); err != nil {
+		t.Fatal(err)
+	}
+	if failing.pending() != 1 {
+		t.Errorf("pending = %d, want the bounce to stay in the mailbox", failing.pending())
+	}
+}
+
+// waitFor 等待条件成立，最长 5 秒
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startIMAP 启动进程内 IMAP 服务器，收件箱中放入 messages，返回地址和收件箱
+func startIMAP(t *testing.T, messages ...[]byte) (string, *memory.Mailbox) {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbox := mbox.(*memory.Mailbox)
+	for _, raw := range messages {
+		if err := inbox.CreateMessage(nil, time.Now(), bytes.NewBuffer(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := server.New(be)
+	srv.AllowInsecureAuth = true
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { _ = srv.Close() })
+	return l.Addr().String(), inbox
+}
+
+// flagged 返回带有指定标记的邮件数量
+func flagged(inbox *memory.Mailbox, flag string) int {
+	n := 0
+	for _, msg := range inbox.Messages {
+		for _, f := range msg.Flags {
+			if f == flag {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func TestIMAPMailboxPoll(t *testing.T) {
+	bounce := bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com")
+	retry := bounceFor("bob@example.org", "5.1.1", "<m2@example.com>", "sender@example.com")
+	addr, inbox := startIMAP(t, bounce, retry)
+	// memory 后端自带一封已读邮件
+	seenBefore := flagged(inbox, imap.SeenFlag)
+
+	mailbox, err := NewMailbox(&Config{Protocol: ProtocolIMAP, Addr: addr, Username: "username", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []string
+	handle := func(raw []byte) error {
+		report, err := Parse(raw)
+		if err != nil {
+			t.Errorf("unexpected message: %v", err)
+			return nil
+		}
+		handled = append(handled, report.Recipients[0].Address)
+		if report.Recipients[0].Address == "bob@example.org" {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}
+	if err := mailbox.Poll(context.Background(), handle); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(handled, ",") != "alice@example.org,bob@example.org" {
+		t.Errorf("handled = %v", handled)
+	}
+	if got := flagged(inbox, imap.SeenFlag) - seenBefore; got != 1 {
+		t.Errorf("%d messages marked seen, want 1", got)
+	}
+
+	// 处理失败的邮件在下次轮询时重新处理
+	handled = nil
+	if err := mailbox.Poll(context.Background(), handle); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(handled, ",") != "bob@example.org" {
+		t.Errorf("second poll handled %v, want only the failed message", handled)
+	}
+}
+
+func TestIMAPMailboxDeleteProcessed(t *testing.T) {
+	addr, inbox := startIMAP(t, bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com"))
+	before := len(inbox.Messages)
+
+	mailbox, err := NewMailbox(&Config{Addr: addr, Username: "username", Password: "password", DeleteProcessed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailbox.Poll(context.Background(), func(raw []byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox.Messages) != before-1 {
+		t.Errorf("inbox has %d messages, want %d", len(inbox.Messages), before-1)
+	}
+}
+
+func TestIMAPMailboxLoginFailure(t *testing.T) {
+	addr, _ := startIMAP(t)
+	mailbox, err := NewMailbox(&Config{Addr: addr, Username: "username", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailbox.Poll(context.Background(), func(raw []byte) error { return nil }); err == nil {
+		t.Error("expected login error")
+	}
+}
+
+func TestProcessorWithIMAPMailbox(t *testing.T) {
+	addr, inbox := startIMAP(t,
+		bounceFor("alice@example.org", "5.1.1", "<m1@example.com>", "sender@example.com"),
+		bounceFor("bob@example.org", "5.7.1", "<m2@example.com>", "sender@example.com"),
+	)
+	mailbox, err := NewMailbox(&Config{Addr: addr, Username: "username", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, statuses, suppressions, _ := newTestProcessor(t, mailbox,
+		sentJob("job-1", "alice@example.org", "<m1@example.com>"),
+		sentJob("job-2", "bob@example.org", "<m2@example.com>"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, time.Hour)
+		close(done)
+	}()
+	waitFor(t, func() bool {
+		st, _ := statuses.Get(context.Background(), "job-2")
+		return st.State == status.StateBounced
+	})
+	cancel()
+	<-done
+
+	for _, id := range []string{"job-1", "job-2"} {
+		if st, _ := statuses.Get(context.Background(), id); st.State != status.StateBounced {
+			t.Errorf("%s state = %s, want bounced", id, st.State)
+		}
+	}
+	if entry, _ := suppressions.Check(context.Background(), "alice@example.org", ""); entry == nil {
+		t.Error("hard bounced address not suppressed")
+	}
+	if entry, _ := suppressions.Check(context.Background(), "bob@example.org", ""); entry != nil {
+		t.Error("soft bounced address suppressed")
+	}
+	if got := flagged(inbox, imap.SeenFlag); got != len(inbox.Messages) {
+		t.Errorf("%d of %d messages marked seen", got, len(inbox.Messages))
+	}
+}
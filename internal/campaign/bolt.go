@@ -0,0 +1,242 @@
+package campaign
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketCampaigns = []byte("campaigns") // ID -> Campaign，包括进度
+	bucketRows      = []byte("rows")      // ID -> 子桶（序号 -> Row）
+	bucketStates    = []byte("states")    // ID -> 子桶（序号 -> RowState）
+)
+
+// BoltStore 基于 bbolt 的活动存储，只能由单个实例打开
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开或创建 bolt 活动存储
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open campaign store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketCampaigns, bucketRows, bucketStates} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// rowKey 将序号编码为按顺序排列的键
+func rowKey(index int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(index))
+	return key
+}
+
+// Create 在一个事务中保存活动及其收件人
+func (s *BoltStore) Create(ctx context.Context, c *Campaign, rows []Row) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rowBucket, err := tx.Bucket(bucketRows).CreateBucket([]byte(c.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Bucket(bucketStates).CreateBucket([]byte(c.ID)); err != nil {
+			return err
+		}
+		// 序号递增写入，填满页面以减小文件体积
+		rowBucket.FillPercent = 1
+		for _, row := range rows {
+			value, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if err := rowBucket.Put(rowKey(row.Index), value); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketCampaigns).Put([]byte(c.ID), data)
+	})
+}
+
+// Get 按ID查询活动
+func (s *BoltStore) Get(ctx context.Context, id string) (*Campaign, error) {
+	var c *Campaign
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		c, err = getCampaign(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// getCampaign 在事务中读取活动
+func getCampaign(tx *bolt.Tx, id string) (*Campaign, error) {
+	data := tx.Bucket(bucketCampaigns).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	c := &Campaign{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	c.Progress.fill(c.Total)
+	return c, nil
+}
+
+// putCampaign 在事务中保存活动
+func putCampaign(tx *bolt.Tx, c *Campaign) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketCampaigns).Put([]byte(c.ID), data)
+}
+
+// List 按创建时间顺序返回全部活动
+func (s *BoltStore) List(ctx context.Context) ([]*Campaign, error) {
+	var campaigns []*Campaign
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketCampaigns).ForEach(func(_, data []byte) error {
+			c := &Campaign{}
+			if err := json.Unmarshal(data, c); err != nil {
+				return err
+			}
+			c.Progress.fill(c.Total)
+			campaigns = append(campaigns, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortCampaigns(campaigns)
+	return campaigns, nil
+}
+
+// Update 在一个事务中读取并修改活动
+func (s *BoltStore) Update(ctx context.Context, id string, fn func(*Campaign) error) (*Campaign, error) {
+	var c *Campaign
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if c, err = getCampaign(tx, id); err != nil {
+			return err
+		}
+		progress := c.Progress
+		if err := fn(c); err != nil {
+			return err
+		}
+		c.Progress = progress
+		return putCampaign(tx, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Rows 返回从序号 from 开始的至多 limit 个收件人
+func (s *BoltStore) Rows(ctx context.Context, id string, from, limit int) ([]Row, error) {
+	var rows []Row
+	err := s.db.View(func(tx *bolt.Tx) error {
+		rowBucket := tx.Bucket(bucketRows).Bucket([]byte(id))
+		states := tx.Bucket(bucketStates).Bucket([]byte(id))
+		if rowBucket == nil || states == nil {
+			return ErrNotFound
+		}
+		cur := rowBucket.Cursor()
+		for k, v := cur.Seek(rowKey(from)); k != nil && len(rows) < limit; k, v = cur.Next() {
+			var row Row
+			if err := json.Unmarshal(v, &row); err != nil {
+				return err
+			}
+			row.State = RowState(states.Get(k))
+			rows = append(rows, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SetRowStates 推进收件人的状态并更新进度
+// 多个工人并发更新时合并到同一个事务中提交
+func (s *BoltStore) SetRowStates(ctx context.Context, id string, states map[int]RowState) error {
+	// 普通任务的状态也会经过这里，先用只读事务排除不存在的活动
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		c, err := getCampaign(tx, id)
+		if err != nil {
+			return err
+		}
+		bucket := tx.Bucket(bucketStates).Bucket([]byte(id))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		changed := false
+		for index, state := range states {
+			if index < 0 || index >= c.Total {
+				continue
+			}
+			key := rowKey(index)
+			old := RowState(bucket.Get(key))
+			if old != "" && rowRank[old] >= rowRank[state] {
+				continue
+			}
+			if err := bucket.Put(key, []byte(state)); err != nil {
+				return err
+			}
+			c.Progress.add(old, -1)
+			c.Progress.add(state, 1)
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		return putCampaign(tx, c)
+	})
+}
+
+// Close 关闭数据库
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// sortCampaigns 按创建时间排序
+func sortCampaigns(campaigns []*Campaign) {
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.Before(campaigns[j].CreatedAt)
+	})
+}
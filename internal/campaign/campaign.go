@@ -0,0 +1,224 @@
+// Package campaign 管理批量发送活动：上传收件人列表后由后台分批入队，并按收件人统计投递进度
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"email-service/pkg/jobqueue"
+)
+
+var (
+	// ErrNotFound 活动不存在
+	ErrNotFound = errors.New("campaign not found")
+
+	// ErrInvalidState 活动当前的状态不允许该操作
+	ErrInvalidState = errors.New("invalid campaign state")
+)
+
+// State 活动状态
+type State string
+
+const (
+	StateScheduled State = "scheduled" // 等待开始时间
+	StateSending   State = "sending"   // 正在分批入队
+	StatePaused    State = "paused"    // 已暂停，已入队但未发送的任务也会推迟
+	StateCompleted State = "completed" // 全部收件人已处理，投递仍可能在进行
+	StateCancelled State = "cancelled" // 已取消，未发送的任务被丢弃
+)
+
+// RowState 单个收件人的状态，为空表示尚未入队
+type RowState string
+
+const (
+	RowQueued     RowState = "queued"     // 已入队或等待重试
+	RowSent       RowState = "sent"       // SMTP 服务器已接收
+	RowFailed     RowState = "failed"     // 永久失败
+	RowBounced    RowState = "bounced"    // 发送后收到退信
+	RowComplained RowState = "complained" // 收件人投诉为垃圾邮件
+	RowSkipped    RowState = "skipped"    // 在屏蔽名单中，未入队
+	RowCancelled  RowState = "cancelled"  // 活动取消时尚未发送
+)
+
+// rowRank 收件人状态只能向后推进，避免晚到的状态覆盖最终结果
+// redis 存储的 setRowStateScript 使用相同的顺序
+var rowRank = map[RowState]int{
+	RowQueued:     1,
+	RowSent:       2,
+	RowFailed:     2,
+	RowSkipped:    2,
+	RowCancelled:  2,
+	RowBounced:    3,
+	RowComplained: 3,
+}
+
+// Progress 按收件人状态统计的进度
+type Progress struct {
+	Pending    int `json:"pending"` // 尚未入队
+	Queued     int `json:"queued"`  // 已入队，等待发送或重试
+	Sent       int `json:"sent"`
+	Failed     int `json:"failed"`
+	Bounced    int `json:"bounced"`
+	Complained int `json:"complained"`
+	Skipped    int `json:"skipped"`
+	Cancelled  int `json:"cancelled"`
+}
+
+// add 调整某个状态的计数
+func (p *Progress) add(state RowState, delta int) {
+	switch state {
+	case RowQueued:
+		p.Queued += delta
+	case RowSent:
+		p.Sent += delta
+	case RowFailed:
+		p.Failed += delta
+	case RowBounced:
+		p.Bounced += delta
+	case RowComplained:
+		p.Complained += delta
+	case RowSkipped:
+		p.Skipped += delta
+	case RowCancelled:
+		p.Cancelled += delta
+	}
+}
+
+// fill 根据收件人总数计算尚未入队的数量
+func (p *Progress) fill(total int) {
+	p.Pending = total - p.Queued - p.Sent - p.Failed - p.Bounced - p.Complained - p.Skipped - p.Cancelled
+}
+
+// Campaign 批量发送活动
+type Campaign struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Subject      string         `json:"subject"`
+	Category     string         `json:"category,omitempty"`
+	Priority     string         `json:"priority,omitempty"`
+	TemplateID   string         `json:"template_id"`
+	TemplateData map[string]any `json:"template_data,omitempty"` // 所有收件人共用的模板数据，收件人的数据覆盖同名字段
+	SendAt       time.Time      `json:"send_at"`                 // 开始入队的时间
+	State        State          `json:"state"`
+	Total        int            `json:"total"`      // 收件人数量
+	Duplicates   int            `json:"duplicates"` // 上传时忽略的重复收件人数量
+	Cursor       int            `json:"cursor"`     // 下一个待入队的收件人序号
+	Progress     Progress       `json:"progress"`
+	LastError    string         `json:"last_error,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	StartedAt    *time.Time     `json:"started_at,omitempty"`
+	CompletedAt  *time.Time     `json:"completed_at,omitempty"`
+}
+
+// Validate 校验活动的配置
+func (c *Campaign) Validate() error {
+	if c.Subject == "" {
+		return errors.New("subject is required")
+	}
+	if !jobqueue.ValidPriority(c.Priority) {
+		return fmt.Errorf("unsupported priority: %s", c.Priority)
+	}
+	return nil
+}
+
+// Row 收件人列表中的一行
+type Row struct {
+	Index    int            `json:"index"`
+	Email    string         `json:"email"`
+	Timezone string         `json:"timezone,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	State    RowState       `json:"-"` // 读取时由存储填充
+}
+
+// Store 定义活动存储接口
+type Store interface {
+	// Create 保存新的活动及其收件人
+	Create(ctx context.Context, c *Campaign, rows []Row) error
+
+	// Get 按ID查询活动，包括进度
+	Get(ctx context.Context, id string) (*Campaign, error)
+
+	// List 按创建时间顺序返回全部活动
+	List(ctx context.Context) ([]*Campaign, error)
+
+	// Update 原子地读取并修改活动，fn 返回错误时放弃修改并返回该错误；进度由存储维护，fn 的修改被忽略
+	Update(ctx context.Context, id string, fn func(*Campaign) error) (*Campaign, error)
+
+	// Rows 返回从序号 from 开始的至多 limit 个收件人及其状态
+	Rows(ctx context.Context, id string, from, limit int) ([]Row, error)
+
+	// SetRowStates 按序号推进收件人的状态并更新进度，不会推进到更早的状态
+	SetRowStates(ctx context.Context, id string, states map[int]RowState) error
+
+	// Close 关闭存储
+	Close() error
+}
+
+// Config 活动配置
+type Config struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Store         string        `mapstructure:"store"` // bolt 或 redis，多实例部署时使用 redis
+	Path          string        `mapstructure:"path"`  // bolt 类型的数据文件
+	Redis         *RedisConfig  `mapstructure:"redis"`
+	Interval      time.Duration `mapstructure:"interval"`        // 检查待入队活动的间隔
+	BatchSize     int           `mapstructure:"batch_size"`      // 每批入队的收件人数量
+	LeaderTTL     time.Duration `mapstructure:"leader_ttl"`      // 主节点租约时长，多实例时只有主节点入队
+	PausedDelay   time.Duration `mapstructure:"paused_delay"`    // 活动暂停时已入队的任务推迟的时长
+	MaxRows       int           `mapstructure:"max_rows"`        // 单个活动的收件人上限
+	MaxUploadSize int64         `mapstructure:"max_upload_size"` // 收件人列表文件的最大字节数
+}
+
+// RedisConfig Redis 活动存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	StoreBolt  = "bolt"
+	StoreRedis = "redis"
+)
+
+// DefaultConfig 返回默认活动配置，默认不启用
+func DefaultConfig() *Config {
+	return &Config{
+		Store:         StoreBolt,
+		Path:          "data/campaigns.db",
+		Interval:      5 * time.Second,
+		BatchSize:     500,
+		LeaderTTL:     30 * time.Second,
+		PausedDelay:   time.Minute,
+		MaxRows:       1000000,
+		MaxUploadSize: 128 << 20,
+	}
+}
+
+// newCampaignID 生成活动ID，长度为任务ID的一半，剩余部分用于收件人序号
+func newCampaignID() string {
+	return jobqueue.NewJobID()[:16]
+}
+
+// JobID 返回活动中第 index 个收件人的任务ID，重复入队时ID不变
+func JobID(id string, index int) string {
+	return fmt.Sprintf("%s%016x", id, index)
+}
+
+// ParseJobID 从任务ID解析活动ID和收件人序号，格式不符时返回 false
+// 普通任务的ID同样可以解析，调用方需确认活动存在
+func ParseJobID(jobID string) (string, int, bool) {
+	if len(jobID) != 32 {
+		return "", 0, false
+	}
+	index, err := strconv.ParseUint(jobID[16:], 16, 31)
+	if err != nil {
+		return "", 0, false
+	}
+	return jobID[:16], int(index), true
+}
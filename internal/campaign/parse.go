@@ -0,0 +1,225 @@
+package campaign
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ErrInvalidList 收件人列表格式错误
+var ErrInvalidList = errors.New("invalid recipient list")
+
+// 收件人列表格式
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// 收件人列表中有特殊含义的列，其余列作为模板数据
+const (
+	columnEmail    = "email"
+	columnTimezone = "timezone"
+)
+
+// ParseResult 解析后的收件人列表
+type ParseResult struct {
+	Rows       []Row
+	Duplicates int // 仅大小写不同的重复地址只保留第一行
+}
+
+// ParseRecipients 解析 CSV 或 NDJSON 格式的收件人列表，format 为空时按内容判断
+// CSV 第一行为表头，必须包含 email 列；NDJSON 每行一个 JSON 对象，必须包含 email 字段
+// timezone 列为收件人时区，其余列作为该收件人的模板数据，CSV 中的空单元格被忽略
+func ParseRecipients(r io.Reader, format string, maxRows int) (*ParseResult, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = detectFormat(br)
+	}
+
+	p := &parser{
+		result:    &ParseResult{},
+		seen:      make(map[string]bool),
+		timezones: make(map[string]bool),
+		maxRows:   maxRows,
+	}
+	var err error
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		err = p.parseCSV(br)
+	case FormatNDJSON, "jsonl":
+		err = p.parseNDJSON(br)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidList, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(p.result.Rows) == 0 {
+		return nil, fmt.Errorf("%w: no recipients", ErrInvalidList)
+	}
+	return p.result, nil
+}
+
+// detectFormat 第一个非空白字符为 { 时视为 NDJSON，否则为 CSV
+func detectFormat(br *bufio.Reader) string {
+	for n := 1; ; n++ {
+		peek, err := br.Peek(n)
+		if len(peek) < n {
+			return FormatCSV
+		}
+		switch peek[n-1] {
+		case ' ', '\t', '\r', '\n':
+			if err != nil {
+				return FormatCSV
+			}
+			continue
+		case '{':
+			return FormatNDJSON
+		default:
+			return FormatCSV
+		}
+	}
+}
+
+type parser struct {
+	result    *ParseResult
+	seen      map[string]bool
+	timezones map[string]bool // 已校验的时区
+	maxRows   int
+}
+
+// parseCSV 解析带表头的 CSV
+func (p *parser) parseCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidList, err)
+	}
+	emailCol := -1
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // 表格软件导出的 BOM
+		}
+		header[i] = strings.TrimSpace(name)
+		if strings.EqualFold(header[i], columnEmail) {
+			emailCol = i
+		}
+	}
+	if emailCol < 0 {
+		return fmt.Errorf("%w: missing %s column", ErrInvalidList, columnEmail)
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidList, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if emailCol >= len(record) {
+			return fmt.Errorf("%w: line %d: missing %s", ErrInvalidList, line, columnEmail)
+		}
+
+		var email, timezone string
+		data := make(map[string]any)
+		for i, value := range record {
+			if i >= len(header) || value == "" {
+				continue
+			}
+			switch {
+			case i == emailCol:
+				email = value
+			case strings.EqualFold(header[i], columnTimezone):
+				timezone = strings.TrimSpace(value)
+			default:
+				data[header[i]] = value
+			}
+		}
+		if err := p.add(line, email, timezone, data); err != nil {
+			return err
+		}
+	}
+}
+
+// parseNDJSON 解析每行一个 JSON 对象的列表
+func (p *parser) parseNDJSON(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(text, &obj); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidList, line, err)
+		}
+
+		email, _ := obj[columnEmail].(string)
+		timezone, _ := obj[columnTimezone].(string)
+		delete(obj, columnEmail)
+		delete(obj, columnTimezone)
+		if err := p.add(line, email, timezone, obj); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidList, err)
+	}
+	return nil
+}
+
+// add 校验并追加一行，重复的地址被忽略
+func (p *parser) add(line int, email, timezone string, data map[string]any) error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return fmt.Errorf("%w: line %d: invalid address %q", ErrInvalidList, line, email)
+	}
+	if timezone != "" && !p.timezones[timezone] {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("%w: line %d: invalid timezone %q", ErrInvalidList, line, timezone)
+		}
+		p.timezones[timezone] = true
+	}
+
+	key := strings.ToLower(addr.Address)
+	if p.seen[key] {
+		p.result.Duplicates++
+		return nil
+	}
+	if p.maxRows > 0 && len(p.result.Rows) >= p.maxRows {
+		return fmt.Errorf("%w: more than %d recipients", ErrInvalidList, p.maxRows)
+	}
+	p.seen[key] = true
+
+	if len(data) == 0 {
+		data = nil
+	}
+	p.result.Rows = append(p.result.Rows, Row{
+		Index:    len(p.result.Rows),
+		Email:    addr.Address,
+		Timezone: timezone,
+		Data:     data,
+	})
+	return nil
+}
@@ -0,0 +1,278 @@
+package campaign
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// maxUpdateAttempts 并发修改同一活动时的最大重试次数
+	maxUpdateAttempts = 10
+
+	// createChunkSize 创建活动时每次写入的收件人数量
+	createChunkSize = 1000
+)
+
+// setRowStateScript 状态推进时更新收件人状态和进度计数，排序与 rowRank 一致
+// KEYS[1] 活动；KEYS[2] 收件人状态哈希；KEYS[3] 进度计数哈希
+// ARGV: 收件人总数、成对的序号和状态
+var setRowStateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local rank = {queued=1, sent=2, failed=2, skipped=2, cancelled=2, bounced=3, complained=3}
+local total = tonumber(ARGV[1])
+for i = 2, #ARGV, 2 do
+	local index, state = ARGV[i], ARGV[i+1]
+	if tonumber(index) < total then
+		local old = redis.call('HGET', KEYS[2], index)
+		if not old or rank[old] < rank[state] then
+			redis.call('HSET', KEYS[2], index, state)
+			if old then
+				redis.call('HINCRBY', KEYS[3], old, -1)
+			end
+			redis.call('HINCRBY', KEYS[3], state, 1)
+		end
+	end
+end
+return 1
+`)
+
+// RedisStore 基于 Redis 的活动存储，多个实例共享
+// 活动保存为 JSON 字符串，收件人保存为列表，收件人状态和进度计数分别保存为哈希
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 活动存储
+func NewRedisStore(config *RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:campaign:"
+	}
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+func (s *RedisStore) key(id string) string       { return s.prefix + "item:" + id }
+func (s *RedisStore) rowsKey(id string) string   { return s.prefix + "rows:" + id }
+func (s *RedisStore) statesKey(id string) string { return s.prefix + "states:" + id }
+func (s *RedisStore) countsKey(id string) string { return s.prefix + "counts:" + id }
+
+// Create 先写入收件人，最后写入活动，活动可见时收件人已完整
+func (s *RedisStore) Create(ctx context.Context, c *Campaign, rows []Row) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(rows); start += createChunkSize {
+		end := min(start+createChunkSize, len(rows))
+		values := make([]any, 0, end-start)
+		for _, row := range rows[start:end] {
+			value, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		if err := s.client.RPush(ctx, s.rowsKey(c.ID), values...).Err(); err != nil {
+			s.client.Del(context.Background(), s.rowsKey(c.ID))
+			return err
+		}
+	}
+	_, err = s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, s.key(c.ID), data, 0)
+		p.SAdd(ctx, s.prefix+"ids", c.ID)
+		return nil
+	})
+	return err
+}
+
+// Get 按ID查询活动
+func (s *RedisStore) Get(ctx context.Context, id string) (*Campaign, error) {
+	var (
+		item   *redis.StringCmd
+		counts *redis.MapStringStringCmd
+	)
+	_, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		item = p.Get(ctx, s.key(id))
+		counts = p.HGetAll(ctx, s.countsKey(id))
+		return nil
+	})
+	if errors.Is(item.Err(), redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeCampaign(item.Val(), counts.Val())
+}
+
+// decodeCampaign 解析活动并填充进度
+func decodeCampaign(data string, counts map[string]string) (*Campaign, error) {
+	c := &Campaign{}
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		return nil, err
+	}
+	c.Progress = Progress{}
+	for state, v := range counts {
+		n, _ := strconv.Atoi(v)
+		c.Progress.add(RowState(state), n)
+	}
+	c.Progress.fill(c.Total)
+	return c, nil
+}
+
+// List 按创建时间顺序返回全部活动
+func (s *RedisStore) List(ctx context.Context) ([]*Campaign, error) {
+	ids, err := s.client.SMembers(ctx, s.prefix+"ids").Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	items := make([]*redis.StringCmd, len(ids))
+	counts := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			items[i] = p.Get(ctx, s.key(id))
+			counts[i] = p.HGetAll(ctx, s.countsKey(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	campaigns := make([]*Campaign, 0, len(ids))
+	for i := range ids {
+		if items[i].Err() != nil {
+			continue
+		}
+		c, err := decodeCampaign(items[i].Val(), counts[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	sortCampaigns(campaigns)
+	return campaigns, nil
+}
+
+// Update 通过 WATCH 乐观锁读取并修改活动，并发修改时重试
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(*Campaign) error) (*Campaign, error) {
+	key := s.key(id)
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var c *Campaign
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Result()
+			if errors.Is(err, redis.Nil) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			counts, err := tx.HGetAll(ctx, s.countsKey(id)).Result()
+			if err != nil {
+				return err
+			}
+			if c, err = decodeCampaign(data, counts); err != nil {
+				return err
+			}
+			progress := c.Progress
+			if err := fn(c); err != nil {
+				return err
+			}
+			c.Progress = progress
+			value, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Set(ctx, key, value, 0)
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, redis.TxFailedErr
+}
+
+// Rows 返回从序号 from 开始的至多 limit 个收件人
+func (s *RedisStore) Rows(ctx context.Context, id string, from, limit int) ([]Row, error) {
+	values, err := s.client.LRange(ctx, s.rowsKey(id), int64(from), int64(from+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	fields := make([]string, len(values))
+	for i := range values {
+		fields[i] = strconv.Itoa(from + i)
+	}
+	states, err := s.client.HMGet(ctx, s.statesKey(id), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &rows[i]); err != nil {
+			return nil, err
+		}
+		if state, ok := states[i].(string); ok {
+			rows[i].State = RowState(state)
+		}
+	}
+	return rows, nil
+}
+
+// SetRowStates 通过脚本原子地推进收件人的状态并更新进度
+func (s *RedisStore) SetRowStates(ctx context.Context, id string, states map[int]RowState) error {
+	c, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	args := make([]any, 0, 1+2*len(states))
+	args = append(args, c.Total)
+	for index, state := range states {
+		args = append(args, index, string(state))
+	}
+	keys := []string{s.key(id), s.statesKey(id), s.countsKey(id)}
+	n, err := setRowStateScript.Run(ctx, s.client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,545 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/schedule"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+	"email-service/pkg/jobqueue"
+)
+
+// stateCacheTTL 工人查询活动状态的缓存时长，暂停和取消最迟在该时长后对已入队的任务生效
+const stateCacheTTL = 5 * time.Second
+
+// errStale 活动已被其他操作修改，放弃本次修改
+var errStale = errors.New("campaign changed")
+
+// JobPusher 接收活动生成的邮件任务，由 mailer.Dispatcher 实现
+type JobPusher interface {
+	PushJob(job jobqueue.EmailJob) error
+}
+
+// cachedState 缓存的活动状态
+type cachedState struct {
+	state State
+	at    time.Time
+}
+
+// Service 管理活动，并由主节点在后台分批入队
+type Service struct {
+	store       Store
+	elector     schedule.Elector // 为空时始终为主节点
+	pusher      JobPusher
+	statuses    status.Store
+	suppression suppression.Store
+	config      *Config
+	logger      *logger.Logger
+
+	mu     sync.Mutex
+	states map[string]cachedState
+}
+
+// New 根据配置创建活动服务；redis 存储同时用于选主
+func New(cfg *Config, pusher JobPusher) (*Service, error) {
+	s := &Service{
+		pusher: pusher,
+		config: cfg,
+		logger: logger.GetDefault().WithComponent("campaign"),
+		states: make(map[string]cachedState),
+	}
+	switch cfg.Store {
+	case StoreBolt, "":
+		path := cfg.Path
+		if path == "" {
+			path = DefaultConfig().Path
+		}
+		store, err := NewBoltStore(path)
+		if err != nil {
+			return nil, err
+		}
+		s.store = store
+	case StoreRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("campaign: redis config is required")
+		}
+		store, err := NewRedisStore(cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+		s.store = store
+		s.elector = schedule.NewRedisElector(store.client, store.prefix+"leader", s.leaderTTL())
+	default:
+		return nil, fmt.Errorf("campaign: unsupported store type %q", cfg.Store)
+	}
+	return s, nil
+}
+
+// SetStatusStore 设置任务状态存储，用于在恢复入队时跳过已入队的收件人，需在 Run 之前调用
+func (s *Service) SetStatusStore(store status.Store) {
+	s.statuses = store
+}
+
+// SetSuppressionStore 设置屏蔽名单，被屏蔽的收件人不会入队，需在 Run 之前调用
+func (s *Service) SetSuppressionStore(store suppression.Store) {
+	s.suppression = store
+}
+
+// MaxRows 返回单个活动的收件人上限
+func (s *Service) MaxRows() int {
+	return s.config.MaxRows
+}
+
+// MaxUploadSize 返回收件人列表文件的最大字节数
+func (s *Service) MaxUploadSize() int64 {
+	if s.config.MaxUploadSize <= 0 {
+		return DefaultConfig().MaxUploadSize
+	}
+	return s.config.MaxUploadSize
+}
+
+// Create 校验并保存新的活动，到达开始时间后由后台入队
+func (s *Service) Create(ctx context.Context, c *Campaign, list *ParseResult) (*Campaign, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if list == nil || len(list.Rows) == 0 {
+		return nil, fmt.Errorf("%w: no recipients", ErrInvalidList)
+	}
+
+	now := time.Now().UTC()
+	created := *c
+	created.ID = newCampaignID()
+	if created.SendAt.IsZero() {
+		created.SendAt = now
+	}
+	created.SendAt = created.SendAt.UTC()
+	created.State = StateScheduled
+	created.Total = len(list.Rows)
+	created.Duplicates = list.Duplicates
+	created.Cursor = 0
+	created.Progress = Progress{Pending: created.Total}
+	created.LastError = ""
+	created.CreatedAt, created.UpdatedAt = now, now
+	created.StartedAt, created.CompletedAt = nil, nil
+	if err := s.store.Create(ctx, &created, list.Rows); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Get 按ID查询活动
+func (s *Service) Get(ctx context.Context, id string) (*Campaign, error) {
+	return s.store.Get(ctx, id)
+}
+
+// List 返回全部活动
+func (s *Service) List(ctx context.Context) ([]*Campaign, error) {
+	return s.store.List(ctx)
+}
+
+// Pause 暂停活动：停止入队，已入队的任务推迟到恢复后发送
+func (s *Service) Pause(ctx context.Context, id string) (*Campaign, error) {
+	return s.transition(ctx, id, func(c *Campaign) error {
+		if c.State == StatePaused || c.State == StateCancelled {
+			return fmt.Errorf("%w: cannot pause a %s campaign", ErrInvalidState, c.State)
+		}
+		c.State = StatePaused
+		return nil
+	})
+}
+
+// Resume 恢复暂停的活动，未开始的活动回到等待开始时间的状态
+func (s *Service) Resume(ctx context.Context, id string) (*Campaign, error) {
+	return s.transition(ctx, id, func(c *Campaign) error {
+		if c.State != StatePaused {
+			return fmt.Errorf("%w: cannot resume a %s campaign", ErrInvalidState, c.State)
+		}
+		switch {
+		case c.StartedAt == nil:
+			c.State = StateScheduled
+		case c.Cursor >= c.Total:
+			c.State = StateCompleted
+		default:
+			c.State = StateSending
+		}
+		return nil
+	})
+}
+
+// Cancel 取消活动：停止入队，已入队但未发送的任务被丢弃
+func (s *Service) Cancel(ctx context.Context, id string) (*Campaign, error) {
+	return s.transition(ctx, id, func(c *Campaign) error {
+		if c.State == StateCancelled {
+			return fmt.Errorf("%w: campaign is already cancelled", ErrInvalidState)
+		}
+		c.State = StateCancelled
+		return nil
+	})
+}
+
+// transition 修改活动状态并刷新本实例的缓存
+func (s *Service) transition(ctx context.Context, id string, fn func(*Campaign) error) (*Campaign, error) {
+	c, err := s.store.Update(ctx, id, func(c *Campaign) error {
+		if err := fn(c); err != nil {
+			return err
+		}
+		c.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.states[id] = cachedState{state: c.State, at: time.Now()}
+	s.mu.Unlock()
+	s.logger.Info("Campaign state changed", "campaign_id", id, "state", c.State)
+	return c, nil
+}
+
+// Run 周期性竞选主节点，主节点为到期的活动分批入队，直到 ctx 结束
+func (s *Service) Run(ctx context.Context) {
+	defer s.store.Close()
+
+	interval := s.config.Interval
+	if interval <= 0 {
+		interval = DefaultConfig().Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	leader := false
+	for {
+		select {
+		case <-ctx.Done():
+			if leader && s.elector != nil {
+				resignCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := s.elector.Resign(resignCtx); err != nil {
+					s.logger.Warn("Failed to resign leadership", "error", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		elected := s.elect(ctx)
+		if elected != leader {
+			leader = elected
+			s.logger.Info("Campaign leadership changed", "leader", leader)
+		}
+		if leader {
+			s.RunDue(ctx)
+		}
+	}
+}
+
+// elect 获取或续期主节点身份
+func (s *Service) elect(ctx context.Context) bool {
+	if s.elector == nil {
+		return true
+	}
+	elected, err := s.elector.Elect(ctx)
+	if err != nil {
+		s.logger.Error("Failed to elect campaign leader", "error", err)
+		return false
+	}
+	return elected
+}
+
+// RunDue 开始到期的活动，并为正在发送的活动入队剩余的收件人
+func (s *Service) RunDue(ctx context.Context) {
+	campaigns, err := s.store.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list campaigns", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, c := range campaigns {
+		if ctx.Err() != nil {
+			return
+		}
+		if c.State == StateScheduled && !c.SendAt.After(now) {
+			started, err := s.start(ctx, c.ID)
+			if err != nil {
+				s.logger.Error("Failed to start campaign", "campaign_id", c.ID, "error", err)
+				continue
+			}
+			if started == nil {
+				continue
+			}
+			c = started
+		}
+		if c.State == StateSending {
+			s.enqueue(ctx, c)
+		}
+	}
+}
+
+// start 将到期的活动标记为正在发送，活动已被修改时返回 nil
+func (s *Service) start(ctx context.Context, id string) (*Campaign, error) {
+	c, err := s.store.Update(ctx, id, func(c *Campaign) error {
+		if c.State != StateScheduled {
+			return errStale
+		}
+		now := time.Now().UTC()
+		c.State = StateSending
+		c.StartedAt = &now
+		c.UpdatedAt = now
+		return nil
+	})
+	if errors.Is(err, errStale) || errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err == nil {
+		s.logger.Info("Campaign started", "campaign_id", id, "total", c.Total)
+	}
+	return c, err
+}
+
+// enqueue 从游标处分批入队，活动暂停、取消或失去主节点身份时停止
+// 任务ID由活动和收件人序号确定，中断后重新入队时跳过已入队的收件人
+func (s *Service) enqueue(ctx context.Context, c *Campaign) {
+	batchSize := s.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultConfig().BatchSize
+	}
+	lastBeat := time.Now()
+
+	for c.State == StateSending && ctx.Err() == nil {
+		if c.Cursor >= c.Total {
+			s.complete(ctx, c)
+			return
+		}
+
+		rows, err := s.store.Rows(ctx, c.ID, c.Cursor, batchSize)
+		if err != nil {
+			s.logger.Error("Failed to read campaign recipients", "campaign_id", c.ID, "error", err)
+			return
+		}
+		if len(rows) == 0 {
+			s.complete(ctx, c)
+			return
+		}
+
+		states := make(map[int]RowState, len(rows))
+		var pushErr error
+		done := 0
+		for _, row := range rows {
+			job := buildJob(c, row)
+			if row.State != "" {
+				done++
+				continue
+			}
+			if status.Queued(ctx, s.statuses, job.ID, s.logger) {
+				states[row.Index] = RowQueued
+				done++
+				continue
+			}
+			if suppression.Suppressed(ctx, s.suppression, row.Email, c.Category, s.logger) {
+				states[row.Index] = RowSkipped
+				done++
+				continue
+			}
+			if pushErr = s.pusher.PushJob(job); pushErr != nil {
+				break
+			}
+			states[row.Index] = RowQueued
+			done++
+		}
+		// 已入队的任务即使 ctx 已取消也要记录
+		if err := s.store.SetRowStates(context.Background(), c.ID, states); err != nil {
+			s.logger.Error("Failed to record campaign progress", "campaign_id", c.ID, "error", err)
+		}
+
+		lastError := ""
+		if pushErr != nil {
+			s.logger.Error("Failed to push campaign job, will retry", "campaign_id", c.ID, "error", pushErr)
+			lastError = pushErr.Error()
+		}
+		if c = s.advance(c, done, lastError); c == nil || pushErr != nil {
+			return
+		}
+
+		// 收件人较多时定期续期，失去主节点身份后停止，由新的主节点继续
+		if s.elector != nil && time.Since(lastBeat) > s.leaderTTL()/3 {
+			if !s.elect(ctx) {
+				s.logger.Warn("Lost leadership during campaign run", "campaign_id", c.ID)
+				return
+			}
+			lastBeat = time.Now()
+		}
+	}
+}
+
+// advance 将游标向后移动 n 个收件人，返回最新的活动；游标已被其他实例移动时返回 nil
+func (s *Service) advance(c *Campaign, n int, lastError string) *Campaign {
+	cursor := c.Cursor
+	updated, err := s.store.Update(context.Background(), c.ID, func(cur *Campaign) error {
+		if cur.Cursor != cursor {
+			return errStale
+		}
+		cur.Cursor += n
+		cur.LastError = lastError
+		cur.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errStale) && !errors.Is(err, ErrNotFound) {
+			s.logger.Error("Failed to advance campaign cursor", "campaign_id", c.ID, "error", err)
+		}
+		return nil
+	}
+	return updated
+}
+
+// complete 全部收件人处理完成后标记活动完成
+func (s *Service) complete(ctx context.Context, c *Campaign) {
+	_, err := s.store.Update(context.Background(), c.ID, func(cur *Campaign) error {
+		if cur.State != StateSending || cur.Cursor < cur.Total {
+			return errStale
+		}
+		now := time.Now().UTC()
+		cur.State = StateCompleted
+		cur.CompletedAt = &now
+		cur.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errStale) && !errors.Is(err, ErrNotFound) {
+			s.logger.Error("Failed to complete campaign", "campaign_id", c.ID, "error", err)
+		}
+		return
+	}
+	s.logger.Info("Campaign fully queued", "campaign_id", c.ID, "total", c.Total)
+}
+
+// Hold 供工人在发送前检查任务所属的活动：暂停时返回推迟的时长，取消时返回 true 表示丢弃任务
+func (s *Service) Hold(ctx context.Context, job jobqueue.EmailJob) (time.Duration, bool) {
+	if job.Campaign == "" {
+		return 0, false
+	}
+	switch s.state(ctx, job.Campaign) {
+	case StatePaused:
+		if s.config.PausedDelay <= 0 {
+			return DefaultConfig().PausedDelay, false
+		}
+		return s.config.PausedDelay, false
+	case StateCancelled:
+		if _, index, ok := ParseJobID(job.ID); ok {
+			err := s.store.SetRowStates(ctx, job.Campaign, map[int]RowState{index: RowCancelled})
+			if err != nil {
+				s.logger.Warn("Failed to record cancelled campaign job", "campaign_id", job.Campaign, "job_id", job.ID, "error", err)
+			}
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// state 返回活动的状态，短时间内的重复查询使用缓存；查询失败时视为正在发送
+func (s *Service) state(ctx context.Context, id string) State {
+	s.mu.Lock()
+	cached, ok := s.states[id]
+	s.mu.Unlock()
+	if ok && time.Since(cached.at) < stateCacheTTL {
+		return cached.state
+	}
+
+	c, err := s.store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			s.logger.Warn("Failed to look up campaign", "campaign_id", id, "error", err)
+		}
+		return StateSending
+	}
+	s.mu.Lock()
+	s.states[id] = cachedState{state: c.State, at: time.Now()}
+	s.mu.Unlock()
+	return c.State
+}
+
+// TrackStatuses 包装任务状态存储，活动任务的状态变化同步到活动进度
+func (s *Service) TrackStatuses(store status.Store) status.Store {
+	return &trackingStore{Store: store, service: s}
+}
+
+// trackingStore 在保存任务状态后更新活动进度
+type trackingStore struct {
+	status.Store
+	service *Service
+}
+
+// Save 保存任务状态，任务属于活动时同时推进收件人的状态
+func (t *trackingStore) Save(ctx context.Context, st *status.JobStatus) error {
+	if err := t.Store.Save(ctx, st); err != nil {
+		return err
+	}
+	t.service.track(ctx, st)
+	return nil
+}
+
+// track 将任务状态映射为收件人状态
+func (s *Service) track(ctx context.Context, st *status.JobStatus) {
+	id, index, ok := ParseJobID(st.JobID)
+	if !ok {
+		return
+	}
+	var state RowState
+	switch st.State {
+	case status.StateQueued, status.StateRetrying:
+		state = RowQueued
+	case status.StateSent:
+		state = RowSent
+	case status.StateFailed:
+		state = RowFailed
+	case status.StateBounced:
+		state = RowBounced
+	case status.StateComplained:
+		state = RowComplained
+	default:
+		return
+	}
+	err := s.store.SetRowStates(ctx, id, map[int]RowState{index: state})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.logger.Warn("Failed to update campaign progress", "campaign_id", id, "job_id", st.JobID, "error", err)
+	}
+}
+
+// buildJob 生成发给收件人的邮件任务，收件人的模板数据覆盖活动共用的同名字段
+func buildJob(c *Campaign, row Row) jobqueue.EmailJob {
+	data := make(map[string]any, len(c.TemplateData)+len(row.Data))
+	maps.Copy(data, c.TemplateData)
+	maps.Copy(data, row.Data)
+
+	now := time.Now()
+	return jobqueue.EmailJob{
+		ID:           JobID(c.ID, row.Index),
+		To:           row.Email,
+		Subject:      c.Subject,
+		Category:     c.Category,
+		Priority:     c.Priority,
+		TemplateID:   c.TemplateID,
+		TemplateData: data,
+		MaxRetries:   3,
+		NextRetryAt:  now,
+		CreatedAt:    now,
+		Timezone:     row.Timezone,
+		Campaign:     c.ID,
+	}
+}
+
+// leaderTTL 返回主节点租约时长
+func (s *Service) leaderTTL() time.Duration {
+	if s.config.LeaderTTL <= 0 {
+		return DefaultConfig().LeaderTTL
+	}
+	return s.config.LeaderTTL
+}
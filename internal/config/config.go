@@ -5,8 +5,27 @@ import (
 	"os"
 	"strconv"
 
+	"email-service/internal/attachment"
+	"email-service/internal/bounce"
+	"email-service/internal/campaign"
+	"email-service/internal/digest"
+	"email-service/internal/dkim"
+	"email-service/internal/events"
+	"email-service/internal/feedback"
+	"email-service/internal/idempotency"
 	"email-service/internal/logger"
+	"email-service/internal/outbox"
+	"email-service/internal/pgp"
 	"email-service/internal/queue"
+	"email-service/internal/quiethours"
+	"email-service/internal/schedule"
+	"email-service/internal/smime"
+	"email-service/internal/smtpd"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+	"email-service/internal/throttle"
+	"email-service/internal/unsubscribe"
+	"email-service/internal/verp"
 
 	"github.com/spf13/viper"
 )
@@ -18,10 +37,30 @@ type Config struct {
 	SMTPUser     string
 	SMTPPass     string
 	ServerPort   string
+	AdminToken   string
 	MaxWorkers   int
 	MaxQueueSize int
 	Queue        *queue.TaskQueueConfig
 	Logger       *logger.Config
+	Attachment   *attachment.Config
+	DKIM         *dkim.Config
+	SMIME        *smime.Config
+	PGP          *pgp.Config
+	Status       *status.Config
+	Unsubscribe  *unsubscribe.Config
+	Suppression  *suppression.Config
+	Bounce       *bounce.Config
+	VERP         *verp.Config
+	SMTPServer   *smtpd.Config
+	Feedback     *feedback.Config
+	Events       *events.Config
+	Outbox       *outbox.Config
+	Idempotency  *idempotency.Config
+	Throttle     *throttle.Config
+	Digest       *digest.Config
+	Schedule     *schedule.Config
+	QuietHours   *quiethours.Config
+	Campaign     *campaign.Config
 }
 
 // Load 从环境变量加载配置
@@ -54,16 +93,70 @@ func Load() (*Config, error) {
 		Output:    getEnv("LOG_OUTPUT", "stdout"),
 	}
 
+	// 默认附件存储配置
+	attachmentConfig := attachment.DefaultConfig()
+	attachmentConfig.Dir = getEnv("ATTACHMENT_DIR", attachmentConfig.Dir)
+
+	// 默认 PGP 配置
+	pgpConfig := pgp.DefaultConfig()
+	pgpConfig.KeyStoreDir = getEnv("PGP_KEY_STORE_DIR", pgpConfig.KeyStoreDir)
+	pgpConfig.SigningKeyFile = getEnv("PGP_SIGNING_KEY_FILE", "")
+	pgpConfig.SigningKeyPassphrase = getEnv("PGP_SIGNING_KEY_PASSPHRASE", "")
+	pgpConfig.MissingKeyPolicy = getEnv("PGP_MISSING_KEY_POLICY", pgpConfig.MissingKeyPolicy)
+
+	// 默认屏蔽名单配置
+	suppressionConfig := suppression.DefaultConfig()
+	suppressionConfig.Type = getEnv("SUPPRESSION_TYPE", suppressionConfig.Type)
+	suppressionConfig.File = getEnv("SUPPRESSION_FILE", suppressionConfig.File)
+	suppressionConfig.AutoSuppressHardBounces = getEnv("SUPPRESSION_AUTO_HARD_BOUNCES", "false") == "true"
+
+	// 默认 VERP 配置
+	verpConfig := verp.DefaultConfig()
+	verpConfig.Enabled = getEnv("VERP_ENABLED", "false") == "true"
+	verpConfig.Domain = getEnv("VERP_DOMAIN", "")
+
 	return &Config{
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.qq.com"),
 		SMTPPort:     smtpPort,
 		SMTPUser:     getEnv("SMTP_USER", "2514307815@qq.com"),
 		SMTPPass:     getEnv("SMTP_PASS", ""),
 		ServerPort:   getEnv("SERVER_PORT", "8080"),
+		AdminToken:   getEnv("ADMIN_TOKEN", ""),
 		MaxWorkers:   maxWorkers,
 		MaxQueueSize: maxQueueSize,
 		Queue:        queueConfig,
 		Logger:       loggerConfig,
+		Attachment:   attachmentConfig,
+		DKIM:         &dkim.Config{},
+		SMIME: &smime.Config{
+			CertFile:     getEnv("SMIME_CERT_FILE", ""),
+			KeyFile:      getEnv("SMIME_KEY_FILE", ""),
+			CertStoreDir: getEnv("SMIME_CERT_STORE_DIR", ""),
+		},
+		PGP:    pgpConfig,
+		Status: status.DefaultConfig(),
+		Unsubscribe: &unsubscribe.Config{
+			Enabled: getEnv("UNSUBSCRIBE_ENABLED", "false") == "true",
+			BaseURL: getEnv("UNSUBSCRIBE_BASE_URL", ""),
+			Secret:  getEnv("UNSUBSCRIBE_SECRET", ""),
+		},
+		Suppression: suppressionConfig,
+		Bounce:      bounce.DefaultConfig(),
+		VERP:        verpConfig,
+		SMTPServer:  smtpd.DefaultConfig(),
+		Feedback:    feedback.DefaultConfig(),
+		Events: &events.Config{
+			WebhookURL: getEnv("EVENTS_WEBHOOK_URL", ""),
+			Secret:     getEnv("EVENTS_WEBHOOK_SECRET", ""),
+			Timeout:    events.DefaultConfig().Timeout,
+		},
+		Outbox:      outbox.DefaultConfig(),
+		Idempotency: idempotency.DefaultConfig(),
+		Throttle:    throttle.DefaultConfig(),
+		Digest:      digest.DefaultConfig(),
+		Schedule:    schedule.DefaultConfig(),
+		QuietHours:  quiethours.DefaultConfig(),
+		Campaign:    campaign.DefaultConfig(),
 	}, nil
 }
 
@@ -131,16 +224,150 @@ func LoadWithViper(configPath string) (*Config, error) {
 		}
 	}
 
+	// 解析附件存储配置，未配置的字段保持默认值
+	attachmentConfig := attachment.DefaultConfig()
+	if err := v.UnmarshalKey("attachment", attachmentConfig); err != nil {
+		return nil, fmt.Errorf("invalid attachment config: %w", err)
+	}
+
+	// 解析 DKIM 签名配置
+	var dkimConfig dkim.Config
+	if err := v.UnmarshalKey("dkim", &dkimConfig); err != nil {
+		return nil, fmt.Errorf("invalid dkim config: %w", err)
+	}
+
+	// 解析 S/MIME 配置
+	var smimeConfig smime.Config
+	if err := v.UnmarshalKey("smime", &smimeConfig); err != nil {
+		return nil, fmt.Errorf("invalid smime config: %w", err)
+	}
+
+	// 解析 PGP 配置，未配置的字段保持默认值
+	pgpConfig := pgp.DefaultConfig()
+	if err := v.UnmarshalKey("pgp", pgpConfig); err != nil {
+		return nil, fmt.Errorf("invalid pgp config: %w", err)
+	}
+
+	// 解析任务状态存储配置，未配置的字段保持默认值
+	statusConfig := status.DefaultConfig()
+	if err := v.UnmarshalKey("status", statusConfig); err != nil {
+		return nil, fmt.Errorf("invalid status config: %w", err)
+	}
+
+	// 解析退订配置
+	var unsubscribeConfig unsubscribe.Config
+	if err := v.UnmarshalKey("unsubscribe", &unsubscribeConfig); err != nil {
+		return nil, fmt.Errorf("invalid unsubscribe config: %w", err)
+	}
+
+	// 解析屏蔽名单配置，未配置的字段保持默认值
+	suppressionConfig := suppression.DefaultConfig()
+	if err := v.UnmarshalKey("suppression", suppressionConfig); err != nil {
+		return nil, fmt.Errorf("invalid suppression config: %w", err)
+	}
+
+	// 解析退信邮箱配置，未配置的字段保持默认值
+	bounceConfig := bounce.DefaultConfig()
+	if err := v.UnmarshalKey("bounce", bounceConfig); err != nil {
+		return nil, fmt.Errorf("invalid bounce config: %w", err)
+	}
+
+	// 解析 VERP 配置，未配置的字段保持默认值
+	verpConfig := verp.DefaultConfig()
+	if err := v.UnmarshalKey("verp", verpConfig); err != nil {
+		return nil, fmt.Errorf("invalid verp config: %w", err)
+	}
+
+	// 解析 SMTP 提交服务配置，未配置的字段保持默认值
+	smtpServerConfig := smtpd.DefaultConfig()
+	if err := v.UnmarshalKey("smtp_server", smtpServerConfig); err != nil {
+		return nil, fmt.Errorf("invalid smtp_server config: %w", err)
+	}
+
+	// 解析投诉报告配置，未配置的字段保持默认值
+	feedbackConfig := feedback.DefaultConfig()
+	if err := v.UnmarshalKey("feedback", feedbackConfig); err != nil {
+		return nil, fmt.Errorf("invalid feedback config: %w", err)
+	}
+
+	// 解析投递事件配置，未配置的字段保持默认值
+	eventsConfig := events.DefaultConfig()
+	if err := v.UnmarshalKey("events", eventsConfig); err != nil {
+		return nil, fmt.Errorf("invalid events config: %w", err)
+	}
+
+	// 解析发件箱中继配置，未配置的字段保持默认值
+	outboxConfig := outbox.DefaultConfig()
+	if err := v.UnmarshalKey("outbox", outboxConfig); err != nil {
+		return nil, fmt.Errorf("invalid outbox config: %w", err)
+	}
+
+	// 解析幂等记录存储配置，未配置的字段保持默认值
+	idempotencyConfig := idempotency.DefaultConfig()
+	if err := v.UnmarshalKey("idempotency", idempotencyConfig); err != nil {
+		return nil, fmt.Errorf("invalid idempotency config: %w", err)
+	}
+
+	// 解析限流规则配置，未配置的字段保持默认值
+	throttleConfig := throttle.DefaultConfig()
+	if err := v.UnmarshalKey("throttle", throttleConfig); err != nil {
+		return nil, fmt.Errorf("invalid throttle config: %w", err)
+	}
+
+	// 解析摘要配置，未配置的字段保持默认值
+	digestConfig := digest.DefaultConfig()
+	if err := v.UnmarshalKey("digest", digestConfig); err != nil {
+		return nil, fmt.Errorf("invalid digest config: %w", err)
+	}
+
+	// 解析定时任务配置，未配置的字段保持默认值
+	scheduleConfig := schedule.DefaultConfig()
+	if err := v.UnmarshalKey("schedule", scheduleConfig); err != nil {
+		return nil, fmt.Errorf("invalid schedule config: %w", err)
+	}
+
+	// 解析免打扰配置，未配置的字段保持默认值
+	quietHoursConfig := quiethours.DefaultConfig()
+	if err := v.UnmarshalKey("quiet_hours", quietHoursConfig); err != nil {
+		return nil, fmt.Errorf("invalid quiet hours config: %w", err)
+	}
+
+	// 解析批量发送活动配置，未配置的字段保持默认值
+	campaignConfig := campaign.DefaultConfig()
+	if err := v.UnmarshalKey("campaign", campaignConfig); err != nil {
+		return nil, fmt.Errorf("invalid campaign config: %w", err)
+	}
+
 	return &Config{
 		SMTPHost:     v.GetString("smtp.host"),
 		SMTPPort:     v.GetInt("smtp.port"),
 		SMTPUser:     v.GetString("smtp.user"),
 		SMTPPass:     v.GetString("smtp.pass"),
 		ServerPort:   v.GetString("server.port"),
+		AdminToken:   v.GetString("server.admin_token"),
 		MaxWorkers:   v.GetInt("max_workers"),
 		MaxQueueSize: v.GetInt("max_queue_size"),
 		Queue:        &queueConfig,
 		Logger:       &loggerConfig,
+		Attachment:   attachmentConfig,
+		DKIM:         &dkimConfig,
+		SMIME:        &smimeConfig,
+		PGP:          pgpConfig,
+		Status:       statusConfig,
+		Unsubscribe:  &unsubscribeConfig,
+		Suppression:  suppressionConfig,
+		Bounce:       bounceConfig,
+		VERP:         verpConfig,
+		SMTPServer:   smtpServerConfig,
+		Feedback:     feedbackConfig,
+		Events:       eventsConfig,
+		Outbox:       outboxConfig,
+		Idempotency:  idempotencyConfig,
+		Throttle:     throttleConfig,
+		Digest:       digestConfig,
+		Schedule:     scheduleConfig,
+		QuietHours:   quietHoursConfig,
+		Campaign:     campaignConfig,
 	}, nil
 }
 
@@ -0,0 +1,135 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketDigests = []byte("digests") // member -> boltRecord
+	bucketDue     = []byte("due")     // 到期时间(8字节) + member -> 空
+)
+
+// boltRecord 摘要在 bolt 中的记录
+type boltRecord struct {
+	Meta  Meta             `json:"meta"`
+	Items []map[string]any `json:"items"`
+}
+
+// BoltStore 基于 bbolt 的摘要存储，只在单实例内有效，重启后不丢失
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开或创建 bolt 摘要存储
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open digest store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketDigests, bucketDue} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Append 追加一条通知
+func (s *BoltStore) Append(ctx context.Context, id ID, meta Meta, item map[string]any) (int, *Meta, error) {
+	member := []byte(id.member())
+	var rec boltRecord
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		digests := tx.Bucket(bucketDigests)
+		if data := digests.Get(member); data != nil {
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+		} else {
+			rec.Meta = meta
+			if err := tx.Bucket(bucketDue).Put(dueKey(meta.DueAt, member), nil); err != nil {
+				return err
+			}
+		}
+		rec.Items = append(rec.Items, item)
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return digests.Put(member, data)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(rec.Items), &rec.Meta, nil
+}
+
+// Due 按到期时间顺序返回窗口已结束的摘要
+func (s *BoltStore) Due(ctx context.Context, now time.Time, limit int) ([]ID, error) {
+	var ids []ID
+	limitKey := dueKey(now, nil)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketDue).Cursor()
+		for k, _ := c.First(); k != nil && len(ids) < limit; k, _ = c.Next() {
+			if bytes.Compare(k[:8], limitKey) > 0 {
+				break
+			}
+			ids = append(ids, parseMember(string(k[8:])))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// Flush 在同一个事务中取出摘要、调用 send 并删除，send 失败时事务回滚
+func (s *BoltStore) Flush(ctx context.Context, id ID, send func(Digest) error) error {
+	member := []byte(id.member())
+	return s.db.Update(func(tx *bolt.Tx) error {
+		digests := tx.Bucket(bucketDigests)
+		data := digests.Get(member)
+		if data == nil {
+			return nil
+		}
+		var rec boltRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if err := send(Digest{ID: parseMember(string(member)), Meta: rec.Meta, Items: rec.Items}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketDue).Delete(dueKey(rec.Meta.DueAt, member)); err != nil {
+			return err
+		}
+		return digests.Delete(member)
+	})
+}
+
+// Close 关闭数据库
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// dueKey 生成到期索引的键，按时间排序
+func dueKey(at time.Time, member []byte) []byte {
+	key := make([]byte, 8, 8+len(member))
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	return append(key, member...)
+}
@@ -0,0 +1,313 @@
+// Package digest 将同一收件人在窗口期内的多条通知合并为一封摘要邮件
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+)
+
+// ErrInvalidKey 摘要键为空、过长或包含控制字符
+var ErrInvalidKey = errors.New("digest: invalid key")
+
+// maxKeyLength 摘要键的最大长度
+const maxKeyLength = 128
+
+// ID 标识一个收件人的一份摘要
+type ID struct {
+	Key       string `json:"key"`
+	Recipient string `json:"recipient"`
+}
+
+// member 返回摘要在存储中的名称，键和地址都不含换行
+func (id ID) member() string {
+	return id.Key + "\n" + strings.ToLower(id.Recipient)
+}
+
+// parseMember 解析 member 返回的名称
+func parseMember(member string) ID {
+	key, recipient, _ := strings.Cut(member, "\n")
+	return ID{Key: key, Recipient: recipient}
+}
+
+// Meta 摘要邮件的属性，取自开启该摘要的第一条通知
+type Meta struct {
+	Subject    string        `json:"subject"`
+	TemplateID string        `json:"template_id"` // 摘要模板，条目列表在模板数据的 items 中
+	Category   string        `json:"category,omitempty"`
+	Priority   string        `json:"priority,omitempty"`
+	Sign       string        `json:"sign,omitempty"`
+	Encrypt    string        `json:"encrypt,omitempty"`
+	Window     time.Duration `json:"window"`
+	CreatedAt  time.Time     `json:"created_at"` // 第一条通知的时间
+	DueAt      time.Time     `json:"due_at"`     // 窗口结束时间
+}
+
+// Digest 待发送的摘要
+type Digest struct {
+	ID
+	Meta
+	Items []map[string]any
+}
+
+// Store 定义摘要存储接口
+type Store interface {
+	// Append 追加一条通知；摘要不存在时以 meta 创建。返回追加后的条目数和摘要的实际属性
+	Append(ctx context.Context, id ID, meta Meta, item map[string]any) (int, *Meta, error)
+
+	// Due 返回窗口已结束的摘要
+	Due(ctx context.Context, now time.Time, limit int) ([]ID, error)
+
+	// Flush 取出摘要交给 send，send 成功后删除已取出的条目；摘要不存在时不调用 send
+	Flush(ctx context.Context, id ID, send func(Digest) error) error
+
+	// Close 关闭存储
+	Close() error
+}
+
+// Config 摘要配置
+type Config struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Store    string        `mapstructure:"store"` // bolt 或 redis
+	Path     string        `mapstructure:"path"`  // bolt 类型的数据文件
+	Redis    *RedisConfig  `mapstructure:"redis"`
+	Template string        `mapstructure:"template"`  // 请求未指定 template_id 时使用的摘要模板
+	Interval time.Duration `mapstructure:"interval"`  // 检查到期摘要的间隔
+	MaxItems int           `mapstructure:"max_items"` // 请求未指定时的条目上限，达到后立即发送
+	Window   time.Duration `mapstructure:"window"`    // 请求未指定时的窗口
+}
+
+// RedisConfig Redis 摘要存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	StoreBolt  = "bolt"
+	StoreRedis = "redis"
+)
+
+// DefaultConfig 返回默认摘要配置，默认不启用
+func DefaultConfig() *Config {
+	return &Config{
+		Store:    StoreBolt,
+		Path:     "data/digest.db",
+		Template: "zh/digest.html",
+		Interval: 10 * time.Second,
+		MaxItems: 100,
+		Window:   time.Hour,
+	}
+}
+
+// JobPusher 接收摘要邮件任务，由 mailer.Dispatcher 实现
+type JobPusher interface {
+	PushJob(job jobqueue.EmailJob) error
+}
+
+// Result 通知加入摘要的结果
+type Result struct {
+	Items int       // 摘要当前的条目数
+	DueAt time.Time // 摘要的发送时间
+	JobID string    // 达到条目上限立即发送时为摘要邮件的任务ID
+}
+
+// Service 维护摘要并在窗口结束或条目达到上限时发送
+type Service struct {
+	store  Store
+	pusher JobPusher
+	config *Config
+	logger *logger.Logger
+}
+
+// New 根据配置创建摘要服务
+func New(cfg *Config, pusher JobPusher) (*Service, error) {
+	var (
+		store Store
+		err   error
+	)
+	switch cfg.Store {
+	case StoreBolt, "":
+		path := cfg.Path
+		if path == "" {
+			path = DefaultConfig().Path
+		}
+		store, err = NewBoltStore(path)
+	case StoreRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("digest: redis config is required")
+		}
+		store, err = NewRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("digest: unsupported store type %q", cfg.Store)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		store:  store,
+		pusher: pusher,
+		config: cfg,
+		logger: logger.GetDefault().WithComponent("digest"),
+	}, nil
+}
+
+// Template 返回请求未指定模板时使用的摘要模板
+func (s *Service) Template() string {
+	if s.config.Template == "" {
+		return DefaultConfig().Template
+	}
+	return s.config.Template
+}
+
+// DefaultWindow 返回请求未指定窗口时使用的窗口
+func (s *Service) DefaultWindow() time.Duration {
+	if s.config.Window <= 0 {
+		return DefaultConfig().Window
+	}
+	return s.config.Window
+}
+
+// ValidKey 判断摘要键是否合法
+func ValidKey(key string) error {
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("%w: length must be 1-%d", ErrInvalidKey, maxKeyLength)
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("%w: control characters are not allowed", ErrInvalidKey)
+		}
+	}
+	return nil
+}
+
+// Add 将一条通知加入收件人的摘要，条目数达到 maxItems 时立即发送
+// maxItems 不大于 0 时使用配置的上限
+func (s *Service) Add(ctx context.Context, id ID, meta Meta, item map[string]any, maxItems int) (*Result, error) {
+	if err := ValidKey(id.Key); err != nil {
+		return nil, err
+	}
+	if maxItems <= 0 {
+		maxItems = s.config.MaxItems
+	}
+	now := time.Now()
+	if meta.Window <= 0 {
+		meta.Window = s.DefaultWindow()
+	}
+	if meta.TemplateID == "" {
+		meta.TemplateID = s.Template()
+	}
+	meta.CreatedAt = now
+	meta.DueAt = now.Add(meta.Window)
+
+	n, actual, err := s.store.Append(ctx, id, meta, item)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Items: n, DueAt: actual.DueAt}
+	if maxItems > 0 && n >= maxItems {
+		jobID, err := s.flush(ctx, id)
+		if err != nil {
+			// 条目已保存，窗口结束时会再次发送
+			s.logger.Error("Failed to send full digest", "key", id.Key, "recipient", id.Recipient, "error", err)
+			return result, nil
+		}
+		result.JobID = jobID
+		result.DueAt = time.Now()
+	}
+	return result, nil
+}
+
+// Run 周期性发送到期的摘要，直到 ctx 结束
+func (s *Service) Run(ctx context.Context) {
+	defer s.store.Close()
+
+	interval := s.config.Interval
+	if interval <= 0 {
+		interval = DefaultConfig().Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.FlushDue(ctx)
+		}
+	}
+}
+
+// FlushDue 发送所有窗口已结束的摘要
+func (s *Service) FlushDue(ctx context.Context) {
+	for {
+		ids, err := s.store.Due(ctx, time.Now(), 100)
+		if err != nil {
+			s.logger.Error("Failed to list due digests", "error", err)
+			return
+		}
+		sent := 0
+		for _, id := range ids {
+			if _, err := s.flush(ctx, id); err != nil {
+				s.logger.Error("Failed to send digest", "key", id.Key, "recipient", id.Recipient, "error", err)
+				continue
+			}
+			sent++
+		}
+		// 全部失败时留到下一轮，避免反复重试
+		if len(ids) < 100 || sent == 0 {
+			return
+		}
+	}
+}
+
+// flush 将摘要转换为一个邮件任务推入队列，返回任务ID
+func (s *Service) flush(ctx context.Context, id ID) (string, error) {
+	var jobID string
+	err := s.store.Flush(ctx, id, func(d Digest) error {
+		job := buildJob(d)
+		if err := s.pusher.PushJob(job); err != nil {
+			return err
+		}
+		jobID = job.ID
+		s.logger.Info("Digest queued",
+			"key", d.Key, "recipient", d.Recipient, "items", len(d.Items), "job_id", job.ID)
+		return nil
+	})
+	return jobID, err
+}
+
+// buildJob 生成摘要邮件任务，模板数据中 items 为条目列表
+func buildJob(d Digest) jobqueue.EmailJob {
+	now := time.Now()
+	return jobqueue.EmailJob{
+		ID:         jobqueue.NewJobID(),
+		To:         d.Recipient,
+		Subject:    d.Subject,
+		Category:   d.Category,
+		Priority:   d.Priority,
+		Sign:       d.Sign,
+		Encrypt:    d.Encrypt,
+		TemplateID: d.TemplateID,
+		TemplateData: map[string]any{
+			"items":      d.Items,
+			"count":      len(d.Items),
+			"digest_key": d.Key,
+			"first_at":   d.CreatedAt,
+			"last_at":    now,
+		},
+		MaxRetries:  3,
+		NextRetryAt: now,
+		CreatedAt:   now,
+	}
+}
@@ -0,0 +1,195 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"email-service/pkg/jobqueue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// flushLockTTL 发送摘要时持有锁的时长，防止多个实例重复发送
+const flushLockTTL = time.Minute
+
+// appendScript 摘要不存在时写入属性并加入到期索引，然后追加条目
+// KEYS: 属性、条目列表、到期索引；ARGV: 属性JSON、到期毫秒时间、member、条目JSON
+var appendScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX') then
+	redis.call('ZADD', KEYS[3], ARGV[2], ARGV[3])
+end
+local n = redis.call('RPUSH', KEYS[2], ARGV[4])
+return {n, redis.call('GET', KEYS[1])}
+`)
+
+// finishScript 删除已发送的条目；发送期间追加的条目开启新的窗口。最后释放锁
+// KEYS: 属性、条目列表、到期索引、锁；ARGV: 已发送条目数、member、新窗口属性JSON、新到期毫秒时间、锁令牌
+var finishScript = redis.NewScript(`
+redis.call('LTRIM', KEYS[2], ARGV[1], -1)
+if redis.call('LLEN', KEYS[2]) == 0 then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	redis.call('ZREM', KEYS[3], ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[3])
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[2])
+end
+if redis.call('GET', KEYS[4]) == ARGV[5] then
+	redis.call('DEL', KEYS[4])
+end
+return 1
+`)
+
+// unlockScript 仅当锁仍由本次发送持有时释放
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore 基于 Redis 的摘要存储，多个实例共享
+// 每份摘要保存为属性字符串和条目列表，到期时间保存在一个有序集合中
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 摘要存储
+func NewRedisStore(config *RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:digest:"
+	}
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+// keys 返回摘要的属性、条目列表和到期索引键
+func (s *RedisStore) keys(member string) []string {
+	return []string{s.prefix + "meta:" + member, s.prefix + "items:" + member, s.prefix + "due"}
+}
+
+// Append 追加一条通知
+func (s *RedisStore) Append(ctx context.Context, id ID, meta Meta, item map[string]any) (int, *Meta, error) {
+	member := id.member()
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return 0, nil, err
+	}
+	itemData, err := json.Marshal(item)
+	if err != nil {
+		return 0, nil, err
+	}
+	res, err := appendScript.Run(ctx, s.client, s.keys(member),
+		string(metaData), meta.DueAt.UnixMilli(), member, string(itemData)).Slice()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, _ := res[0].(int64)
+	actual, _ := res[1].(string)
+	var m Meta
+	if err := json.Unmarshal([]byte(actual), &m); err != nil {
+		return 0, nil, err
+	}
+	return int(n), &m, nil
+}
+
+// Due 按到期时间顺序返回窗口已结束的摘要
+func (s *RedisStore) Due(ctx context.Context, now time.Time, limit int) ([]ID, error) {
+	members, err := s.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:     s.prefix + "due",
+		Start:   "-inf",
+		Stop:    now.UnixMilli(),
+		ByScore: true,
+		Count:   int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]ID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, parseMember(member))
+	}
+	return ids, nil
+}
+
+// Flush 加锁后取出摘要交给 send，成功后删除已发送的条目；锁被其他实例持有时直接返回
+func (s *RedisStore) Flush(ctx context.Context, id ID, send func(Digest) error) error {
+	member := id.member()
+	keys := s.keys(member)
+	lockKey := s.prefix + "lock:" + member
+	token := jobqueue.NewJobID()
+
+	ok, err := s.client.SetNX(ctx, lockKey, token, flushLockTTL).Result()
+	if err != nil || !ok {
+		return err
+	}
+	unlock := func() {
+		unlockScript.Run(context.Background(), s.client, []string{lockKey}, token)
+	}
+
+	metaData, err := s.client.Get(ctx, keys[0]).Result()
+	if errors.Is(err, redis.Nil) {
+		unlock()
+		return nil
+	}
+	if err != nil {
+		unlock()
+		return err
+	}
+	var meta Meta
+	if err := json.Unmarshal([]byte(metaData), &meta); err != nil {
+		unlock()
+		return err
+	}
+	raw, err := s.client.LRange(ctx, keys[1], 0, -1).Result()
+	if err != nil {
+		unlock()
+		return err
+	}
+	items := make([]map[string]any, 0, len(raw))
+	for _, data := range raw {
+		var item map[string]any
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			unlock()
+			return err
+		}
+		items = append(items, item)
+	}
+
+	if err := send(Digest{ID: parseMember(member), Meta: meta, Items: items}); err != nil {
+		unlock()
+		return err
+	}
+
+	now := time.Now()
+	next := meta
+	next.CreatedAt = now
+	next.DueAt = now.Add(meta.Window)
+	nextData, err := json.Marshal(next)
+	if err != nil {
+		unlock()
+		return err
+	}
+	// 摘要已入队，后续清理使用独立的 context
+	return finishScript.Run(context.Background(), s.client, append(keys, lockKey),
+		len(raw), member, string(nextData), next.DueAt.UnixMilli(), token).Err()
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
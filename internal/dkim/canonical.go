@@ -0,0 +1,138 @@
+package dkim
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Canonicalization 规范化算法
+type Canonicalization string
+
+const (
+	// CanonSimple simple 规范化
+	CanonSimple Canonicalization = "simple"
+	// CanonRelaxed relaxed 规范化
+	CanonRelaxed Canonicalization = "relaxed"
+)
+
+// ParseCanonicalization 解析 "header/body" 形式的规范化配置，为空时使用 relaxed/relaxed
+func ParseCanonicalization(value string) (header, body Canonicalization, err error) {
+	if value == "" {
+		return CanonRelaxed, CanonRelaxed, nil
+	}
+
+	parts := strings.SplitN(strings.ToLower(value), "/", 2)
+	header = Canonicalization(parts[0])
+	body = CanonSimple
+	if len(parts) == 2 {
+		body = Canonicalization(parts[1])
+	}
+	for _, c := range []Canonicalization{header, body} {
+		if c != CanonSimple && c != CanonRelaxed {
+			return "", "", fmt.Errorf("dkim: unsupported canonicalization %q", value)
+		}
+	}
+	return header, body, nil
+}
+
+// headerField 原始头部字段，raw 包含折行及结尾 CRLF
+type headerField struct {
+	name string
+	raw  string
+}
+
+// value 返回冒号之后的原始值
+func (f headerField) value() string {
+	return f.raw[strings.IndexByte(f.raw, ':')+1:]
+}
+
+// splitMessage 将邮件拆分为头部和正文
+func splitMessage(raw []byte) (header, body []byte, err error) {
+	idx := bytes.Index(raw, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return nil, nil, fmt.Errorf("%w: missing header/body separator", ErrInvalidMessage)
+	}
+	return raw[:idx+2], raw[idx+4:], nil
+}
+
+// parseHeader 解析头部字段，保留折行
+func parseHeader(header []byte) []headerField {
+	var fields []headerField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name := line
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name = line[:i]
+		}
+		fields = append(fields, headerField{name: strings.TrimSpace(name), raw: line})
+	}
+	return fields
+}
+
+// canonicalHeader 规范化单个头部字段 (RFC 6376 3.4.1, 3.4.2)
+func canonicalHeader(raw string, c Canonicalization) string {
+	if c == CanonSimple {
+		return raw
+	}
+
+	i := strings.IndexByte(raw, ':')
+	if i < 0 {
+		return raw
+	}
+	name := strings.ToLower(strings.TrimRight(raw[:i], " \t"))
+	value := strings.NewReplacer("\r\n", "").Replace(raw[i+1:])
+	value = strings.TrimSpace(compressWSP(value))
+	return name + ":" + value + "\r\n"
+}
+
+// canonicalBody 规范化正文 (RFC 6376 3.4.3, 3.4.4)
+func canonicalBody(body []byte, c Canonicalization) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	if c == CanonRelaxed {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(compressWSP(line), " ")
+		}
+	}
+
+	// 去除结尾空行
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	lines = lines[:end]
+
+	if len(lines) == 0 {
+		if c == CanonSimple {
+			return []byte("\r\n")
+		}
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// compressWSP 将连续的空白字符压缩为单个空格
+func compressWSP(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inWSP := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == ' ' || ch == '\t' {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+			inWSP = true
+			continue
+		}
+		inWSP = false
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
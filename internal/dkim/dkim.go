@@ -0,0 +1,305 @@
+// Package dkim 实现 DKIM (RFC 6376) 邮件签名，支持 rsa-sha256 和 ed25519-sha256 (RFC 8463)
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AlgorithmRSASHA256 RSA-SHA256 签名算法
+	AlgorithmRSASHA256 = "rsa-sha256"
+	// AlgorithmEd25519SHA256 Ed25519-SHA256 签名算法
+	AlgorithmEd25519SHA256 = "ed25519-sha256"
+)
+
+var (
+	// ErrInvalidMessage 邮件格式无法解析
+	ErrInvalidMessage = errors.New("dkim: invalid message")
+
+	// ErrUnsupportedKey 不支持的私钥类型
+	ErrUnsupportedKey = errors.New("dkim: unsupported private key type")
+)
+
+// DefaultHeaders 默认参与签名的头部
+var DefaultHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// Config DKIM 签名配置
+type Config struct {
+	Enabled          bool        `mapstructure:"enabled"`
+	Canonicalization string      `mapstructure:"canonicalization"` // header/body 规范化方式，默认 relaxed/relaxed
+	Headers          []string    `mapstructure:"headers"`          // 参与签名的头部，默认 DefaultHeaders
+	Keys             []KeyConfig `mapstructure:"keys"`             // 按发件域配置的签名密钥
+}
+
+// KeyConfig 单个发件域的签名密钥配置
+type KeyConfig struct {
+	Domain         string `mapstructure:"domain"`           // 发件域（d=）
+	Selector       string `mapstructure:"selector"`         // 选择器（s=）
+	Algorithm      string `mapstructure:"algorithm"`        // rsa-sha256 或 ed25519-sha256，为空时按密钥类型推断
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PEM 私钥文件路径
+	PrivateKey     string `mapstructure:"private_key"`      // PEM 私钥内容，优先于文件
+}
+
+// Key 已加载的签名密钥
+type Key struct {
+	Domain    string
+	Selector  string
+	Algorithm string
+	signer    crypto.Signer
+}
+
+// LoadKey 根据配置加载签名密钥
+func LoadKey(cfg KeyConfig) (*Key, error) {
+	if cfg.Domain == "" || cfg.Selector == "" {
+		return nil, errors.New("dkim: domain and selector are required")
+	}
+
+	data := []byte(cfg.PrivateKey)
+	if len(data) == 0 {
+		var err error
+		if data, err = os.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return nil, fmt.Errorf("dkim: failed to read private key for %s: %w", cfg.Domain, err)
+		}
+	}
+
+	signer, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: private key for %s: %w", cfg.Domain, err)
+	}
+
+	algorithm := algorithmFor(signer)
+	if cfg.Algorithm != "" && !strings.EqualFold(cfg.Algorithm, algorithm) {
+		return nil, fmt.Errorf("dkim: algorithm %s does not match %s key for %s", cfg.Algorithm, algorithm, cfg.Domain)
+	}
+
+	return &Key{
+		Domain:    strings.ToLower(cfg.Domain),
+		Selector:  cfg.Selector,
+		Algorithm: algorithm,
+		signer:    signer,
+	}, nil
+}
+
+// ParsePrivateKey 解析 PEM 格式的 RSA (PKCS#1/PKCS#8) 或 Ed25519 (PKCS#8) 私钥
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, ErrUnsupportedKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+func algorithmFor(signer crypto.Signer) string {
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		return AlgorithmEd25519SHA256
+	}
+	return AlgorithmRSASHA256
+}
+
+// sign 对头部哈希进行签名
+func (k *Key) sign(digest []byte) ([]byte, error) {
+	switch key := k.signer.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	case ed25519.PrivateKey:
+		// RFC 8463: 使用 PureEdDSA 对 SHA-256 哈希签名
+		return ed25519.Sign(key, digest), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// Signer 按发件域选择密钥对邮件进行 DKIM 签名
+type Signer struct {
+	keys        map[string][]*Key
+	headers     []string
+	headerCanon Canonicalization
+	bodyCanon   Canonicalization
+	now         func() time.Time
+}
+
+// NewSigner 根据配置创建签名器
+func NewSigner(cfg *Config) (*Signer, error) {
+	headerCanon, bodyCanon, err := ParseCanonicalization(cfg.Canonicalization)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = DefaultHeaders
+	}
+	// From 头部必须参与签名 (RFC 6376 5.4)
+	hasFrom := false
+	for _, h := range headers {
+		if strings.EqualFold(h, "From") {
+			hasFrom = true
+			break
+		}
+	}
+	if !hasFrom {
+		headers = append([]string{"From"}, headers...)
+	}
+
+	s := &Signer{
+		keys:        make(map[string][]*Key),
+		headers:     headers,
+		headerCanon: headerCanon,
+		bodyCanon:   bodyCanon,
+		now:         time.Now,
+	}
+	for _, kc := range cfg.Keys {
+		key, err := LoadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		s.keys[key.Domain] = append(s.keys[key.Domain], key)
+	}
+	return s, nil
+}
+
+// Sign 对完整的邮件原文（CRLF 换行）签名，返回添加了 DKIM-Signature 头部的邮件
+// 发件域没有配置密钥时原样返回
+func (s *Signer) Sign(raw []byte) ([]byte, error) {
+	header, body, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	fields := parseHeader(header)
+
+	domain, err := fromDomain(fields)
+	if err != nil {
+		return nil, err
+	}
+	keys := s.keys[domain]
+	if len(keys) == 0 {
+		return raw, nil
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body, s.bodyCanon))
+	bh := base64.StdEncoding.EncodeToString(bodyHash[:])
+
+	var signatures bytes.Buffer
+	for _, key := range keys {
+		sig, err := s.signWith(key, fields, bh)
+		if err != nil {
+			return nil, err
+		}
+		signatures.WriteString(sig)
+	}
+
+	out := make([]byte, 0, signatures.Len()+len(raw))
+	out = append(out, signatures.Bytes()...)
+	return append(out, raw...), nil
+}
+
+// signWith 使用指定密钥生成 DKIM-Signature 头部（含结尾 CRLF）
+func (s *Signer) signWith(key *Key, fields []headerField, bh string) (string, error) {
+	var names []string
+	var hashed bytes.Buffer
+	used := make(map[int]bool)
+	for _, name := range s.headers {
+		// 同名头部从下往上依次选取 (RFC 6376 5.4.2)
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			names = append(names, name)
+			hashed.WriteString(canonicalHeader(fields[i].raw, s.headerCanon))
+			break
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + key.Algorithm,
+		"c=" + string(s.headerCanon) + "/" + string(s.bodyCanon),
+		"d=" + key.Domain,
+		"s=" + key.Selector,
+		"t=" + strconv.FormatInt(s.now().Unix(), 10),
+		"h=" + strings.Join(names, ":"),
+		"bh=" + bh,
+		"b=",
+	}
+	unsigned := "DKIM-Signature: " + strings.Join(tags, ";\r\n\t")
+
+	// 签名头部自身以 b= 为空参与哈希，且不含结尾 CRLF
+	canon := canonicalHeader(unsigned+"\r\n", s.headerCanon)
+	hashed.WriteString(strings.TrimSuffix(canon, "\r\n"))
+
+	digest := sha256.Sum256(hashed.Bytes())
+	sig, err := key.sign(digest[:])
+	if err != nil {
+		return "", fmt.Errorf("dkim: failed to sign for %s: %w", key.Domain, err)
+	}
+	return unsigned + foldBase64(base64.StdEncoding.EncodeToString(sig)) + "\r\n", nil
+}
+
+// fromDomain 返回 From 头部地址的域名
+func fromDomain(fields []headerField) (string, error) {
+	for _, f := range fields {
+		if !strings.EqualFold(f.name, "From") {
+			continue
+		}
+		addr, err := mail.ParseAddress(strings.TrimSpace(f.value()))
+		if err != nil {
+			return "", fmt.Errorf("dkim: invalid From header: %w", err)
+		}
+		at := strings.LastIndex(addr.Address, "@")
+		if at < 0 {
+			return "", fmt.Errorf("dkim: invalid From address %q", addr.Address)
+		}
+		return strings.ToLower(addr.Address[at+1:]), nil
+	}
+	return "", fmt.Errorf("%w: missing From header", ErrInvalidMessage)
+}
+
+// foldBase64 将较长的签名值折行，避免超过单行长度限制
+func foldBase64(s string) string {
+	const width = 72
+	var b strings.Builder
+	for len(s) > width {
+		b.WriteString(s[:width])
+		b.WriteString("\r\n\t")
+		s = s[width:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
@@ -0,0 +1,300 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	msgauth "github.com/emersion/go-msgauth/dkim"
+)
+
+const testMessage = "From: Sender <sender@example.com>\r\n" +
+	"To:  alice@example.org\r\n" +
+	"Subject: Hello\r\n" +
+	"\tworld\r\n" +
+	"Date: Mon, 19 Oct 2026 10:00:00 +0800\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hi Alice,  \r\n" +
+	"\r\n" +
+	"see  you\tsoon.\r\n" +
+	"\r\n" +
+	"\r\n"
+
+// pemKey 将私钥编码为 PKCS#8 PEM
+func pemKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// testKeys 生成 example.com 下的一个 RSA 密钥和一个 Ed25519 密钥
+func testKeys(t *testing.T) []KeyConfig {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []KeyConfig{
+		{Domain: "example.com", Selector: "rsa", PrivateKey: pemKey(t, rsaKey)},
+		{Domain: "Example.COM", Selector: "ed", PrivateKey: pemKey(t, edKey)},
+	}
+}
+
+// newTestSigner 创建签名器，并返回按记录名称索引的 DNS TXT 记录
+func newTestSigner(t *testing.T, canon string, keys []KeyConfig) (*Signer, map[string]string) {
+	t.Helper()
+	signer, err := NewSigner(&Config{Canonicalization: canon, Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]string)
+	for _, domainKeys := range signer.keys {
+		for _, key := range domainKeys {
+			record, err := key.DNSRecord()
+			if err != nil {
+				t.Fatal(err)
+			}
+			records[key.RecordName()] = record
+		}
+	}
+	return signer, records
+}
+
+// verify 使用独立的 DKIM 实现验证邮件，DNS 查询由 records 应答
+func verify(t *testing.T, msg []byte, records map[string]string) []*msgauth.Verification {
+	t.Helper()
+	verifications, err := msgauth.VerifyWithOptions(bytes.NewReader(msg), &msgauth.VerifyOptions{
+		LookupTXT: func(name string) ([]string, error) {
+			if record, ok := records[name]; ok {
+				return []string{record}, nil
+			}
+			return nil, fmt.Errorf("no TXT record for %s", name)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifications
+}
+
+func TestSignVerifiesWithBothKeys(t *testing.T) {
+	for _, canon := range []string{"", "relaxed/relaxed", "simple/simple", "relaxed/simple", "simple/relaxed"} {
+		t.Run(canon, func(t *testing.T) {
+			signer, records := newTestSigner(t, canon, testKeys(t))
+			signed, err := signer.Sign([]byte(testMessage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(signed, []byte(testMessage)) {
+				t.Fatal("original message was modified")
+			}
+
+			verifications := verify(t, signed, records)
+			if len(verifications) != 2 {
+				t.Fatalf("got %d signatures, want 2", len(verifications))
+			}
+			for _, v := range verifications {
+				if v.Err != nil {
+					t.Errorf("signature for %s failed: %v", v.Domain, v.Err)
+				}
+				if v.Domain != "example.com" {
+					t.Errorf("d=%s, want example.com", v.Domain)
+				}
+			}
+		})
+	}
+}
+
+func TestSignAlgorithms(t *testing.T) {
+	signer, _ := newTestSigner(t, "", testKeys(t))
+	signed, err := signer.Sign([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"a=rsa-sha256", "a=ed25519-sha256", "s=rsa", "s=ed", "c=relaxed/relaxed"} {
+		if !bytes.Contains(signed, []byte(tag)) {
+			t.Errorf("signature is missing %s", tag)
+		}
+	}
+}
+
+func TestRelaxedToleratesWhitespaceChanges(t *testing.T) {
+	signer, records := newTestSigner(t, "relaxed/relaxed", testKeys(t))
+	signed, err := signer.Sign([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 中继改写头部大小写、折行和正文空白后签名仍然有效
+	modified := string(signed)
+	modified = strings.Replace(modified, "Subject: Hello\r\n\tworld", "subject:   Hello world", 1)
+	modified = strings.Replace(modified, "see  you\tsoon.", "see you soon.", 1)
+	modified = strings.Replace(modified, "Hi Alice,  \r\n", "Hi Alice,\r\n", 1)
+	for _, v := range verify(t, []byte(modified), records) {
+		if v.Err != nil {
+			t.Errorf("relaxed signature failed after whitespace change: %v", v.Err)
+		}
+	}
+
+	// 修改内容后签名失效
+	tampered := strings.Replace(string(signed), "see  you", "see  them", 1)
+	for _, v := range verify(t, []byte(tampered), records) {
+		if v.Err == nil {
+			t.Error("signature still valid after body was changed")
+		}
+	}
+}
+
+func TestSimpleRejectsWhitespaceChanges(t *testing.T) {
+	signer, records := newTestSigner(t, "simple/simple", testKeys(t))
+	signed, err := signer.Sign([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := strings.Replace(string(signed), "see  you", "see you", 1)
+	for _, v := range verify(t, []byte(modified), records) {
+		if v.Err == nil {
+			t.Error("simple body signature still valid after whitespace change")
+		}
+	}
+}
+
+func TestSignSelectsKeysByFromDomain(t *testing.T) {
+	keys := testKeys(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys = append(keys, KeyConfig{Domain: "example.net", Selector: "net", PrivateKey: pemKey(t, otherKey)})
+	signer, records := newTestSigner(t, "", keys)
+
+	// example.net 只使用自己的密钥
+	msg := strings.Replace(testMessage, "sender@example.com", "sender@EXAMPLE.net", 1)
+	signed, err := signer.Sign([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifications := verify(t, signed, records)
+	if len(verifications) != 1 {
+		t.Fatalf("got %d signatures, want 1", len(verifications))
+	}
+	if v := verifications[0]; v.Err != nil || v.Domain != "example.net" {
+		t.Errorf("got d=%s err=%v, want example.net signature", v.Domain, v.Err)
+	}
+
+	// 没有配置密钥的发件域原样返回
+	msg = strings.Replace(testMessage, "sender@example.com", "sender@example.org", 1)
+	signed, err = signer.Sign([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(signed) != msg {
+		t.Error("message from unconfigured domain was modified")
+	}
+}
+
+func TestSignSelectsBottomMostHeader(t *testing.T) {
+	signer, records := newTestSigner(t, "", testKeys(t))
+	msg := strings.Replace(testMessage, "Subject: Hello\r\n", "Subject: first\r\nSubject: Hello\r\n", 1)
+	signed, err := signer.Sign([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range verify(t, signed, records) {
+		if v.Err != nil {
+			t.Errorf("signature with repeated header failed: %v", v.Err)
+		}
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	signer, _ := newTestSigner(t, "", testKeys(t))
+	if _, err := signer.Sign([]byte("From: a@example.com\r\nSubject: x\r\n")); err == nil {
+		t.Error("expected error for message without body separator")
+	}
+	if _, err := signer.Sign([]byte("Subject: x\r\n\r\nbody\r\n")); err == nil {
+		t.Error("expected error for message without From")
+	}
+}
+
+func TestCanonicalHeaderRelaxed(t *testing.T) {
+	// RFC 6376 3.4.5 示例
+	tests := map[string]string{
+		"A: X\r\n":             "a:X\r\n",
+		"B : Y\t\r\n\tZ  \r\n": "b:Y Z\r\n",
+	}
+	for in, want := range tests {
+		if got := canonicalHeader(in, CanonRelaxed); got != want {
+			t.Errorf("canonicalHeader(%q) = %q, want %q", in, got, want)
+		}
+		if got := canonicalHeader(in, CanonSimple); got != in {
+			t.Errorf("simple canonicalHeader(%q) = %q, want unchanged", in, got)
+		}
+	}
+}
+
+func TestCanonicalBody(t *testing.T) {
+	// RFC 6376 3.4.5 示例
+	body := []byte(" C \r\nD \t E\r\n\r\n\r\n")
+	if got := string(canonicalBody(body, CanonRelaxed)); got != " C\r\nD E\r\n" {
+		t.Errorf("relaxed body = %q", got)
+	}
+	if got := string(canonicalBody(body, CanonSimple)); got != " C \r\nD \t E\r\n" {
+		t.Errorf("simple body = %q", got)
+	}
+
+	// 空正文：simple 为单个 CRLF，relaxed 为空
+	if got := string(canonicalBody(nil, CanonSimple)); got != "\r\n" {
+		t.Errorf("simple empty body = %q", got)
+	}
+	if got := canonicalBody([]byte("\r\n\r\n"), CanonRelaxed); len(got) != 0 {
+		t.Errorf("relaxed empty body = %q", got)
+	}
+}
+
+func TestParseCanonicalization(t *testing.T) {
+	tests := []struct {
+		in           string
+		header, body Canonicalization
+		err          bool
+	}{
+		{"", CanonRelaxed, CanonRelaxed, false},
+		{"relaxed", CanonRelaxed, CanonSimple, false},
+		{"Simple/Relaxed", CanonSimple, CanonRelaxed, false},
+		{"loose/simple", "", "", true},
+	}
+	for _, tt := range tests {
+		header, body, err := ParseCanonicalization(tt.in)
+		if (err != nil) != tt.err || header != tt.header || body != tt.body {
+			t.Errorf("ParseCanonicalization(%q) = %s, %s, %v", tt.in, header, body, err)
+		}
+	}
+}
+
+func TestLoadKeyAlgorithmMismatch(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadKey(KeyConfig{Domain: "example.com", Selector: "ed", Algorithm: AlgorithmRSASHA256, PrivateKey: pemKey(t, edKey)})
+	if err == nil {
+		t.Error("expected error for algorithm that does not match the key")
+	}
+}
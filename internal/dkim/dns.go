@@ -0,0 +1,29 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// RecordName 返回密钥对应的 DNS TXT 记录名称
+func (k *Key) RecordName() string {
+	return fmt.Sprintf("%s._domainkey.%s", k.Selector, k.Domain)
+}
+
+// DNSRecord 返回密钥对应的 DNS TXT 记录内容
+func (k *Key) DNSRecord() (string, error) {
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub), nil
+	}
+	return "", ErrUnsupportedKey
+}
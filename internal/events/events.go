@@ -0,0 +1,142 @@
+// Package events 发出投递事件（退信、投诉等），供业务系统订阅
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"email-service/internal/logger"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeBounced    Type = "bounced"    // 收到退信
+	TypeComplained Type = "complained" // 收件人投诉为垃圾邮件
+)
+
+// Event 投递事件
+type Event struct {
+	Type       Type      `json:"type"`
+	JobID      string    `json:"job_id,omitempty"`
+	Recipient  string    `json:"recipient"`
+	MessageID  string    `json:"message_id,omitempty"`
+	Detail     string    `json:"detail,omitempty"` // 退信原因或投诉类型
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Emitter 定义事件发送接口，实现不应阻塞调用方
+type Emitter interface {
+	Emit(ctx context.Context, event Event)
+}
+
+// Config 事件配置
+type Config struct {
+	WebhookURL string        `mapstructure:"webhook_url"` // 为空时只记录日志
+	Secret     string        `mapstructure:"secret"`      // 配置后以 HMAC-SHA256 签名请求体
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+// DefaultConfig 返回默认事件配置
+func DefaultConfig() *Config {
+	return &Config{Timeout: 10 * time.Second}
+}
+
+// NewEmitter 根据配置创建事件发送器
+func NewEmitter(cfg *Config) Emitter {
+	if cfg == nil || cfg.WebhookURL == "" {
+		return NewLogEmitter()
+	}
+	return NewWebhookEmitter(cfg)
+}
+
+// LogEmitter 只将事件写入日志
+type LogEmitter struct {
+	logger *logger.Logger
+}
+
+// NewLogEmitter 创建日志事件发送器
+func NewLogEmitter() *LogEmitter {
+	return &LogEmitter{logger: logger.GetDefault().WithComponent("events")}
+}
+
+// Emit 记录事件
+func (e *LogEmitter) Emit(ctx context.Context, event Event) {
+	e.logger.Info("Delivery event",
+		"type", event.Type,
+		"job_id", event.JobID,
+		"recipient", event.Recipient,
+		"message_id", event.MessageID,
+		"detail", event.Detail)
+}
+
+// WebhookEmitter 以 JSON POST 将事件推送到 webhook
+// 签名放在 X-Signature 头部，格式为 sha256=<hex>
+type WebhookEmitter struct {
+	url    string
+	secret string
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewWebhookEmitter 创建 webhook 事件发送器
+func NewWebhookEmitter(cfg *Config) *WebhookEmitter {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &WebhookEmitter{
+		url:    cfg.WebhookURL,
+		secret: cfg.Secret,
+		client: &http.Client{Timeout: timeout},
+		logger: logger.GetDefault().WithComponent("events"),
+	}
+}
+
+// Emit 异步推送事件，失败时只记录日志
+func (e *WebhookEmitter) Emit(ctx context.Context, event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	go func() {
+		if err := e.send(event); err != nil {
+			e.logger.Error("Failed to deliver event webhook",
+				"type", event.Type, "job_id", event.JobID, "error", err)
+		}
+	}()
+}
+
+func (e *WebhookEmitter) send(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.secret != "" {
+		mac := hmac.New(sha256.New, []byte(e.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package feedback 解析 ARF (RFC 5965) 投诉报告，标记投诉的任务并将地址加入屏蔽名单
+package feedback
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"email-service/internal/mimeutil"
+)
+
+// ErrNotARF 邮件不是 ARF 报告
+var ErrNotARF = errors.New("not an ARF feedback report")
+
+// Report 解析后的投诉报告
+type Report struct {
+	FeedbackType     string `json:"feedback_type"`                // abuse、fraud 等
+	UserAgent        string `json:"user_agent,omitempty"`         // 生成报告的系统
+	OriginalMailFrom string `json:"original_mail_from,omitempty"` // 原始邮件的信封发件人
+	Recipient        string `json:"recipient,omitempty"`          // 投诉的收件人，部分服务商会隐去
+	MessageID        string `json:"message_id,omitempty"`         // 原始邮件的 Message-ID
+}
+
+// Parse 解析 multipart/report; report-type=feedback-report 邮件
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		return nil, ErrNotARF
+	}
+
+	report := &Report{}
+	found := false
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := mimeutil.DecodeTransfer(part.Header.Get("Content-Transfer-Encoding"), part)
+		switch partType {
+		case "message/feedback-report":
+			if err := parseFeedbackFields(body, report); err != nil {
+				return nil, err
+			}
+			found = true
+		case "message/rfc822", "text/rfc822-headers":
+			parseOriginal(body, report)
+		}
+	}
+	if !found {
+		return nil, ErrNotARF
+	}
+	return report, nil
+}
+
+// parseFeedbackFields 解析 message/feedback-report 部分的字段
+func parseFeedbackFields(body io.Reader, report *Report) error {
+	fields, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	report.FeedbackType = strings.ToLower(strings.TrimSpace(fields.Get("Feedback-Type")))
+	report.UserAgent = strings.TrimSpace(fields.Get("User-Agent"))
+	report.OriginalMailFrom = trimAddress(fields.Get("Original-Mail-From"))
+	report.Recipient = trimAddress(fields.Get("Original-Rcpt-To"))
+	return nil
+}
+
+// parseOriginal 从附带的原始邮件或头部中提取 Message-ID 和收件人
+func parseOriginal(body io.Reader, report *Report) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return
+	}
+	// text/rfc822-headers 只有头部，补一个空行使其成为完整邮件
+	msg, err := mail.ReadMessage(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n")))
+	if err != nil {
+		return
+	}
+	if report.MessageID == "" {
+		report.MessageID = strings.TrimSpace(msg.Header.Get("Message-ID"))
+	}
+	if report.Recipient == "" {
+		if list, err := msg.Header.AddressList("To"); err == nil && len(list) == 1 {
+			report.Recipient = list[0].Address
+		}
+	}
+}
+
+func trimAddress(v string) string {
+	return strings.Trim(strings.TrimSpace(v), "<>")
+}
@@ -0,0 +1,166 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"email-service/internal/bounce"
+	"email-service/internal/events"
+	"email-service/internal/logger"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+)
+
+// Config 投诉报告配置
+type Config struct {
+	Poll *bounce.Config `mapstructure:"poll"` // 轮询接收投诉报告的邮箱，与退信邮箱配置相同
+}
+
+// DefaultConfig 返回默认投诉报告配置
+func DefaultConfig() *Config {
+	return &Config{Poll: bounce.DefaultConfig()}
+}
+
+// Result 投诉报告的处理结果
+type Result struct {
+	Report
+	JobID string `json:"job_id,omitempty"`
+}
+
+// Processor 处理投诉报告：标记任务为已投诉、加入屏蔽名单并发出事件
+type Processor struct {
+	statuses    status.Store
+	suppression suppression.Store
+	emitter     events.Emitter
+	decoder     bounce.ReturnPathDecoder
+	logger      *logger.Logger
+}
+
+// NewProcessor 创建投诉报告处理器
+func NewProcessor(statuses status.Store, suppressionStore suppression.Store) *Processor {
+	return &Processor{
+		statuses:    statuses,
+		suppression: suppressionStore,
+		logger:      logger.GetDefault().WithComponent("feedback"),
+	}
+}
+
+// SetEventEmitter 设置事件发送器
+func (p *Processor) SetEventEmitter(emitter events.Emitter) {
+	p.emitter = emitter
+}
+
+// SetReturnPathDecoder 设置信封发件人解析函数，用于按 VERP 地址关联任务
+func (p *Processor) SetReturnPathDecoder(decoder bounce.ReturnPathDecoder) {
+	p.decoder = decoder
+}
+
+// Process 解析并处理一份投诉报告
+func (p *Processor) Process(ctx context.Context, raw []byte) (*Result, error) {
+	report, err := Parse(raw)
+	if err != nil {
+		if !errors.Is(err, ErrNotARF) {
+			err = fmt.Errorf("%w: %w", ErrNotARF, err)
+		}
+		return nil, err
+	}
+
+	result := &Result{Report: *report}
+	job := p.correlate(ctx, report)
+	if job != nil {
+		result.JobID = job.JobID
+		if result.Recipient == "" {
+			result.Recipient = job.Recipient
+		}
+		job.State = status.StateComplained
+		job.UpdatedAt = time.Now()
+		if err := p.statuses.Save(ctx, job); err != nil {
+			return nil, fmt.Errorf("failed to record complaint: %w", err)
+		}
+	}
+
+	p.logger.Info("Complaint received",
+		"feedback_type", report.FeedbackType,
+		"recipient", result.Recipient,
+		"message_id", report.MessageID,
+		"job_id", result.JobID)
+
+	if result.Recipient == "" {
+		p.logger.Warn("Complaint without identifiable recipient", "message_id", report.MessageID)
+		return result, nil
+	}
+
+	if p.suppression != nil {
+		err := p.suppression.Add(ctx, suppression.Entry{
+			Address: result.Recipient,
+			Reason:  suppression.ReasonComplaint,
+			Detail:  report.FeedbackType,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to suppress complainant: %w", err)
+		}
+	}
+
+	if p.emitter != nil {
+		p.emitter.Emit(ctx, events.Event{
+			Type:       events.TypeComplained,
+			JobID:      result.JobID,
+			Recipient:  result.Recipient,
+			MessageID:  report.MessageID,
+			Detail:     report.FeedbackType,
+			OccurredAt: time.Now(),
+		})
+	}
+	return result, nil
+}
+
+// Run 按间隔轮询投诉邮箱，直到 ctx 取消；无法解析的邮件视为已处理
+func (p *Processor) Run(ctx context.Context, mailbox bounce.Mailbox, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := mailbox.Poll(ctx, func(raw []byte) error {
+			_, err := p.Process(ctx, raw)
+			if errors.Is(err, ErrNotARF) {
+				p.logger.Debug("Ignoring message that is not a feedback report", "error", err)
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			p.logger.Error("Failed to poll feedback mailbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// correlate 按原始 Message-ID 或 VERP 信封发件人关联任务
+func (p *Processor) correlate(ctx context.Context, report *Report) *status.JobStatus {
+	if p.statuses == nil {
+		return nil
+	}
+	if report.MessageID != "" {
+		if st, err := p.statuses.GetByMessageID(ctx, report.MessageID); err == nil {
+			return st
+		}
+	}
+	if p.decoder != nil && report.OriginalMailFrom != "" {
+		if id, ok := p.decoder(report.OriginalMailFrom); ok {
+			if st, err := p.statuses.Get(ctx, id); err == nil {
+				return st
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package idempotency 记录带幂等键的请求及其结果，调用方超时重试时返回首次请求的结果而不重复入队
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Record 幂等键对应的记录
+type Record struct {
+	Fingerprint string          `json:"fingerprint"`           // 请求内容的摘要，用于识别复用键的不同请求
+	Completed   bool            `json:"completed"`             // 请求是否已处理完毕
+	StatusCode  int             `json:"status_code,omitempty"` // 首次请求的响应状态码
+	Body        json.RawMessage `json:"body,omitempty"`        // 首次请求的响应内容
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Store 定义幂等记录存储接口，记录在 TTL 后过期
+type Store interface {
+	// Reserve 原子地占用键；键不存在时写入处理中的记录并返回 nil，已存在时返回已有记录且不做修改
+	Reserve(ctx context.Context, key, fingerprint string) (*Record, error)
+
+	// Complete 保存请求的处理结果
+	Complete(ctx context.Context, key string, record Record) error
+
+	// Release 删除键，请求未产生副作用时调用，使调用方可以重试
+	Release(ctx context.Context, key string) error
+}
+
+// Config 幂等记录存储配置
+type Config struct {
+	Type  string        `mapstructure:"type"` // memory 或 redis
+	TTL   time.Duration `mapstructure:"ttl"`  // 幂等窗口
+	Redis *RedisConfig  `mapstructure:"redis"`
+}
+
+// RedisConfig Redis 幂等记录存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	TypeMemory = "memory"
+	TypeRedis  = "redis"
+)
+
+// DefaultConfig 返回默认幂等记录存储配置
+func DefaultConfig() *Config {
+	return &Config{
+		Type: TypeMemory,
+		TTL:  24 * time.Hour,
+	}
+}
+
+// NewStore 根据配置创建幂等记录存储
+func NewStore(cfg *Config) (Store, error) {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultConfig().TTL
+	}
+	switch cfg.Type {
+	case TypeMemory, "":
+		return NewMemoryStore(ttl), nil
+	case TypeRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("idempotency: redis config is required")
+		}
+		return NewRedisStore(cfg.Redis, ttl)
+	default:
+		return nil, fmt.Errorf("idempotency: unsupported store type %q", cfg.Type)
+	}
+}
+
+// Fingerprint 计算请求内容的摘要，map 的键按字典序编码，相同内容得到相同摘要
+func Fingerprint(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
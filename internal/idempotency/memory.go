@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	record    Record
+	expiresAt time.Time
+}
+
+// MemoryStore 内存幂等记录存储，只在单实例内有效
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	ttl     time.Duration
+}
+
+// NewMemoryStore 创建内存幂等记录存储
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		ttl:     ttl,
+	}
+}
+
+// Reserve 占用键
+func (s *MemoryStore) Reserve(ctx context.Context, key, fingerprint string) (*Record, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		record := entry.record
+		return &record, nil
+	}
+	s.entries[key] = memoryEntry{
+		record:    Record{Fingerprint: fingerprint, CreatedAt: now},
+		expiresAt: now.Add(s.ttl),
+	}
+	return nil, nil
+}
+
+// Complete 保存处理结果
+func (s *MemoryStore) Complete(ctx context.Context, key string, record Record) error {
+	record.Completed = true
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(s.ttl)
+	if entry, ok := s.entries[key]; ok {
+		expiresAt = entry.expiresAt
+	}
+	s.entries[key] = memoryEntry{record: record, expiresAt: expiresAt}
+	return nil
+}
+
+// Release 删除键
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Prune 删除过期记录，返回删除数量
+func (s *MemoryStore) Prune() int {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunPrune 周期性清理过期记录，直到 ctx 结束
+func (s *MemoryStore) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
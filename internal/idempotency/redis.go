@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于 Redis 的幂等记录存储，多个实例共享，每个键保存一条 JSON 记录
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisStore 创建 Redis 幂等记录存储
+func NewRedisStore(config *RedisConfig, ttl time.Duration) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:idempotency:"
+	}
+	return &RedisStore{client: client, prefix: prefix, ttl: ttl}, nil
+}
+
+// Reserve 通过 SET NX 占用键
+func (s *RedisStore) Reserve(ctx context.Context, key, fingerprint string) (*Record, error) {
+	data, err := json.Marshal(Record{Fingerprint: fingerprint, CreatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有记录恰好在 SET NX 与 GET 之间过期时重新占用
+	for i := 0; i < 2; i++ {
+		ok, err := s.client.SetNX(ctx, s.prefix+key, data, s.ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+
+		existing, err := s.client.Get(ctx, s.prefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var record Record
+		if err := json.Unmarshal(existing, &record); err != nil {
+			return nil, fmt.Errorf("idempotency: invalid record for %s: %w", key, err)
+		}
+		return &record, nil
+	}
+	return nil, fmt.Errorf("idempotency: failed to reserve %s", key)
+}
+
+// Complete 保存处理结果，过期时间从保存时重新计算
+func (s *RedisStore) Complete(ctx context.Context, key string, record Record) error {
+	record.Completed = true
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, s.ttl).Err()
+}
+
+// Release 删除键
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -4,7 +4,17 @@ import (
 	"context"
 	"time"
 
+	"email-service/internal/attachment"
+	"email-service/internal/campaign"
+	"email-service/internal/dkim"
 	"email-service/internal/logger"
+	"email-service/internal/pgp"
+	"email-service/internal/quiethours"
+	"email-service/internal/smime"
+	"email-service/internal/status"
+	"email-service/internal/suppression"
+	"email-service/internal/unsubscribe"
+	"email-service/internal/verp"
 	"email-service/pkg/jobqueue"
 
 	"gopkg.in/gomail.v2"
@@ -16,6 +26,16 @@ type Dispatcher struct {
 	maxWorkers   int                    // 最大工人数量
 	jobQueue     jobqueue.JobQueue      // 任务队列
 	retryManager *jobqueue.RetryManager // 重试管理器
+	attachments  attachment.Store       // 附件存储
+	dkimSigner   *dkim.Signer           // DKIM 签名器
+	smime        *smime.Service         // S/MIME 签名与加密
+	pgp          *pgp.Service           // PGP/MIME 签名与加密
+	statuses     status.Store           // 任务状态存储
+	unsubscribe  *unsubscribe.Service   // 退订头部生成
+	suppression  suppression.Store      // 硬退信自动加入的屏蔽名单
+	verp         *verp.Encoder          // 按任务生成信封发件人
+	quietHours   *quiethours.Service    // 收件人投递时段
+	campaigns    *campaign.Service      // 批量发送活动
 	ctx          context.Context
 	cancel       context.CancelFunc
 	logger       *logger.Logger
@@ -35,11 +55,70 @@ func NewDispatcher(dialer *gomail.Dialer, maxWorkers int, jobQueue jobqueue.JobQ
 	}
 }
 
+// SetAttachmentStore 设置附件存储，需在 Run 之前调用
+func (d *Dispatcher) SetAttachmentStore(store attachment.Store) {
+	d.attachments = store
+}
+
+// SetDKIMSigner 设置 DKIM 签名器，需在 Run 之前调用
+func (d *Dispatcher) SetDKIMSigner(signer *dkim.Signer) {
+	d.dkimSigner = signer
+}
+
+// SetSMIME 设置 S/MIME 服务，需在 Run 之前调用
+func (d *Dispatcher) SetSMIME(service *smime.Service) {
+	d.smime = service
+}
+
+// SetPGP 设置 PGP 服务，需在 Run 之前调用
+func (d *Dispatcher) SetPGP(service *pgp.Service) {
+	d.pgp = service
+}
+
+// SetStatusStore 设置任务状态存储，需在 Run 之前调用
+func (d *Dispatcher) SetStatusStore(store status.Store) {
+	d.statuses = store
+}
+
+// SetUnsubscribe 设置退订服务，需在 Run 之前调用
+func (d *Dispatcher) SetUnsubscribe(service *unsubscribe.Service) {
+	d.unsubscribe = service
+}
+
+// SetVERP 设置 VERP 编码器，需在 Run 之前调用
+func (d *Dispatcher) SetVERP(encoder *verp.Encoder) {
+	d.verp = encoder
+}
+
+// SetQuietHours 设置免打扰服务，需在 Run 之前调用
+func (d *Dispatcher) SetQuietHours(service *quiethours.Service) {
+	d.quietHours = service
+}
+
+// SetCampaigns 设置活动服务，需在 Run 之前调用
+func (d *Dispatcher) SetCampaigns(service *campaign.Service) {
+	d.campaigns = service
+}
+
+// SetSuppressionStore 设置屏蔽名单，设置后硬退信的地址会被自动加入名单
+func (d *Dispatcher) SetSuppressionStore(store suppression.Store) {
+	d.suppression = store
+}
+
 // Run 启动调度器，创建并运行所有工人
 func (d *Dispatcher) Run() {
 	for i := 1; i <= d.maxWorkers; i++ {
 		worker := NewWorker(i, d.dialer, d.jobQueue, d.retryManager, d.ctx)
 		worker.SetRetryScheduler(d) // 设置调度器作为重试调度器
+		worker.SetAttachmentStore(d.attachments)
+		worker.SetDKIMSigner(d.dkimSigner)
+		worker.SetSMIME(d.smime)
+		worker.SetPGP(d.pgp)
+		worker.SetStatusStore(d.statuses)
+		worker.SetUnsubscribe(d.unsubscribe)
+		worker.SetVERP(d.verp)
+		worker.SetQuietHours(d.quietHours)
+		worker.SetCampaigns(d.campaigns)
 		worker.Start()
 	}
 	d.logger.Info("Workers started and ready to process jobs", "worker_count", d.maxWorkers)
@@ -54,41 +133,67 @@ func (d *Dispatcher) Stop() {
 	}
 }
 
-// PushJob 将任务推入队列
+// PushJob 将任务推入队列，入队成功后记录为 queued 状态
 func (d *Dispatcher) PushJob(job EmailJob) error {
-	return d.jobQueue.Push(d.ctx, job)
+	if err := d.jobQueue.Push(d.ctx, job); err != nil {
+		return err
+	}
+	recordStatus(d.ctx, d.statuses, &job, status.StateQueued, d.dialer.Username, d.logger)
+	return nil
 }
 
 // ScheduleRetry 安排任务重试
-func (d *Dispatcher) ScheduleRetry(job *jobqueue.EmailJob, err error) {
-	if !d.retryManager.ShouldRetry(job) {
+// 重试任务立即入队，工人取出后会按 NextRetryAt 延迟处理，从而在原任务确认前持久化
+func (d *Dispatcher) ScheduleRetry(job *jobqueue.EmailJob, err error) error {
+	if IsPermanent(err) || !d.retryManager.ShouldRetry(job) {
+		job.LastError = err.Error()
 		d.logger.Error("Task failed permanently",
 			"recipient", job.To,
 			"retry_count", job.RetryCount,
+			"permanent_error", IsPermanent(err),
 			"error", err)
-		return
+		recordStatus(d.ctx, d.statuses, job, status.StateFailed, d.dialer.Username, d.logger)
+		releaseAttachments(d.ctx, d.attachments, job, d.logger)
+		if IsHardBounce(err) {
+			d.suppressHardBounce(job, err)
+		}
+		return nil
 	}
 
 	// 准备重试
 	retryJob := d.retryManager.PrepareRetry(job, err)
+	recordStatus(d.ctx, d.statuses, retryJob, status.StateRetrying, d.dialer.Username, d.logger)
 
-	// 计算延迟时间
-	delay := time.Until(retryJob.NextRetryAt)
-
-	d.logger.LogRetryScheduled(retryJob.To, retryJob.RetryCount, retryJob.MaxRetries, delay)
-
-	// 使用定时器安排重试
-	time.AfterFunc(delay, func() {
-		if err := d.jobQueue.Push(d.ctx, *retryJob); err != nil {
-			d.logger.Error("Failed to reschedule retry",
-				"recipient", retryJob.To,
-				"retry_count", retryJob.RetryCount,
-				"error", err)
-		} else {
-			d.logger.Debug("Retry rescheduled successfully",
-				"recipient", retryJob.To,
-				"retry_count", retryJob.RetryCount,
-				"max_retries", retryJob.MaxRetries)
-		}
-	})
+	d.logger.LogRetryScheduled(retryJob.To, retryJob.RetryCount, retryJob.MaxRetries, time.Until(retryJob.NextRetryAt))
+
+	// 使用独立的 context，停机时也要保证重试任务入队
+	if err := d.jobQueue.Push(context.Background(), *retryJob); err != nil {
+		d.logger.Error("Failed to reschedule retry",
+			"recipient", retryJob.To,
+			"retry_count", retryJob.RetryCount,
+			"error", err)
+		return err
+	}
+	d.logger.Debug("Retry rescheduled successfully",
+		"recipient", retryJob.To,
+		"retry_count", retryJob.RetryCount,
+		"max_retries", retryJob.MaxRetries)
+	return nil
+}
+
+// suppressHardBounce 将硬退信的收件地址加入屏蔽名单
+func (d *Dispatcher) suppressHardBounce(job *jobqueue.EmailJob, err error) {
+	if d.suppression == nil {
+		return
+	}
+	entry := suppression.Entry{
+		Address: job.To,
+		Reason:  suppression.ReasonHardBounce,
+		Detail:  err.Error(),
+	}
+	if err := d.suppression.Add(d.ctx, entry); err != nil {
+		d.logger.Error("Failed to suppress hard-bounced address", "recipient", job.To, "error", err)
+		return
+	}
+	d.logger.Info("Address suppressed after hard bounce", "recipient", job.To)
 }
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+
+	"email-service/internal/smtpstatus"
+)
+
+// ErrPermanent 标记不应重试的永久性失败
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent 将错误标记为永久性失败
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// IsPermanent 判断错误是否为永久性失败
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// ErrHardBounce 标记收件地址本身无效的永久性失败（如邮箱不存在）
+var ErrHardBounce = errors.New("hard bounce")
+
+// IsHardBounce 判断错误是否为硬退信
+func IsHardBounce(err error) bool {
+	return errors.Is(err, ErrHardBounce)
+}
+
+// classifySMTPError 按 SMTP 响应码对发送错误分类
+// 5xx 响应标记为永久性失败；其中指向收件地址的响应同时标记为硬退信
+func classifySMTPError(err error) error {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) || tpErr.Code < 500 || tpErr.Code > 599 {
+		return err
+	}
+	if smtpstatus.IsAddressFailure(tpErr.Code, smtpstatus.Enhanced(tpErr.Msg)) {
+		return fmt.Errorf("%w: %w: %w", ErrPermanent, ErrHardBounce, err)
+	}
+	return Permanent(err)
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+
+	"email-service/pkg/jobqueue"
+
+	"gopkg.in/gomail.v2"
+)
+
+// MessageID 根据任务ID和发件域生成确定的 Message-ID，重试时保持不变
+func MessageID(jobID, from string) string {
+	domain := from
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", jobID, strings.ToLower(domain))
+}
+
+// normalizeMessageID 为缺少尖括号的 Message-ID 补全尖括号
+func normalizeMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.HasPrefix(id, "<") {
+		return id
+	}
+	return "<" + id + ">"
+}
+
+// setThreadingHeaders 设置 Message-ID 以及 In-Reply-To / References 会话头部
+func setThreadingHeaders(m *gomail.Message, messageID string, job *jobqueue.EmailJob) {
+	m.SetHeader("Message-ID", messageID)
+
+	var refs []string
+	seen := make(map[string]bool)
+	for _, ref := range job.References {
+		ref = normalizeMessageID(ref)
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	if inReplyTo := normalizeMessageID(job.InReplyTo); inReplyTo != "" {
+		m.SetHeader("In-Reply-To", inReplyTo)
+		// References 应以父邮件的 Message-ID 结尾 (RFC 5322 3.6.4)
+		if !seen[inReplyTo] {
+			refs = append(refs, inReplyTo)
+		}
+	}
+	if len(refs) > 0 {
+		m.SetHeader("References", strings.Join(refs, " "))
+	}
+}
@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+
+	"email-service/internal/pgp"
+	"email-service/internal/smime"
+	"email-service/pkg/jobqueue"
+)
+
+// secureMessage 按任务要求对邮件原文进行签名和加密
+func (w *Worker) secureMessage(job *jobqueue.EmailJob, raw []byte) ([]byte, error) {
+	if job.Sign == "" && job.Encrypt == "" {
+		return raw, nil
+	}
+	if job.Sign != "" && job.Encrypt != "" && job.Sign != job.Encrypt {
+		return nil, Permanent(fmt.Errorf("cannot combine %s signing with %s encryption", job.Sign, job.Encrypt))
+	}
+
+	method := job.Encrypt
+	if method == "" {
+		method = job.Sign
+	}
+
+	switch method {
+	case jobqueue.SecuritySMIME:
+		return w.secureSMIME(job, raw)
+	case jobqueue.SecurityPGP:
+		return w.securePGP(job, raw)
+	default:
+		return nil, Permanent(fmt.Errorf("unsupported security method %q", method))
+	}
+}
+
+// secureSMIME 先 S/MIME 签名后加密
+func (w *Worker) secureSMIME(job *jobqueue.EmailJob, raw []byte) ([]byte, error) {
+	if w.smime == nil {
+		return nil, Permanent(errors.New("S/MIME requested but not configured"))
+	}
+
+	var err error
+	if job.Sign != "" {
+		if raw, err = w.smime.Sign(raw); err != nil {
+			return nil, Permanent(err)
+		}
+	}
+	if job.Encrypt != "" {
+		if raw, err = w.smime.Encrypt(raw, []string{job.To}); err != nil {
+			if errors.Is(err, smime.ErrNoRecipientCert) {
+				return nil, Permanent(err)
+			}
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// securePGP 生成 PGP/MIME 邮件，同时签名和加密时使用合并方式
+func (w *Worker) securePGP(job *jobqueue.EmailJob, raw []byte) ([]byte, error) {
+	if w.pgp == nil {
+		return nil, Permanent(errors.New("PGP requested but not configured"))
+	}
+
+	if job.Encrypt == "" {
+		signed, err := w.pgp.Sign(raw)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+		return signed, nil
+	}
+
+	encrypted, err := w.pgp.Encrypt(raw, []string{job.To}, job.Sign != "")
+	if err != nil {
+		if errors.Is(err, pgp.ErrNoPublicKey) || errors.Is(err, pgp.ErrInvalidKey) || errors.Is(err, pgp.ErrNoSigningKey) {
+			return nil, Permanent(err)
+		}
+		return nil, err
+	}
+	return encrypted, nil
+}
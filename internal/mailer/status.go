@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/status"
+	"email-service/pkg/jobqueue"
+)
+
+// recordStatus 记录任务状态，未配置状态存储时忽略
+func recordStatus(ctx context.Context, store status.Store, job *jobqueue.EmailJob, state status.State, from string, log *logger.Logger) {
+	if store == nil || job.ID == "" {
+		return
+	}
+
+	st := &status.JobStatus{
+		JobID:      job.ID,
+		Recipient:  job.To,
+		Subject:    job.Subject,
+		State:      state,
+		MessageID:  MessageID(job.ID, from),
+		RetryCount: job.RetryCount,
+		LastError:  job.LastError,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  time.Now(),
+	}
+	if err := store.Save(ctx, st); err != nil {
+		log.Warn("Failed to record job status", "job_id", job.ID, "state", state, "error", err)
+	}
+}
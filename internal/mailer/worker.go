@@ -11,15 +11,28 @@ import (
 	"net/http"
 	"time"
 
+	"email-service/internal/attachment"
+	"email-service/internal/campaign"
+	"email-service/internal/dkim"
 	"email-service/internal/logger"
+	"email-service/internal/pgp"
+	"email-service/internal/quiethours"
+	"email-service/internal/smime"
+	"email-service/internal/status"
+	"email-service/internal/unsubscribe"
+	"email-service/internal/verp"
 	"email-service/pkg/jobqueue"
 
 	"gopkg.in/gomail.v2"
 )
 
+// keepAliveInterval 处理任务期间续期的间隔
+const keepAliveInterval = 30 * time.Second
+
 // RetryScheduler 定义重试调度接口
+// 返回错误表示重试任务未能入队，调用方不应确认原任务
 type RetryScheduler interface {
-	ScheduleRetry(job *jobqueue.EmailJob, err error)
+	ScheduleRetry(job *jobqueue.EmailJob, err error) error
 }
 
 // Worker 负责从任务队列中取出并处理邮件任务
@@ -29,6 +42,15 @@ type Worker struct {
 	jobQueue       jobqueue.JobQueue      // 任务队列
 	retryManager   *jobqueue.RetryManager // 重试管理器
 	retryScheduler RetryScheduler         // 重试调度器
+	attachments    attachment.Store       // 附件存储
+	dkimSigner     *dkim.Signer           // DKIM 签名器，为空时不签名
+	smime          *smime.Service         // S/MIME 签名与加密
+	pgp            *pgp.Service           // PGP/MIME 签名与加密
+	statuses       status.Store           // 任务状态存储
+	unsubscribe    *unsubscribe.Service   // 退订头部生成
+	verp           *verp.Encoder          // 按任务生成信封发件人
+	quietHours     *quiethours.Service    // 收件人投递时段
+	campaigns      *campaign.Service      // 批量发送活动，用于暂停和取消已入队的任务
 	ctx            context.Context
 	logger         *logger.Logger
 }
@@ -50,6 +72,51 @@ func (w *Worker) SetRetryScheduler(scheduler RetryScheduler) {
 	w.retryScheduler = scheduler
 }
 
+// SetAttachmentStore 设置附件存储，用于解析通过ID引用的附件
+func (w *Worker) SetAttachmentStore(store attachment.Store) {
+	w.attachments = store
+}
+
+// SetDKIMSigner 设置 DKIM 签名器
+func (w *Worker) SetDKIMSigner(signer *dkim.Signer) {
+	w.dkimSigner = signer
+}
+
+// SetSMIME 设置 S/MIME 服务
+func (w *Worker) SetSMIME(service *smime.Service) {
+	w.smime = service
+}
+
+// SetPGP 设置 PGP 服务
+func (w *Worker) SetPGP(service *pgp.Service) {
+	w.pgp = service
+}
+
+// SetStatusStore 设置任务状态存储
+func (w *Worker) SetStatusStore(store status.Store) {
+	w.statuses = store
+}
+
+// SetUnsubscribe 设置退订服务
+func (w *Worker) SetUnsubscribe(service *unsubscribe.Service) {
+	w.unsubscribe = service
+}
+
+// SetVERP 设置 VERP 编码器，设置后信封发件人按任务生成
+func (w *Worker) SetVERP(encoder *verp.Encoder) {
+	w.verp = encoder
+}
+
+// SetQuietHours 设置免打扰服务，设置后投递时段之外的任务推迟到时段开始
+func (w *Worker) SetQuietHours(service *quiethours.Service) {
+	w.quietHours = service
+}
+
+// SetCampaigns 设置活动服务，设置后暂停的活动的任务被推迟，取消的活动的任务被丢弃
+func (w *Worker) SetCampaigns(service *campaign.Service) {
+	w.campaigns = service
+}
+
 // Start 启动工人，使其开始监听任务
 func (w *Worker) Start() {
 	go w.processJobs()
@@ -63,7 +130,7 @@ func (w *Worker) processJobs() {
 			w.logger.Info("Worker shutting down")
 			return
 		default:
-			job, err := w.jobQueue.Pop(w.ctx)
+			delivery, err := w.jobQueue.Pop(w.ctx)
 			if err != nil {
 				// 如果是超时或上下文取消，继续循环
 				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -74,36 +141,118 @@ func (w *Worker) processJobs() {
 				time.Sleep(time.Second)
 				continue
 			}
+			w.handleDelivery(delivery)
+		}
+	}
+}
 
-			// 检查是否到了重试时间
-			if !w.retryManager.IsReadyForRetry(&job) {
-				// 还没到重试时间，重新放回队列
-				delay := time.Until(job.NextRetryAt)
-				w.logger.Debug("Job not ready for retry, delaying",
-					"recipient", job.To,
-					"delay", delay.Round(time.Second))
-
-				// 延迟后重新入队
-				time.AfterFunc(delay, func() {
-					if err = w.jobQueue.Push(w.ctx, job); err != nil {
-						w.logger.Error("Failed to requeue delayed job",
-							"recipient", job.To,
-							"error", err)
-					}
-				})
-				continue
+// handleDelivery 处理取出的任务，SMTP 服务器接受邮件或失败已妥善记录后才确认
+// 确认使用独立的 context，避免停机时已完成的任务因确认失败而被重复发送
+func (w *Worker) handleDelivery(delivery jobqueue.Delivery) {
+	job := delivery.Job()
+
+	// 检查是否到了重试时间
+	if !w.retryManager.IsReadyForRetry(&job) {
+		// 还没到重试时间，延迟后重新入队
+		delay := time.Until(job.NextRetryAt)
+		w.logger.Debug("Job not ready for retry, delaying",
+			"recipient", job.To,
+			"delay", delay.Round(time.Second))
+		if err := jobqueue.Defer(context.Background(), delivery, delay); err != nil {
+			w.logger.Error("Failed to requeue delayed job", "recipient", job.To, "error", err)
+		}
+		return
+	}
+
+	// 检查所属的活动是否已暂停或取消
+	if w.campaigns != nil && job.Campaign != "" {
+		delay, drop := w.campaigns.Hold(w.ctx, job)
+		if drop {
+			w.logger.Info("Dropping job of cancelled campaign", "recipient", job.To, "job_id", job.ID, "campaign_id", job.Campaign)
+			job.LastError = "campaign cancelled"
+			recordStatus(w.ctx, w.statuses, &job, status.StateFailed, w.dialer.Username, w.logger)
+			releaseAttachments(w.ctx, w.attachments, &job, w.logger)
+			if err := delivery.Ack(context.Background()); err != nil {
+				w.logger.Error("Failed to ack job", "recipient", job.To, "job_id", job.ID, "error", err)
+			}
+			return
+		}
+		if delay > 0 {
+			w.logger.Debug("Campaign paused, delaying job", "recipient", job.To, "job_id", job.ID, "campaign_id", job.Campaign)
+			if err := jobqueue.Defer(context.Background(), delivery, delay); err != nil {
+				w.logger.Error("Failed to requeue paused job", "recipient", job.To, "job_id", job.ID, "error", err)
+			}
+			return
+		}
+	}
+
+	// 检查是否处于收件人的投递时段
+	if w.quietHours != nil {
+		if delay := w.quietHours.Delay(w.ctx, job, time.Now()); delay > 0 {
+			w.logger.Info("Deferring job until delivery window opens",
+				"recipient", job.To,
+				"job_id", job.ID,
+				"category", job.Category,
+				"delay", delay.Round(time.Second))
+			if err := jobqueue.Defer(context.Background(), delivery, delay); err != nil {
+				w.logger.Error("Failed to requeue deferred job", "recipient", job.To, "job_id", job.ID, "error", err)
 			}
+			return
+		}
+	}
 
-			// 处理任务
-			w.processJob(job)
+	stop := w.keepAlive(delivery)
+	err := w.processJob(job)
+	stop()
+
+	if err != nil {
+		delay := w.retryManager.CalculateNextRetryDelay(job.RetryCount)
+		w.logger.Error("Failed to hand off job, requeueing",
+			"recipient", job.To,
+			"job_id", job.ID,
+			"delay", delay,
+			"error", err)
+		if err := delivery.Nack(context.Background(), delay); err != nil {
+			w.logger.Error("Failed to requeue job", "recipient", job.To, "job_id", job.ID, "error", err)
 		}
+		return
+	}
+
+	if err := delivery.Ack(context.Background()); err != nil {
+		w.logger.Error("Failed to ack job", "recipient", job.To, "job_id", job.ID, "error", err)
 	}
 }
 
+// keepAlive 在任务处理期间定期续期，避免慢速 SMTP 会话超过可见性超时后被重复投递
+func (w *Worker) keepAlive(delivery jobqueue.Delivery) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := delivery.Extend(context.Background()); err != nil {
+					w.logger.Warn("Failed to extend job visibility", "job_id", delivery.Job().ID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
 // processJob 处理单个邮件发送任务
-func (w *Worker) processJob(job jobqueue.EmailJob) {
+// 发送成功或失败已交给重试调度器时返回 nil；返回错误表示任务未被妥善处理，需要重新投递
+func (w *Worker) processJob(job jobqueue.EmailJob) error {
 	startTime := time.Now()
 
+	// 兼容没有任务ID的旧任务
+	if job.ID == "" {
+		job.ID = jobqueue.NewJobID()
+	}
+
 	// ====== 增加日志记录，追踪任务处理开始 ======
 	w.logger.Info("Starting to process job", "to", job.To)
 	// ====== end ======
@@ -139,50 +288,138 @@ func (w *Worker) processJob(job jobqueue.EmailJob) {
 	m.SetHeader("From", w.dialer.Username)
 	m.SetHeader("To", job.To)
 	m.SetHeader("Subject", job.Subject)
+	setThreadingHeaders(m, MessageID(job.ID, w.dialer.Username), &job)
+	if w.unsubscribe != nil && w.unsubscribe.Applies(job.Category) {
+		for name, value := range w.unsubscribe.Headers(job.To, job.Category) {
+			m.SetHeader(name, value)
+		}
+	}
 	m.SetBody("text/html", job.Body)
 
-	// 处理附件
-	w.processAttachments(&job, m)
+	// 处理附件，附件缺失时不发送不完整的邮件
+	if err := w.processAttachments(&job, m); err != nil {
+		w.logger.Error("Failed to attach files", "to", job.To, "job_id", job.ID, "error", err)
+		return w.retryScheduler.ScheduleRetry(&job, err)
+	}
 
 	// 发送邮件
-	err := w.sendEmail(m)
+	err := w.sendEmail(&job, w.envelopeFrom(&job), []string{job.To}, m)
 	duration := time.Since(startTime)
 
 	if err != nil {
-		w.logger.Error("Failed to send email", "to", job.To, "duration", duration, "error", err)
-		w.retryScheduler.ScheduleRetry(&job, err)
-		return
+		w.logger.Error("Failed to send email", "to", job.To, "job_id", job.ID, "duration", duration, "error", err)
+		return w.retryScheduler.ScheduleRetry(&job, err)
+	}
+
+	w.logger.Info("Successfully sent email", "to", job.To, "job_id", job.ID, "duration", duration)
+	recordStatus(w.ctx, w.statuses, &job, status.StateSent, w.dialer.Username, w.logger)
+	releaseAttachments(w.ctx, w.attachments, &job, w.logger)
+	return nil
+}
+
+// envelopeFrom 返回 SMTP MAIL FROM 使用的信封发件人，启用 VERP 时按任务生成
+func (w *Worker) envelopeFrom(job *jobqueue.EmailJob) string {
+	if w.verp != nil && job.ID != "" {
+		return w.verp.Address(job.ID)
 	}
+	return w.dialer.Username
+}
 
-	w.logger.Info("Successfully sent email", "to", job.To, "duration", duration)
+// sendEmail 封装了实际的邮件发送逻辑：序列化、签名后通过 SMTP 发送
+func (w *Worker) sendEmail(job *jobqueue.EmailJob, from string, to []string, m *gomail.Message) error {
+	raw, err := w.encodeMessage(job, m)
+	if err != nil {
+		return err
+	}
+	return w.transmit(from, to, raw)
 }
 
-// sendEmail 封装了实际的邮件发送逻辑
-func (w *Worker) sendEmail(m *gomail.Message) error {
-	// DialAndSend 会处理连接、认证和发送的整个过程
-	return w.dialer.DialAndSend(m)
+// encodeMessage 将邮件序列化为原文，按任务要求签名加密，并在配置了 DKIM 时签名
+func (w *Worker) encodeMessage(job *jobqueue.EmailJob, m *gomail.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	raw, err := w.secureMessage(job, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if w.dkimSigner != nil {
+		signed, err := w.dkimSigner.Sign(raw)
+		if err != nil {
+			return nil, err
+		}
+		raw = signed
+	}
+	return raw, nil
+}
+
+// transmit 建立 SMTP 连接并以指定信封发送邮件原文
+func (w *Worker) transmit(from string, to []string, raw []byte) error {
+	s, err := w.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = s.Close(); err != nil {
+			w.logger.Debug("Failed to close SMTP connection", "error", err)
+		}
+	}()
+	return classifySMTPError(s.Send(from, to, bytes.NewReader(raw)))
 }
 
 // processAttachments 处理附件
-func (w *Worker) processAttachments(job *jobqueue.EmailJob, m *gomail.Message) {
+// 已上传的附件不存在或已过期时返回永久性错误，其他错误可以重试
+func (w *Worker) processAttachments(job *jobqueue.EmailJob, m *gomail.Message) error {
 	for _, att := range job.Attachments {
 		data, err := w.getAttachmentData(&att)
 		if err != nil {
-			w.logger.Error("Failed to get attachment data", "filename", att.Filename, "url", att.URL, "error", err)
-			continue
+			err = fmt.Errorf("attachment %q: %w", attachmentName(&att), err)
+			if errors.Is(err, attachment.ErrNotFound) || errors.Is(err, attachment.ErrExpired) {
+				return Permanent(err)
+			}
+			return err
 		}
 
 		if len(data) > 0 {
 			m.Attach(att.Filename, gomail.SetCopyFunc(func(writer io.Writer) error {
-				_, err = writer.Write(data)
+				_, err := writer.Write(data)
 				return err
 			}))
 		}
 	}
+	return nil
+}
+
+// attachmentName 返回日志和错误信息中使用的附件名称
+func attachmentName(att *jobqueue.Attachment) string {
+	switch {
+	case att.Filename != "":
+		return att.Filename
+	case att.ID != "":
+		return att.ID
+	default:
+		return att.URL
+	}
 }
 
 // getAttachmentData 根据附件定义获取附件数据
 func (w *Worker) getAttachmentData(att *jobqueue.Attachment) ([]byte, error) {
+	if att.ID != "" {
+		if w.attachments == nil {
+			return nil, errors.New("attachment store not configured")
+		}
+		meta, data, err := w.attachments.Open(w.ctx, att.ID)
+		if err != nil {
+			return nil, err
+		}
+		if att.Filename == "" {
+			att.Filename = meta.Filename
+		}
+		return data, nil
+	}
 	if att.URL != "" {
 		return w.downloadAttachment(att.URL)
 	}
@@ -215,3 +452,18 @@ func (w *Worker) downloadAttachment(url string) ([]byte, error) {
 
 	return io.ReadAll(resp.Body)
 }
+
+// releaseAttachments 任务结束后释放其引用的已上传附件
+func releaseAttachments(ctx context.Context, store attachment.Store, job *jobqueue.EmailJob, log *logger.Logger) {
+	if store == nil {
+		return
+	}
+	for _, att := range job.Attachments {
+		if att.ID == "" {
+			continue
+		}
+		if err := store.Release(ctx, att.ID); err != nil {
+			log.Warn("Failed to release attachment", "id", att.ID, "recipient", job.To, "error", err)
+		}
+	}
+}
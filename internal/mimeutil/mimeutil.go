@@ -0,0 +1,97 @@
+// Package mimeutil 提供对已序列化邮件原文的 MIME 实体拆分与重组，供签名和加密使用
+package mimeutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// ErrInvalidMessage 邮件原文缺少头部与正文分隔
+var ErrInvalidMessage = errors.New("mimeutil: missing header/body separator")
+
+// Split 将邮件原文拆分为外层头部（不含 Content-* 与 MIME-Version）和 MIME 实体
+// MIME 实体由 Content-* 头部、空行和正文组成，可直接作为签名或加密的内容
+func Split(raw []byte) (outer, entity []byte, err error) {
+	idx := bytes.Index(raw, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return nil, nil, ErrInvalidMessage
+	}
+
+	var outerBuf, entityBuf bytes.Buffer
+	for _, field := range splitFields(string(raw[:idx+2])) {
+		name := strings.ToLower(field[:max(strings.IndexByte(field, ':'), 0)])
+		switch {
+		case strings.HasPrefix(name, "content-"):
+			entityBuf.WriteString(field)
+		case name == "mime-version":
+			// MIME-Version 由 Join 统一写入
+		default:
+			outerBuf.WriteString(field)
+		}
+	}
+	entityBuf.WriteString("\r\n")
+	entityBuf.Write(raw[idx+4:])
+	return outerBuf.Bytes(), entityBuf.Bytes(), nil
+}
+
+// Join 将外层头部与新的 MIME 实体组合为完整邮件
+func Join(outer, entity []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(outer) + len(entity) + 20)
+	buf.Write(outer)
+	buf.WriteString("Mime-Version: 1.0\r\n")
+	buf.Write(entity)
+	return buf.Bytes()
+}
+
+// Boundary 生成随机的 multipart 边界
+func Boundary() string {
+	b := make([]byte, 15)
+	_, _ = rand.Read(b)
+	return "=_" + hex.EncodeToString(b)
+}
+
+// WriteBase64 按每行 76 字符写入 Base64 编码内容
+func WriteBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
+
+// splitFields 将头部拆分为字段，保留折行和结尾 CRLF
+func splitFields(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// DecodeTransfer 按 Content-Transfer-Encoding 返回解码后的内容
+func DecodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
@@ -0,0 +1,221 @@
+// Package outbox 将业务系统发件箱表中的任务转发到邮件队列
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/status"
+	"email-service/pkg/jobqueue"
+	"email-service/pkg/outbox"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// maxRetryDelay 转发失败后重试的最长间隔
+const maxRetryDelay = 10 * time.Minute
+
+// drivers 各数据库对应的 database/sql 驱动名
+var drivers = map[string]string{
+	outbox.Postgres: "pgx",
+	outbox.MySQL:    "mysql",
+	outbox.SQLite:   "sqlite",
+}
+
+// Config 发件箱中继配置
+type Config struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Driver       string        `mapstructure:"driver"` // postgres、mysql 或 sqlite
+	DSN          string        `mapstructure:"dsn"`    // 业务系统数据库
+	Table        string        `mapstructure:"table"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Retention    time.Duration `mapstructure:"retention"` // 已转发记录的保留时长
+}
+
+// DefaultConfig 返回默认发件箱中继配置
+func DefaultConfig() *Config {
+	return &Config{
+		Table:        outbox.DefaultTable,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// JobPusher 接收发件箱中的任务，由 mailer.Dispatcher 实现
+type JobPusher interface {
+	PushJob(job jobqueue.EmailJob) error
+}
+
+// Relay 轮询发件箱表并将任务推入队列
+// 认领、推送和标记在同一事务中完成；事务提交失败时任务会被再次转发，
+// 此时通过状态存储中已有的任务ID去重
+type Relay struct {
+	db       *sql.DB
+	box      *outbox.Outbox
+	pusher   JobPusher
+	statuses status.Store
+	config   *Config
+	logger   *logger.Logger
+}
+
+// NewRelay 连接业务数据库并确保发件箱表存在
+func NewRelay(cfg *Config, pusher JobPusher) (*Relay, error) {
+	driver, ok := drivers[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("outbox: unsupported driver %q", cfg.Driver)
+	}
+	if cfg.DSN == "" {
+		return nil, errors.New("outbox: dsn is required")
+	}
+	box, err := outbox.New(cfg.Driver, cfg.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == outbox.SQLite {
+		db.SetMaxOpenConns(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, stmt := range box.Schema() {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("outbox: failed to create table: %w", err)
+		}
+	}
+
+	return &Relay{
+		db:     db,
+		box:    box,
+		pusher: pusher,
+		config: cfg,
+		logger: logger.GetDefault().WithComponent("outbox-relay"),
+	}, nil
+}
+
+// SetStatusStore 设置任务状态存储，用于跳过已入队的任务，需在 Run 之前调用
+func (r *Relay) SetStatusStore(store status.Store) {
+	r.statuses = store
+}
+
+// Run 周期性转发发件箱中的任务并清理已转发的记录，直到 ctx 结束
+func (r *Relay) Run(ctx context.Context) {
+	defer r.db.Close()
+
+	interval := r.config.PollInterval
+	if interval <= 0 {
+		interval = DefaultConfig().PollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	for {
+		// 一批取满时立即处理下一批
+		n, err := r.RelayBatch(ctx)
+		if err != nil {
+			r.logger.Error("Failed to relay outbox jobs", "error", err)
+		}
+		if err == nil && n == r.batchSize() && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pruneTicker.C:
+			r.prune(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch 转发一批任务，返回本批认领的记录数
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	records, err := r.box.Claim(ctx, tx, r.batchSize())
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range records {
+		if err := r.relay(ctx, tx, rec); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), tx.Commit()
+}
+
+// relay 转发一条记录，只有写入发件箱表失败时返回错误
+func (r *Relay) relay(ctx context.Context, tx *sql.Tx, rec outbox.Record) error {
+	job, err := rec.Job()
+	if err != nil {
+		r.logger.Error("Invalid outbox job payload", "job_id", rec.JobID, "error", err)
+		return r.box.MarkFailed(ctx, tx, rec.JobID, "invalid job payload: "+err.Error(), time.Now().Add(maxRetryDelay))
+	}
+	job.ID = rec.JobID
+
+	if status.Queued(ctx, r.statuses, job.ID, r.logger) {
+		r.logger.Debug("Skipping outbox job already queued", "job_id", job.ID)
+		return r.box.MarkRelayed(ctx, tx, job.ID)
+	}
+
+	if err := r.pusher.PushJob(job); err != nil {
+		retryAt := time.Now().Add(retryDelay(rec.Attempts))
+		r.logger.Warn("Failed to push outbox job",
+			"job_id", job.ID, "attempts", rec.Attempts+1, "retry_at", retryAt, "error", err)
+		return r.box.MarkFailed(ctx, tx, job.ID, err.Error(), retryAt)
+	}
+	return r.box.MarkRelayed(ctx, tx, job.ID)
+}
+
+// prune 删除超过保留时长的已转发记录
+func (r *Relay) prune(ctx context.Context) {
+	if r.config.Retention <= 0 {
+		return
+	}
+	n, err := r.box.Prune(ctx, r.db, time.Now().Add(-r.config.Retention))
+	if err != nil {
+		r.logger.Error("Failed to prune outbox", "error", err)
+		return
+	}
+	if n > 0 {
+		r.logger.Info("Pruned relayed outbox jobs", "count", n)
+	}
+}
+
+func (r *Relay) batchSize() int {
+	if r.config.BatchSize <= 0 {
+		return DefaultConfig().BatchSize
+	}
+	return r.config.BatchSize
+}
+
+// retryDelay 按失败次数指数退避，最长 maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	if attempts > 10 {
+		return maxRetryDelay
+	}
+	delay := time.Second << attempts
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
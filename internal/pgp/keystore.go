@@ -0,0 +1,199 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// KeyInfo 已登记公钥的摘要信息
+type KeyInfo struct {
+	Address     string    `json:"address"`
+	Fingerprint string    `json:"fingerprint"`
+	UserIDs     []string  `json:"user_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// KeyStore 邮箱地址到 ASCII-armored 公钥的登记表
+type KeyStore interface {
+	// Get 返回地址对应的公钥，不存在时返回 ErrNoPublicKey
+	Get(address string) (*openpgp.Entity, string, error)
+
+	// Put 校验并登记公钥，覆盖已有记录
+	Put(address, armored string) (*KeyInfo, error)
+
+	// Delete 删除地址对应的公钥
+	Delete(address string) error
+
+	// List 列出所有已登记的公钥
+	List() ([]KeyInfo, error)
+}
+
+// FileKeyStore 基于本地目录的公钥登记表，文件名为 <邮箱地址>.asc
+type FileKeyStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileKeyStore 创建目录公钥登记表
+func NewFileKeyStore(dir string) (*FileKeyStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("pgp: failed to create key store dir: %w", err)
+	}
+	return &FileKeyStore{dir: dir}, nil
+}
+
+// Get 返回地址对应的公钥
+func (s *FileKeyStore) Get(address string) (*openpgp.Entity, string, error) {
+	path, err := s.path(address)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.RLock()
+	data, err := os.ReadFile(path)
+	s.mu.RUnlock()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("%w %s", ErrNoPublicKey, NormalizeAddress(address))
+		}
+		return nil, "", err
+	}
+
+	entity, err := ParsePublicKey(string(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return entity, string(data), nil
+}
+
+// Put 校验并登记公钥
+func (s *FileKeyStore) Put(address, armored string) (*KeyInfo, error) {
+	path, err := s.path(address)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := ParsePublicKey(armored)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(armored), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return keyInfo(NormalizeAddress(address), entity, time.Now()), nil
+}
+
+// Delete 删除地址对应的公钥
+func (s *FileKeyStore) Delete(address string) error {
+	path, err := s.path(address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w %s", ErrNoPublicKey, NormalizeAddress(address))
+		}
+		return err
+	}
+	return nil
+}
+
+// List 列出所有已登记的公钥
+func (s *FileKeyStore) List() ([]KeyInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.asc"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	infos := make([]KeyInfo, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entity, err := ParsePublicKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("pgp: invalid key file %s: %w", file, err)
+		}
+		stat, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		address := strings.TrimSuffix(filepath.Base(file), ".asc")
+		infos = append(infos, *keyInfo(address, entity, stat.ModTime()))
+	}
+	return infos, nil
+}
+
+// path 返回地址对应的文件路径
+func (s *FileKeyStore) path(address string) (string, error) {
+	address = NormalizeAddress(address)
+	if address == "" || !strings.Contains(address, "@") || strings.ContainsAny(address, `/\`) || strings.HasPrefix(address, ".") {
+		return "", fmt.Errorf("%w: invalid address %q", ErrInvalidKey, address)
+	}
+	return filepath.Join(s.dir, address+".asc"), nil
+}
+
+// NormalizeAddress 规范化邮箱地址
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// ParsePublicKey 解析并校验单个 ASCII-armored 公钥，要求包含可用的加密子密钥且不含私钥
+func ParsePublicKey(armored string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewBufferString(armored))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("%w: expected exactly one key, got %d", ErrInvalidKey, len(entities))
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey != nil {
+		return nil, fmt.Errorf("%w: private keys must not be registered", ErrInvalidKey)
+	}
+	if _, ok := entity.EncryptionKey(time.Now()); !ok {
+		return nil, fmt.Errorf("%w: no valid encryption key", ErrInvalidKey)
+	}
+	return entity, nil
+}
+
+func keyInfo(address string, entity *openpgp.Entity, updatedAt time.Time) *KeyInfo {
+	info := &KeyInfo{
+		Address:     address,
+		Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+		CreatedAt:   entity.PrimaryKey.CreationTime,
+		UpdatedAt:   updatedAt,
+	}
+	for name := range entity.Identities {
+		info.UserIDs = append(info.UserIDs, name)
+	}
+	sort.Strings(info.UserIDs)
+	return info
+}
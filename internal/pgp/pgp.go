@@ -0,0 +1,232 @@
+// Package pgp 实现基于 OpenPGP 的 PGP/MIME (RFC 3156) 邮件签名与加密
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+
+	"email-service/internal/mimeutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+var (
+	// ErrNoPublicKey 收件人没有登记公钥
+	ErrNoPublicKey = errors.New("pgp: no public key for recipient")
+
+	// ErrNoSigningKey 未配置服务签名密钥
+	ErrNoSigningKey = errors.New("pgp: signing key not configured")
+
+	// ErrInvalidKey 公钥无效
+	ErrInvalidKey = errors.New("pgp: invalid public key")
+)
+
+// 收件人缺少公钥时的处理策略
+const (
+	PolicySkip = "skip" // 跳过该收件人，不发送
+	PolicyFail = "fail" // 拒绝整个请求
+)
+
+// Config PGP 配置
+type Config struct {
+	KeyStoreDir          string `mapstructure:"key_store_dir"`          // 公钥登记目录
+	SigningKeyFile       string `mapstructure:"signing_key_file"`       // 服务签名私钥（ASCII-armored）
+	SigningKeyPassphrase string `mapstructure:"signing_key_passphrase"` // 签名私钥口令
+	MissingKeyPolicy     string `mapstructure:"missing_key_policy"`     // skip 或 fail
+}
+
+// DefaultConfig 返回默认 PGP 配置
+func DefaultConfig() *Config {
+	return &Config{
+		KeyStoreDir:      "data/pgp-keys",
+		MissingKeyPolicy: PolicyFail,
+	}
+}
+
+// Service 提供 PGP/MIME 签名与加密
+type Service struct {
+	store  KeyStore
+	signer *openpgp.Entity
+	policy string
+	config *packet.Config
+}
+
+// New 根据配置创建 PGP 服务
+func New(cfg *Config, store KeyStore) (*Service, error) {
+	policy := cfg.MissingKeyPolicy
+	switch policy {
+	case "":
+		policy = PolicyFail
+	case PolicySkip, PolicyFail:
+	default:
+		return nil, fmt.Errorf("pgp: unsupported missing key policy %q", policy)
+	}
+
+	s := &Service{
+		store:  store,
+		policy: policy,
+		config: &packet.Config{DefaultHash: crypto.SHA256},
+	}
+
+	if cfg.SigningKeyFile != "" {
+		signer, err := loadSigningKey(cfg.SigningKeyFile, cfg.SigningKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		s.signer = signer
+	}
+	return s, nil
+}
+
+// KeyStore 返回公钥登记表
+func (s *Service) KeyStore() KeyStore {
+	return s.store
+}
+
+// MissingKeyPolicy 返回收件人缺少公钥时的处理策略
+func (s *Service) MissingKeyPolicy() string {
+	return s.policy
+}
+
+// CanSign 是否配置了签名私钥
+func (s *Service) CanSign() bool {
+	return s.signer != nil
+}
+
+// HasKey 判断收件人是否登记了公钥
+func (s *Service) HasKey(address string) (bool, error) {
+	if _, _, err := s.store.Get(address); err != nil {
+		if errors.Is(err, ErrNoPublicKey) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Sign 使用服务密钥将邮件签名为 multipart/signed 格式 (RFC 3156 第5节)
+func (s *Service) Sign(raw []byte) ([]byte, error) {
+	if s.signer == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	outer, entity, err := mimeutil.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, s.signer, bytes.NewReader(entity), s.config); err != nil {
+		return nil, fmt.Errorf("pgp: failed to sign: %w", err)
+	}
+
+	boundary := mimeutil.Boundary()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/signed; protocol=\"application/pgp-signature\"; micalg=pgp-sha256;\r\n boundary=\"%s\"\r\n\r\n", boundary)
+	buf.WriteString("This is an OpenPGP/MIME signed message (RFC 3156).\r\n\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.Write(entity)
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	buf.WriteString("Content-Description: OpenPGP digital signature\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n")
+	writeCRLF(&buf, signature.Bytes())
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+
+	return mimeutil.Join(outer, buf.Bytes()), nil
+}
+
+// Encrypt 使用收件人公钥将邮件加密为 multipart/encrypted 格式 (RFC 3156 第4节)
+// sign 为 true 时同时使用服务密钥签名 (RFC 3156 第6.2节)
+func (s *Service) Encrypt(raw []byte, recipients []string, sign bool) ([]byte, error) {
+	var signer *openpgp.Entity
+	if sign {
+		if s.signer == nil {
+			return nil, ErrNoSigningKey
+		}
+		signer = s.signer
+	}
+
+	to := make([]*openpgp.Entity, 0, len(recipients))
+	for _, addr := range recipients {
+		entity, _, err := s.store.Get(addr)
+		if err != nil {
+			return nil, err
+		}
+		to = append(to, entity)
+	}
+
+	outer, entity, err := mimeutil.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var ciphertext bytes.Buffer
+	armored, err := armor.Encode(&ciphertext, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openpgp.Encrypt(armored, to, signer, nil, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("pgp: failed to encrypt: %w", err)
+	}
+	if _, err = plaintext.Write(entity); err != nil {
+		return nil, err
+	}
+	if err = plaintext.Close(); err != nil {
+		return nil, err
+	}
+	if err = armored.Close(); err != nil {
+		return nil, err
+	}
+
+	boundary := mimeutil.Boundary()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\";\r\n boundary=\"%s\"\r\n\r\n", boundary)
+	buf.WriteString("This is an OpenPGP/MIME encrypted message (RFC 3156).\r\n\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	buf.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	buf.WriteString("Version: 1\r\n\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	buf.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	buf.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	writeCRLF(&buf, ciphertext.Bytes())
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+
+	return mimeutil.Join(outer, buf.Bytes()), nil
+}
+
+// loadSigningKey 读取并解密服务签名私钥
+func loadSigningKey(path, passphrase string) (*openpgp.Entity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pgp: failed to read signing key: %w", err)
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("pgp: invalid signing key: %w", err)
+	}
+	if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, errors.New("pgp: signing key file contains no private key")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("pgp: failed to decrypt signing key: %w", err)
+		}
+	}
+	return entity, nil
+}
+
+// writeCRLF 将 LF 换行的 armored 数据以 CRLF 换行写入
+func writeCRLF(buf *bytes.Buffer, data []byte) {
+	buf.Write(bytes.ReplaceAll(bytes.TrimRight(data, "\n"), []byte("\n"), []byte("\r\n")))
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+)
+
+// requeueDelivery 用于取出时即已删除任务的后端（内存队列、Redis 列表、NATS 核心订阅）
+// Ack 和 Extend 为空操作，Nack 将任务重新推入队列
+type requeueDelivery struct {
+	job   jobqueue.EmailJob
+	queue jobqueue.JobQueue
+}
+
+// Job 返回任务内容
+func (d *requeueDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 任务取出时已从队列删除，无需确认
+func (d *requeueDelivery) Ack(ctx context.Context) error {
+	return nil
+}
+
+// Nack 重新推入任务；延迟期间任务只保存在进程内存中
+func (d *requeueDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	if requeueAfter <= 0 {
+		return d.queue.Push(ctx, d.job)
+	}
+	time.AfterFunc(requeueAfter, func() {
+		if err := d.queue.Push(context.Background(), d.job); err != nil {
+			logger.GetDefault().WithComponent("queue").Error("Failed to requeue delayed job",
+				"recipient", d.job.To,
+				"job_id", d.job.ID,
+				"error", err)
+		}
+	})
+	return nil
+}
+
+// Extend 任务不会被重新投递，无需续期
+func (d *requeueDelivery) Extend(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,379 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 磁盘队列使用的桶
+var (
+	bucketJobs     = []byte("jobs")     // 序号 -> 任务 JSON
+	bucketReady    = []byte("ready")    // 优先级通道 + 可投递时间 + 序号 -> 空
+	bucketInflight = []byte("inflight") // 序号 -> 可见性截止时间
+)
+
+// diskPollInterval 等待新任务时的最长休眠时间，避免漏掉通知
+const diskPollInterval = time.Second
+
+// DiskQueue 基于 bbolt 的本地持久化队列，适合单机部署
+// 任务按可投递时间排序，取出后进入处理中状态，Ack 后删除；进程重启时处理中的任务重新投递，
+// 超过可见性超时未确认的任务由回收协程放回队列。每次写入都在事务提交时同步到磁盘。
+// 每个优先级的任务在 ready 桶中占用独立的键前缀，Pop 按权重选择通道
+type DiskQueue struct {
+	db                *bolt.DB
+	visibilityTimeout time.Duration
+	selector          laneSelector
+	notify            chan struct{}
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+	logger            *logger.Logger
+}
+
+// NewDiskQueue 打开或创建磁盘队列
+func NewDiskQueue(config *DiskConfig) (*DiskQueue, error) {
+	path := config.Path
+	if path == "" {
+		path = "data/queue.db"
+	}
+	visibilityTimeout := config.VisibilityTimeout
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 5 * time.Minute
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk queue: %w", err)
+	}
+
+	// 上次运行时处理中的任务没有确认，全部重新投递
+	recovered := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketJobs, bucketReady, bucketInflight} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		n, err := requeueInflight(tx, func([]byte) bool { return true })
+		recovered = n
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &DiskQueue{
+		db:                db,
+		visibilityTimeout: visibilityTimeout,
+		notify:            make(chan struct{}, 1),
+		cancel:            cancel,
+		logger:            logger.GetDefault().WithComponent("disk-queue"),
+	}
+	if recovered > 0 {
+		q.logger.Info("Recovered unacknowledged jobs", "count", recovered)
+	}
+	q.wg.Add(1)
+	go q.reap(ctx)
+	return q, nil
+}
+
+// Push 将任务写入队列，NextRetryAt 在未来的任务到期后才会被取出
+func (q *DiskQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	readyAt := time.Now()
+	if job.NextRetryAt.After(readyAt) {
+		readyAt = job.NextRetryAt
+	}
+
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(bucketJobs)
+		seq, err := jobs.NextSequence()
+		if err != nil {
+			return err
+		}
+		id := encodeUint64(seq)
+		if err := jobs.Put(id, data); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketReady).Put(readyKey(laneOf(job.Priority), readyAt, id), nil)
+	})
+	if err != nil {
+		return err
+	}
+	q.wake()
+	return nil
+}
+
+// Pop 取出最早到期的任务，没有到期任务时阻塞等待
+func (q *DiskQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	for {
+		delivery, wait, err := q.reserve()
+		if err != nil || delivery != nil {
+			return delivery, err
+		}
+		if wait <= 0 || wait > diskPollInterval {
+			wait = diskPollInterval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-q.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve 按权重从各通道取出一个到期任务；没有到期任务时返回距最早任务到期的时间，队列为空时为 0
+func (q *DiskQueue) reserve() (*diskDelivery, time.Duration, error) {
+	var (
+		delivery *diskDelivery
+		wait     time.Duration
+	)
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		c := tx.Bucket(bucketReady).Cursor()
+		for _, lane := range q.selector.order() {
+			d, laneWait, err := q.reserveLane(tx, c, lane, now)
+			if err != nil || d != nil {
+				delivery = d
+				return err
+			}
+			if laneWait > 0 && (wait == 0 || laneWait < wait) {
+				wait = laneWait
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return delivery, wait, nil
+}
+
+// reserveLane 取出一个通道中最早到期的任务；没有到期任务时返回距该通道最早任务到期的时间
+func (q *DiskQueue) reserveLane(tx *bolt.Tx, c *bolt.Cursor, lane int, now time.Time) (*diskDelivery, time.Duration, error) {
+	jobs := tx.Bucket(bucketJobs)
+	prefix := []byte{byte(lane)}
+	for key, _ := c.Seek(prefix); key != nil && key[0] == byte(lane); key, _ = c.Seek(prefix) {
+		readyAt := time.Unix(0, int64(binary.BigEndian.Uint64(key[1:9])))
+		if readyAt.After(now) {
+			return nil, readyAt.Sub(now), nil
+		}
+
+		id := append([]byte(nil), key[9:]...)
+		if err := c.Delete(); err != nil {
+			return nil, 0, err
+		}
+		data := jobs.Get(id)
+		if data == nil {
+			// 任务已被确认，忽略残留的索引
+			continue
+		}
+		var job jobqueue.EmailJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			q.logger.Error("Dropping undecodable job", "seq", binary.BigEndian.Uint64(id), "error", err)
+			if err := jobs.Delete(id); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		deadline := now.Add(q.visibilityTimeout)
+		if err := tx.Bucket(bucketInflight).Put(id, encodeUint64(uint64(deadline.UnixNano()))); err != nil {
+			return nil, 0, err
+		}
+		return &diskDelivery{queue: q, id: id, lane: lane, job: job}, 0, nil
+	}
+	return nil, 0, nil
+}
+
+// Close 停止回收协程并关闭数据库
+func (q *DiskQueue) Close() error {
+	q.cancel()
+	q.wg.Wait()
+	return q.db.Close()
+}
+
+// Size 返回尚未确认的任务数量，包括延迟和处理中的任务
+func (q *DiskQueue) Size() (int, error) {
+	var n int
+	err := q.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketJobs).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// reap 定期将超过可见性超时的处理中任务放回队列
+func (q *DiskQueue) reap(ctx context.Context) {
+	defer q.wg.Done()
+
+	interval := q.visibilityTimeout / 2
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := uint64(time.Now().UnixNano())
+		var n int
+		err := q.db.Update(func(tx *bolt.Tx) error {
+			var err error
+			n, err = requeueInflight(tx, func(deadline []byte) bool {
+				return binary.BigEndian.Uint64(deadline) <= now
+			})
+			return err
+		})
+		if err != nil {
+			q.logger.Error("Failed to requeue expired jobs", "error", err)
+			continue
+		}
+		if n > 0 {
+			q.logger.Warn("Requeued jobs past visibility timeout", "count", n)
+			q.wake()
+		}
+	}
+}
+
+// wake 通知等待中的 Pop 重新检查队列
+func (q *DiskQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// requeueInflight 将满足条件的处理中任务立即放回队列
+func requeueInflight(tx *bolt.Tx, expired func(deadline []byte) bool) (int, error) {
+	inflight := tx.Bucket(bucketInflight)
+	var ids [][]byte
+	err := inflight.ForEach(func(id, deadline []byte) error {
+		if expired(deadline) {
+			ids = append(ids, append([]byte(nil), id...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	jobs := tx.Bucket(bucketJobs)
+	for _, id := range ids {
+		if err := inflight.Delete(id); err != nil {
+			return 0, err
+		}
+		if err := tx.Bucket(bucketReady).Put(readyKey(jobLane(jobs.Get(id)), now, id), nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// jobLane 返回任务 JSON 所在的通道
+func jobLane(data []byte) int {
+	var job struct {
+		Priority string `json:"priority"`
+	}
+	_ = json.Unmarshal(data, &job)
+	return laneOf(job.Priority)
+}
+
+// readyKey 生成同一通道内按可投递时间排序的键
+func readyKey(lane int, at time.Time, id []byte) []byte {
+	key := make([]byte, 0, 17)
+	key = append(key, byte(lane))
+	key = append(key, encodeUint64(uint64(at.UnixNano()))...)
+	return append(key, id...)
+}
+
+func encodeUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// diskDelivery 磁盘队列取出的任务
+type diskDelivery struct {
+	queue *DiskQueue
+	id    []byte
+	lane  int
+	job   jobqueue.EmailJob
+}
+
+// Job 返回任务内容
+func (d *diskDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 删除任务
+func (d *diskDelivery) Ack(ctx context.Context) error {
+	return d.queue.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketInflight).Delete(d.id); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketJobs).Delete(d.id)
+	})
+}
+
+// Nack 将任务放回队列，requeueAfter 之后才能再次取出
+func (d *diskDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	err := d.queue.db.Update(func(tx *bolt.Tx) error {
+		inflight := tx.Bucket(bucketInflight)
+		if inflight.Get(d.id) == nil {
+			// 已被回收协程放回队列
+			return nil
+		}
+		if err := inflight.Delete(d.id); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketReady).Put(readyKey(d.lane, time.Now().Add(requeueAfter), d.id), nil)
+	})
+	if err != nil {
+		return err
+	}
+	d.queue.wake()
+	return nil
+}
+
+// Extend 重置可见性截止时间
+func (d *diskDelivery) Extend(ctx context.Context) error {
+	q := d.queue
+	return q.db.Update(func(tx *bolt.Tx) error {
+		inflight := tx.Bucket(bucketInflight)
+		if inflight.Get(d.id) == nil {
+			return nil
+		}
+		deadline := time.Now().Add(q.visibilityTimeout)
+		return inflight.Put(d.id, encodeUint64(uint64(deadline.UnixNano())))
+	})
+}
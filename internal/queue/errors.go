@@ -29,6 +29,19 @@ var (
 	ErrNatsConfigRequired = errors.New("nats config is required")
 )
 
+var (
+	// ErrJetStreamConfigRequired JetStream配置是必须的
+	ErrJetStreamConfigRequired = errors.New("jetstream config is required")
+)
+
+var (
+	// ErrSQLConfig SQL配置错误
+	ErrSQLConfig = errors.New("sql queue config error")
+
+	// ErrLeaseLost 任务租约已过期并被其他工人认领
+	ErrLeaseLost = errors.New("job lease lost")
+)
+
 var (
 	ErrKafkaConfig         = errors.New("kafak config error")
 	ErrKafkaConfigRequired = errors.New("kafka config is required")
@@ -18,6 +18,9 @@ func NewJobQueue(config *TaskQueueConfig) (jobqueue.JobQueue, error) {
 		if config.Redis == nil {
 			return nil, ErrRedisConfigRequired
 		}
+		if config.Redis.Reliable {
+			return NewReliableRedisQueue(config.Redis)
+		}
 		return NewRedisQueue(config.Redis)
 	case TypeNATS:
 		if config.NATS == nil {
@@ -29,6 +32,21 @@ func NewJobQueue(config *TaskQueueConfig) (jobqueue.JobQueue, error) {
 			return nil, ErrKafkaConfigRequired
 		}
 		return NewKafkaQueue(config.Kafka)
+	case TypeJetStream:
+		if config.JetStream == nil {
+			return nil, ErrJetStreamConfigRequired
+		}
+		return NewJetStreamQueue(config.JetStream)
+	case TypeDisk:
+		if config.Disk == nil {
+			config.Disk = &DiskConfig{}
+		}
+		return NewDiskQueue(config.Disk)
+	case TypeSQL:
+		if config.SQL == nil {
+			return nil, fmt.Errorf("%w: sql config is required", ErrSQLConfig)
+		}
+		return NewSQLQueue(config.SQL)
 	default:
 		return nil, fmt.Errorf("unsupported queue type: %s (only memory supported currently)", config.Type)
 	}
@@ -1,23 +1,40 @@
 // Package queue 队列配置定义
 package queue
 
+import "time"
+
 // TaskQueueType 队列类型
 type TaskQueueType string
 
 const (
-	TypeMemory TaskQueueType = "memory"
-	TypeRedis  TaskQueueType = "redis"
-	TypeNATS   TaskQueueType = "nats"
-	TypeKafka  TaskQueueType = "kafka"
+	TypeMemory    TaskQueueType = "memory"
+	TypeRedis     TaskQueueType = "redis"
+	TypeNATS      TaskQueueType = "nats"
+	TypeKafka     TaskQueueType = "kafka"
+	TypeJetStream TaskQueueType = "jetstream"
+	TypeDisk      TaskQueueType = "disk"
+	TypeSQL       TaskQueueType = "sql"
 )
 
+// Distributed 判断队列是否可由多个实例共同消费，此时任务可能在入队之外的实例上处理
+func (t TaskQueueType) Distributed() bool {
+	switch t {
+	case TypeMemory, TypeDisk, "":
+		return false
+	}
+	return true
+}
+
 // TaskQueueConfig 队列配置
 type TaskQueueConfig struct {
-	Type   TaskQueueType `mapstructure:"type"`
-	Redis  *RedisConfig  `mapstructure:"redis,omitempty"`
-	NATS   *NATSConfig   `mapstructure:"nats,omitempty"`
-	Memory *MemoryConfig `mapstructure:"memory,omitempty"`
-	Kafka  *KafkaConfig  `mapstructure:"kafka,omitempty"`
+	Type      TaskQueueType    `mapstructure:"type"`
+	Redis     *RedisConfig     `mapstructure:"redis,omitempty"`
+	NATS      *NATSConfig      `mapstructure:"nats,omitempty"`
+	Memory    *MemoryConfig    `mapstructure:"memory,omitempty"`
+	Kafka     *KafkaConfig     `mapstructure:"kafka,omitempty"`
+	JetStream *JetStreamConfig `mapstructure:"jetstream,omitempty"`
+	Disk      *DiskConfig      `mapstructure:"disk,omitempty"`
+	SQL       *SQLConfig       `mapstructure:"sql,omitempty"`
 }
 
 // RedisConfig Redis队列配置
@@ -26,6 +43,13 @@ type RedisConfig struct {
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
 	QueueKey string `mapstructure:"queue_key"`
+
+	// 可靠模式：使用 Redis Streams 消费者组，任务确认后才删除（需要 Redis 6.2+）
+	Reliable          bool          `mapstructure:"reliable"`
+	Group             string        `mapstructure:"group"`              // 消费者组名
+	Consumer          string        `mapstructure:"consumer"`           // 消费者名，默认为主机名-进程号
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"` // 未确认任务重新投递前的等待时间
+	ReapInterval      time.Duration `mapstructure:"reap_interval"`      // 回收超时任务的检查间隔
 }
 
 // NATSConfig NATS队列配置
@@ -34,6 +58,32 @@ type NATSConfig struct {
 	Subject string `mapstructure:"subject"`
 }
 
+// JetStreamConfig NATS JetStream 队列配置
+type JetStreamConfig struct {
+	URL        string        `mapstructure:"url"`
+	Stream     string        `mapstructure:"stream"`      // Stream 名称，不存在时自动创建
+	Subject    string        `mapstructure:"subject"`     // 任务写入的主题
+	Durable    string        `mapstructure:"durable"`     // 所有实例共享的持久化消费者名
+	AckWait    time.Duration `mapstructure:"ack_wait"`    // 未确认任务重新投递前的等待时间
+	MaxDeliver int           `mapstructure:"max_deliver"` // 最大投递次数，默认（0 或 -1）不限
+	Replicas   int           `mapstructure:"replicas"`    // Stream 副本数
+}
+
+// DiskConfig 本地磁盘队列配置
+type DiskConfig struct {
+	Path              string        `mapstructure:"path"`               // 数据库文件路径
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"` // 未确认任务重新投递前的等待时间
+}
+
+// SQLConfig SQL数据库队列配置
+type SQLConfig struct {
+	Driver            string        `mapstructure:"driver"`             // postgres、mysql 或 sqlite
+	DSN               string        `mapstructure:"dsn"`                // 连接字符串，sqlite 默认为 data/email.db
+	MaxOpenConns      int           `mapstructure:"max_open_conns"`     // 最大连接数，sqlite 固定为 1
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"` // 租约时长，过期未确认的任务会被重新认领
+	PollInterval      time.Duration `mapstructure:"poll_interval"`      // 没有任务时的轮询间隔
+}
+
 // MemoryConfig 内存队列配置
 type MemoryConfig struct {
 	BufferSize int `mapstructure:"buffer_size"`
@@ -41,7 +91,35 @@ type MemoryConfig struct {
 
 // KafkaConfig Kafka队列配置
 type KafkaConfig struct {
-	Brokers []string `mapstructure:"brokers"`  // Kafka集群地址
-	Topic   string   `mapstructure:"topic"`    // Kafka主题
-	GroupID string   `mapstructure:"group_id"` // Kafka消费者组ID
+	Brokers     []string          `mapstructure:"brokers"`      // Kafka集群地址
+	Topic       string            `mapstructure:"topic"`        // Kafka主题
+	GroupID     string            `mapstructure:"group_id"`     // Kafka消费者组ID
+	KeyBy       string            `mapstructure:"key_by"`       // 消息键：recipient（默认）、domain 或 none
+	RetryTopics []KafkaRetryTopic `mapstructure:"retry_topics"` // 延迟重投使用的重试主题
+	DLQTopic    string            `mapstructure:"dlq_topic"`    // 死信主题
+	MaxAttempts int               `mapstructure:"max_attempts"` // 同一消息最多处理失败次数，超过后写入死信主题，0 表示不限
+	SASL        *KafkaSASLConfig  `mapstructure:"sasl,omitempty"`
+	TLS         *KafkaTLSConfig   `mapstructure:"tls,omitempty"`
+}
+
+// KafkaRetryTopic Kafka重试主题，延迟不超过 Delay 的任务写入该主题
+type KafkaRetryTopic struct {
+	Topic string        `mapstructure:"topic"`
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+// KafkaSASLConfig Kafka SASL认证配置
+type KafkaSASLConfig struct {
+	Mechanism string `mapstructure:"mechanism"` // plain、scram-sha-256 或 scram-sha-512
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+}
+
+// KafkaTLSConfig Kafka TLS配置
+type KafkaTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"` // 客户端证书，双向认证时使用
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
 }
@@ -0,0 +1,245 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"email-service/pkg/jobqueue"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// jetStreamWait 所有优先级都没有任务时 Pop 等待的最长时间
+	jetStreamWait = time.Second
+
+	// jetStreamLaneWait 等待期间在每个优先级消费者上单次挂起的时长
+	jetStreamLaneWait = 100 * time.Millisecond
+)
+
+// JetStreamQueue 基于 NATS JetStream 的队列
+// 任务写入工作队列保留策略的 Stream，所有实例共享同一个持久化拉取消费者，每个任务只投递给一个实例；
+// 超过 AckWait 未确认的任务由服务端重新投递。每个优先级使用独立的主题和消费者
+type JetStreamQueue struct {
+	conn      *nats.Conn
+	js        jetstream.JetStream
+	consumers [laneCount]jetstream.Consumer
+	subjects  [laneCount]string
+	selector  laneSelector
+}
+
+// NewJetStreamQueue 创建 JetStream 队列，Stream 和消费者不存在时自动创建
+func NewJetStreamQueue(config *JetStreamConfig) (*JetStreamQueue, error) {
+	url := config.URL
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	streamName := config.Stream
+	if streamName == "" {
+		streamName = "EMAIL_JOBS"
+	}
+	subject := config.Subject
+	if subject == "" {
+		subject = "email.jobs"
+	}
+	durable := config.Durable
+	if durable == "" {
+		durable = "email-workers"
+	}
+	ackWait := config.AckWait
+	if ackWait <= 0 {
+		ackWait = 5 * time.Minute
+	}
+	// 延迟重投（免打扰时段、活动暂停、等待重试）同样计入投递次数，超过上限的任务会被服务端静默丢弃，
+	// 因此默认不限制，重试次数由重试策略控制
+	maxDeliver := config.MaxDeliver
+	if maxDeliver == 0 {
+		maxDeliver = -1
+	}
+	replicas := config.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subjects := laneKeys(subject, ".")
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      streamName,
+		Subjects:  subjects[:],
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+		Replicas:  replicas,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	q := &JetStreamQueue{
+		conn:     conn,
+		js:       js,
+		subjects: subjects,
+	}
+	for i, name := range laneKeys(durable, "-") {
+		consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+			Durable:       name,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+			AckWait:       ackWait,
+			MaxDeliver:    maxDeliver,
+			FilterSubject: subjects[i],
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create consumer: %w", err)
+		}
+		q.consumers[i] = consumer
+	}
+	return q, nil
+}
+
+// Push 将任务写入 Stream，服务端确认持久化后返回
+func (q *JetStreamQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = q.js.Publish(ctx, q.subjects[laneOf(job.Priority)], data)
+	return err
+}
+
+// Pop 按权重依次从各优先级的消费者拉取任务，都没有任务时按优先级轮流在各消费者上短暂等待，最长 1 秒
+// 等待期间到达的任何优先级的任务最迟在一轮等待后取出
+func (q *JetStreamQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, lane := range q.selector.order() {
+		batch, err := q.consumers[lane].FetchNoWait(1)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := firstMessage(batch)
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return q.delivery(msg)
+		}
+	}
+
+	// 由服务端挂起拉取请求直到有任务或超时，避免空闲时反复发送请求
+	deadline := time.Now().Add(jetStreamWait)
+	for {
+		for lane := range q.consumers {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			wait := min(time.Until(deadline), jetStreamLaneWait)
+			if wait <= 0 {
+				// 超时返回，让调用者重试
+				return nil, ErrTimeout
+			}
+			batch, err := q.consumers[lane].Fetch(1, jetstream.FetchMaxWait(wait))
+			if err != nil {
+				return nil, err
+			}
+			msg, err := firstMessage(batch)
+			if err != nil {
+				return nil, err
+			}
+			if msg != nil {
+				return q.delivery(msg)
+			}
+		}
+	}
+}
+
+// firstMessage 返回拉取到的第一条消息，没有消息时返回 nil
+func firstMessage(batch jetstream.MessageBatch) (jetstream.Msg, error) {
+	for msg := range batch.Messages() {
+		return msg, nil
+	}
+	if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) && !errors.Is(err, jetstream.ErrNoMessages) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// delivery 将消息转换为 Delivery，无法解析的消息不再重新投递
+func (q *JetStreamQueue) delivery(msg jetstream.Msg) (jobqueue.Delivery, error) {
+	var job jobqueue.EmailJob
+	if err := json.Unmarshal(msg.Data(), &job); err != nil {
+		if termErr := msg.Term(); termErr != nil {
+			return nil, termErr
+		}
+		return nil, err
+	}
+	return &jetStreamDelivery{msg: msg, job: job}, nil
+}
+
+// Close 关闭连接
+func (q *JetStreamQueue) Close() error {
+	q.conn.Close()
+	return nil
+}
+
+// Size 返回尚未确认的任务数量，包括等待投递和正在处理的任务
+func (q *JetStreamQueue) Size() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	total := 0
+	for _, consumer := range q.consumers {
+		info, err := consumer.Info(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += int(info.NumPending) + info.NumAckPending
+	}
+	return total, nil
+}
+
+// jetStreamDelivery JetStream 取出的任务
+type jetStreamDelivery struct {
+	msg jetstream.Msg
+	job jobqueue.EmailJob
+}
+
+// Job 返回任务内容
+func (d *jetStreamDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 确认任务并等待服务端回应，工作队列策略下确认后消息从 Stream 删除
+func (d *jetStreamDelivery) Ack(ctx context.Context) error {
+	return d.msg.DoubleAck(ctx)
+}
+
+// Nack 由服务端在延迟后重新投递任务
+func (d *jetStreamDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	if requeueAfter <= 0 {
+		return d.msg.Nak()
+	}
+	return d.msg.NakWithDelay(requeueAfter)
+}
+
+// Extend 重置 AckWait 计时
+func (d *jetStreamDelivery) Extend(ctx context.Context) error {
+	return d.msg.InProgress()
+}
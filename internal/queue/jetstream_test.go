@@ -0,0 +1,323 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/pkg/jobqueue"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// startJetStream 启动进程内开启 JetStream 的 NATS 服务器，返回连接地址
+func startJetStream(t *testing.T) string {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns.ClientURL()
+}
+
+// newTestJetStreamQueue 创建连接到 url 的队列，测试结束时关闭
+func newTestJetStreamQueue(t *testing.T, url string, ackWait time.Duration) *JetStreamQueue {
+	t.Helper()
+	q, err := NewJetStreamQueue(&JetStreamConfig{URL: url, AckWait: ackWait})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.Close() })
+	return q
+}
+
+func pushJob(t *testing.T, q jobqueue.JobQueue, id, priority string) {
+	t.Helper()
+	job := jobqueue.EmailJob{ID: id, To: id + "@example.com", Subject: "test", Priority: priority}
+	if err := q.Push(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustPop 取出一个任务，超时则测试失败
+func mustPop(t *testing.T, q jobqueue.JobQueue) jobqueue.Delivery {
+	t.Helper()
+	d, err := q.Pop(context.Background())
+	if err != nil {
+		t.Fatalf("Pop: %v", err)
+	}
+	return d
+}
+
+// popWithin 在 timeout 内重复取任务，直到取出一个任务
+func popWithin(t *testing.T, q jobqueue.JobQueue, timeout time.Duration) jobqueue.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		d, err := q.Pop(context.Background())
+		if err == nil {
+			return d
+		}
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("Pop: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no job within %v", timeout)
+		}
+	}
+}
+
+func TestJetStreamSingleDeliveryAcrossInstances(t *testing.T) {
+	url := startJetStream(t)
+	a := newTestJetStreamQueue(t, url, time.Minute)
+	b := newTestJetStreamQueue(t, url, time.Minute)
+
+	const total = 50
+	for i := 0; i < total; i++ {
+		pushJob(t, a, fmt.Sprintf("job-%02d", i), "")
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]int)
+		wg   sync.WaitGroup
+	)
+	for _, q := range []*JetStreamQueue{a, b, a, b} {
+		wg.Add(1)
+		go func(q *JetStreamQueue) {
+			defer wg.Done()
+			for {
+				d, err := q.Pop(context.Background())
+				if errors.Is(err, ErrTimeout) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				seen[d.Job().ID]++
+				mu.Unlock()
+				if err := d.Ack(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	if len(seen) != total {
+		t.Errorf("got %d distinct jobs, want %d", len(seen), total)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %s delivered %d times", id, n)
+		}
+	}
+	if size, err := a.Size(); err != nil || size != 0 {
+		t.Errorf("Size = %d, %v; want 0", size, err)
+	}
+}
+
+func TestJetStreamNackWithDelay(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Minute)
+	pushJob(t, q, "delayed", "")
+
+	d := mustPop(t, q)
+	nacked := time.Now()
+	if err := d.Nack(context.Background(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// 延迟期间不会重新投递
+	if _, err := q.Pop(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Pop during delay = %v, want ErrTimeout", err)
+	}
+
+	redelivered := popWithin(t, q, 3*time.Second)
+	if elapsed := time.Since(nacked); elapsed < 2*time.Second {
+		t.Errorf("redelivered after %v, want at least 2s", elapsed)
+	}
+	if redelivered.Job().ID != "delayed" {
+		t.Errorf("got job %s, want delayed", redelivered.Job().ID)
+	}
+	if err := redelivered.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJetStreamRepeatedNackIsNotDropped(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Minute)
+	pushJob(t, q, "deferred", jobqueue.PriorityHigh)
+
+	// 免打扰时段、活动暂停等延迟重投不受投递次数限制
+	for i := 0; i < 12; i++ {
+		d := popWithin(t, q, 3*time.Second)
+		if err := d.Nack(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := popWithin(t, q, 3*time.Second)
+	if d.Job().ID != "deferred" {
+		t.Errorf("got job %s, want deferred", d.Job().ID)
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJetStreamRedeliveryAfterAckWait(t *testing.T) {
+	url := startJetStream(t)
+	a := newTestJetStreamQueue(t, url, time.Second)
+	b := newTestJetStreamQueue(t, url, time.Second)
+	pushJob(t, a, "crashed", "")
+
+	// a 取出后未确认，模拟实例崩溃
+	first := mustPop(t, a)
+	second := popWithin(t, b, 5*time.Second)
+	if second.Job().ID != first.Job().ID {
+		t.Errorf("got job %s, want %s", second.Job().ID, first.Job().ID)
+	}
+	if err := second.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJetStreamExtendDelaysRedelivery(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Second)
+	pushJob(t, q, "slow", "")
+
+	d := mustPop(t, q)
+	for i := 0; i < 3; i++ {
+		time.Sleep(500 * time.Millisecond)
+		if err := d.Extend(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 持续续期时不会重新投递
+	if _, err := q.Pop(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Pop while extended = %v, want ErrTimeout", err)
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJetStreamLanePriority(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Minute)
+	pushJob(t, q, "bulk", jobqueue.PriorityBulk)
+	pushJob(t, q, "normal", jobqueue.PriorityNormal)
+	pushJob(t, q, "high", jobqueue.PriorityHigh)
+
+	d := mustPop(t, q)
+	if d.Job().ID != "high" {
+		t.Errorf("first job = %s, want high", d.Job().ID)
+	}
+	_ = d.Ack(context.Background())
+
+	// 高优先级任务较多时低优先级通道仍按权重得到处理
+	for i := 0; i < 20; i++ {
+		pushJob(t, q, fmt.Sprintf("high-%02d", i), jobqueue.PriorityHigh)
+	}
+	got := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		d := mustPop(t, q)
+		got[d.Job().ID]++
+		_ = d.Ack(context.Background())
+	}
+	if got["normal"] != 1 || got["bulk"] != 1 {
+		t.Errorf("normal and bulk jobs not served within 10 pops: %v", got)
+	}
+}
+
+func TestJetStreamPopWaitsOnAllLanes(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Minute)
+
+	start := time.Now()
+	if _, err := q.Pop(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Pop on empty queue = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("empty Pop returned after %v, want to wait about 1s", elapsed)
+	}
+
+	// 等待期间到达的高优先级任务立即返回
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		job := jobqueue.EmailJob{ID: "urgent", To: "urgent@example.com", Priority: jobqueue.PriorityHigh}
+		if err := q.Push(context.Background(), job); err != nil {
+			t.Error(err)
+		}
+	}()
+	start = time.Now()
+	d := mustPop(t, q)
+	if d.Job().ID != "urgent" {
+		t.Errorf("got job %s, want urgent", d.Job().ID)
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Errorf("high priority job returned after %v", elapsed)
+	}
+	_ = d.Ack(context.Background())
+
+	// 等待期间到达的低优先级任务同样不需要等到下次调用
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		job := jobqueue.EmailJob{ID: "bulk", To: "bulk@example.com", Priority: jobqueue.PriorityBulk}
+		if err := q.Push(context.Background(), job); err != nil {
+			t.Error(err)
+		}
+	}()
+	start = time.Now()
+	d = mustPop(t, q)
+	if d.Job().ID != "bulk" {
+		t.Errorf("got job %s, want bulk", d.Job().ID)
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Errorf("bulk job returned after %v", elapsed)
+	}
+	_ = d.Ack(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Pop(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Pop with cancelled context = %v", err)
+	}
+}
+
+func TestJetStreamSize(t *testing.T) {
+	q := newTestJetStreamQueue(t, startJetStream(t), time.Minute)
+	pushJob(t, q, "a", jobqueue.PriorityHigh)
+	pushJob(t, q, "b", "")
+	pushJob(t, q, "c", jobqueue.PriorityBulk)
+
+	if size, err := q.Size(); err != nil || size != 3 {
+		t.Fatalf("Size = %d, %v; want 3", size, err)
+	}
+
+	// 正在处理的任务仍计入长度
+	d := mustPop(t, q)
+	if size, err := q.Size(); err != nil || size != 3 {
+		t.Errorf("Size with job in flight = %d, %v; want 3", size, err)
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := q.Size(); err != nil || size != 2 {
+		t.Errorf("Size after ack = %d, %v; want 2", size, err)
+	}
+}
@@ -2,20 +2,65 @@ package queue
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"email-service/internal/logger"
 	"email-service/pkg/jobqueue"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// 任务消息的头部
+const (
+	headerAttempts  = "x-attempts"   // 处理失败次数，推迟处理不计入
+	headerRetryAt   = "x-retry-at"   // 重试主题中的任务转回主主题的时间（Unix 毫秒）
+	headerDLQReason = "x-dlq-reason" // 写入死信主题的原因
+)
+
+// 消息键的取值方式
+const (
+	KafkaKeyRecipient = "recipient" // 按收件人，同一收件人的任务进入同一分区
+	KafkaKeyDomain    = "domain"    // 按收件人域名
+	KafkaKeyNone      = "none"      // 不设置键，轮询分区
 )
 
 // KafkaQueue 定义了Kafka队列
+// 偏移量在任务确认后按分区顺序提交；延迟重投的任务写入重试主题，到期后由转发协程写回主主题。
+// 每个优先级使用独立的主题和消费者组，各由一个协程预取一条消息，Pop 按权重从中选择
 type KafkaQueue struct {
-	writer *kafka.Writer // 写入器
-	reader *kafka.Reader // 读取器
-	topic  string        // 主题
+	writer      *kafka.Writer // 写入器，按消息指定主题
+	lanes       [laneCount]*kafkaLane
+	selector    laneSelector
+	client      *kafka.Client // 用于计算消费者组积压
+	groupID     string
+	keyBy       string
+	retryTopics []KafkaRetryTopic // 按延迟升序排列
+	dlqTopic    string
+	maxAttempts int
+	commits     *commitTracker
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	logger      *logger.Logger
+}
+
+// kafkaLane 一个优先级通道使用的主题与读取器
+type kafkaLane struct {
+	topic    string
+	groupID  string
+	reader   *kafka.Reader
+	messages chan kafka.Message // 已取出等待交给工人的消息
 }
 
 // NewKafkaQueue 创建Kafka队列
@@ -24,64 +69,622 @@ func NewKafkaQueue(cfg *KafkaConfig) (*KafkaQueue, error) {
 		return nil, ErrKafkaConfigRequired
 	}
 
+	keyBy := cfg.KeyBy
+	if keyBy == "" {
+		keyBy = KafkaKeyRecipient
+	}
+	if keyBy != KafkaKeyRecipient && keyBy != KafkaKeyDomain && keyBy != KafkaKeyNone {
+		return nil, fmt.Errorf("%w: unsupported key_by %q", ErrKafkaConfig, keyBy)
+	}
+
+	retryTopics := append([]KafkaRetryTopic(nil), cfg.RetryTopics...)
+	for _, rt := range retryTopics {
+		if rt.Topic == "" || rt.Delay <= 0 {
+			return nil, fmt.Errorf("%w: retry topic requires topic and delay", ErrKafkaConfig)
+		}
+	}
+	sort.Slice(retryTopics, func(i, j int) bool { return retryTopics[i].Delay < retryTopics[j].Delay })
+
+	tlsConfig, err := kafkaTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := kafkaSASLMechanism(cfg.SASL)
+	if err != nil {
+		return nil, err
+	}
+	transport := &kafka.Transport{TLS: tlsConfig, SASL: mechanism}
+	dialer := &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		TLS:           tlsConfig,
+		SASLMechanism: mechanism,
+	}
+
 	writer := &kafka.Writer{
-		Addr:     kafka.TCP(cfg.Brokers...),
-		Topic:    cfg.Topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{}, // 没有键的消息轮询分区
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond, // 任务逐条写入，避免等待凑满批次
+		Transport:    transport,
 	}
 
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  cfg.Brokers,
-		Topic:    cfg.Topic,
-		GroupID:  cfg.GroupID,
-		MinBytes: 10e3, // 10KB，最小消息
-		MaxBytes: 10e6, // 10MB，最大消息
-	})
+	ctx, cancel := context.WithCancel(context.Background())
+	k := &KafkaQueue{
+		writer:      writer,
+		client:      &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Transport: transport},
+		groupID:     cfg.GroupID,
+		keyBy:       keyBy,
+		retryTopics: retryTopics,
+		dlqTopic:    cfg.DLQTopic,
+		maxAttempts: cfg.MaxAttempts,
+		commits:     newCommitTracker(),
+		cancel:      cancel,
+		logger:      logger.GetDefault().WithComponent("kafka-queue"),
+	}
+
+	topics := laneKeys(cfg.Topic, ".")
+	groups := laneKeys(cfg.GroupID, ".")
+	for i := range k.lanes {
+		lane := &kafkaLane{
+			topic:   topics[i],
+			groupID: groups[i],
+			reader: kafka.NewReader(kafka.ReaderConfig{
+				Brokers:  cfg.Brokers,
+				Topic:    topics[i],
+				GroupID:  groups[i],
+				Dialer:   dialer,
+				MinBytes: 1,
+				MaxBytes: 10e6, // 10MB，最大消息
+			}),
+			messages: make(chan kafka.Message),
+		}
+		k.lanes[i] = lane
+		k.wg.Add(1)
+		go k.prefetch(ctx, lane)
+	}
+
+	for _, rt := range retryTopics {
+		retryReader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:  cfg.Brokers,
+			Topic:    rt.Topic,
+			GroupID:  k.retryGroupID(rt.Topic),
+			Dialer:   dialer,
+			MinBytes: 1,
+			MaxBytes: 10e6,
+		})
+		k.wg.Add(1)
+		go k.forwardRetries(ctx, rt, retryReader)
+	}
 
-	return &KafkaQueue{
-		writer: writer,
-		reader: reader,
-		topic:  cfg.Topic,
-	}, nil
+	return k, nil
 }
 
-// Push 将任务推送到Kafka队列
+// Push 将任务推送到Kafka队列，尚未到重试时间的任务直接写入重试主题
 func (k *KafkaQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
+	if delay := time.Until(job.NextRetryAt); delay > 0 && len(k.retryTopics) > 0 {
+		return k.writeRetry(ctx, job, nil, delay)
+	}
+	return k.write(ctx, k.laneTopic(job.Priority), job, nil)
+}
+
+// Pop 按权重从各优先级主题获取任务，偏移量在 Ack 时才提交
+func (k *KafkaQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	for _, i := range k.selector.order() {
+		select {
+		case m := <-k.lanes[i].messages:
+			return k.delivery(ctx, k.lanes[i], m)
+		default:
+		}
+	}
+
+	select {
+	case m := <-k.lanes[laneHigh].messages:
+		return k.delivery(ctx, k.lanes[laneHigh], m)
+	case m := <-k.lanes[laneNormal].messages:
+		return k.delivery(ctx, k.lanes[laneNormal], m)
+	case m := <-k.lanes[laneBulk].messages:
+		return k.delivery(ctx, k.lanes[laneBulk], m)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prefetch 持续从一个优先级主题读取消息，每次最多持有一条未交给工人的消息
+func (k *KafkaQueue) prefetch(ctx context.Context, lane *kafkaLane) {
+	defer k.wg.Done()
+
+	for {
+		m, err := lane.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			k.logger.Error("Failed to fetch message", "topic", lane.topic, "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		select {
+		case lane.messages <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// delivery 将消息转换为 Delivery
+func (k *KafkaQueue) delivery(ctx context.Context, lane *kafkaLane, m kafka.Message) (jobqueue.Delivery, error) {
+	k.commits.track(m)
+
+	d := &kafkaDelivery{queue: k, lane: lane, msg: m}
+	if err := json.Unmarshal(m.Value, &d.job); err != nil {
+		// 无法解析的消息写入死信主题后提交，避免反复读取
+		if dlqErr := d.deadLetter(ctx, "invalid job payload: "+err.Error()); dlqErr != nil {
+			return nil, dlqErr
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close 关闭Kafka队列
+func (k *KafkaQueue) Close() error {
+	k.cancel()
+	k.wg.Wait()
+	err := k.writer.Close()
+	for _, lane := range k.lanes {
+		if closeErr := lane.reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Size 返回消费者组在主主题和重试主题上的积压消息数
+func (k *KafkaQueue) Size() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var total int64
+	for _, lane := range k.lanes {
+		n, err := k.lag(ctx, lane.topic, lane.groupID)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	for _, rt := range k.retryTopics {
+		n, err := k.lag(ctx, rt.Topic, k.retryGroupID(rt.Topic))
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return int(total), nil
+}
+
+// lag 计算消费者组在主题各分区上最新偏移量与已提交偏移量之差
+func (k *KafkaQueue) lag(ctx context.Context, topic, groupID string) (int64, error) {
+	meta, err := k.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return 0, err
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return 0, t.Error
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return 0, nil
+	}
+
+	committed, err := k.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if committed.Error != nil {
+		return 0, committed.Error
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, len(partitions)*2)
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := k.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	commitOffsets := make(map[int]int64, len(partitions))
+	for _, p := range committed.Topics[topic] {
+		if p.Error != nil {
+			return 0, p.Error
+		}
+		commitOffsets[p.Partition] = p.CommittedOffset
+	}
+
+	var total int64
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			return 0, p.Error
+		}
+		start, ok := commitOffsets[p.Partition]
+		if !ok || start < p.FirstOffset {
+			// 消费者组尚未提交过偏移量，读取器从最早的消息开始消费
+			start = p.FirstOffset
+		}
+		if p.LastOffset > start {
+			total += p.LastOffset - start
+		}
+	}
+	return total, nil
+}
+
+// write 将任务写入指定主题
+func (k *KafkaQueue) write(ctx context.Context, topic string, job jobqueue.EmailJob, headers []kafka.Header) error {
 	data, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
 	msg := kafka.Message{
-		Value: data,
-		Time:  time.Now(),
+		Topic:   topic,
+		Key:     k.messageKey(&job),
+		Value:   data,
+		Headers: headers,
+		Time:    time.Now(),
 	}
 	return k.writer.WriteMessages(ctx, msg)
 }
 
-// Pop 从Kafka队列中获取任务
-func (k *KafkaQueue) Pop(ctx context.Context) (jobqueue.EmailJob, error) {
-	m, err := k.reader.ReadMessage(ctx)
+// writeRetry 将任务写入延迟最接近的重试主题
+// 超过最长延迟时写入最后一个主题，转发协程取出时尚未到期的任务会按剩余延迟重新写入重试主题
+func (k *KafkaQueue) writeRetry(ctx context.Context, job jobqueue.EmailJob, headers []kafka.Header, delay time.Duration) error {
+	retryAt := time.Now().Add(delay).UnixMilli()
+	headers = setHeader(headers, headerRetryAt, strconv.FormatInt(retryAt, 10))
+	return k.write(ctx, k.retryTopic(delay).Topic, job, headers)
+}
+
+// retryTopic 返回延迟不小于 delay 的第一个重试主题，没有时返回最后一个主题
+func (k *KafkaQueue) retryTopic(delay time.Duration) KafkaRetryTopic {
+	for _, rt := range k.retryTopics {
+		if rt.Delay >= delay {
+			return rt
+		}
+	}
+	return k.retryTopics[len(k.retryTopics)-1]
+}
+
+// laneTopic 返回任务优先级对应的主题
+func (k *KafkaQueue) laneTopic(priority string) string {
+	return k.lanes[laneOf(priority)].topic
+}
+
+// messageKey 返回任务的消息键，相同键的任务写入同一分区以保证顺序
+func (k *KafkaQueue) messageKey(job *jobqueue.EmailJob) []byte {
+	switch k.keyBy {
+	case KafkaKeyNone:
+		return nil
+	case KafkaKeyDomain:
+		if i := strings.LastIndex(job.To, "@"); i >= 0 {
+			return []byte(strings.ToLower(job.To[i+1:]))
+		}
+	}
+	return []byte(strings.ToLower(job.To))
+}
+
+// retryGroupID 返回重试主题转发协程使用的消费者组
+func (k *KafkaQueue) retryGroupID(topic string) string {
+	return k.groupID + "." + topic
+}
+
+// forwardRetries 读取重试主题，消息在主题中停留满该主题的延迟后处理并提交偏移量：
+// 已到重试时间的任务写回主主题，未到期的任务按剩余延迟重新写入重试主题。
+// 消息按写入时间排列，等待时间不超过主题的延迟，远期任务不会阻塞其后已到期的任务
+func (k *KafkaQueue) forwardRetries(ctx context.Context, rt KafkaRetryTopic, reader *kafka.Reader) {
+	defer k.wg.Done()
+	defer reader.Close()
+
+	for {
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			k.logger.Error("Failed to fetch retry message", "topic", reader.Config().Topic, "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		written := m.Time
+		if written.IsZero() {
+			written = time.Now()
+		}
+		due := written.Add(rt.Delay)
+		retryAt, parseErr := strconv.ParseInt(headerValue(m.Headers, headerRetryAt), 10, 64)
+		if parseErr == nil && time.UnixMilli(retryAt).Before(due) {
+			due = time.UnixMilli(retryAt)
+		}
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		// 写回失败时不提交，重启后会重新转发
+		var job struct {
+			Priority string `json:"priority"`
+		}
+		_ = json.Unmarshal(m.Value, &job)
+		msg := kafka.Message{
+			Topic:   k.laneTopic(job.Priority),
+			Key:     m.Key,
+			Value:   m.Value,
+			Headers: removeHeader(m.Headers, headerRetryAt),
+			Time:    time.Now(),
+		}
+		if remaining := time.Until(time.UnixMilli(retryAt)); parseErr == nil && remaining > 0 {
+			msg.Topic = k.retryTopic(remaining).Topic
+			msg.Headers = m.Headers
+		}
+		for {
+			err = k.writer.WriteMessages(ctx, msg)
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			k.logger.Error("Failed to forward retry message", "topic", m.Topic, "offset", m.Offset, "error", err)
+			time.Sleep(time.Second)
+		}
+		if err != nil {
+			return
+		}
+		if err := reader.CommitMessages(ctx, m); err != nil {
+			k.logger.Error("Failed to commit retry message", "topic", m.Topic, "offset", m.Offset, "error", err)
+		}
+	}
+}
+
+// kafkaDelivery Kafka 取出的任务
+type kafkaDelivery struct {
+	queue *KafkaQueue
+	lane  *kafkaLane
+	msg   kafka.Message
+	job   jobqueue.EmailJob
+}
+
+// Job 返回任务内容
+func (d *kafkaDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 确认任务，分区内此前取出的任务都已确认时才提交偏移量
+func (d *kafkaDelivery) Ack(ctx context.Context) error {
+	k := d.queue
+	if m, ok := k.commits.done(d.msg); ok {
+		return d.lane.reader.CommitMessages(ctx, m)
+	}
+	return nil
+}
+
+// Nack 处理失败，将任务写入重试主题后确认原消息；处理失败次数达到上限时写入死信主题
+// 未配置重试主题时在进程内延迟后写回主主题，期间进程退出则原消息未提交，重启后会重新读取
+func (d *kafkaDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	k := d.queue
+	attempts, _ := strconv.Atoi(headerValue(d.msg.Headers, headerAttempts))
+	attempts++
+	if k.maxAttempts > 0 && attempts >= k.maxAttempts {
+		return d.deadLetter(ctx, fmt.Sprintf("exceeded %d delivery attempts", k.maxAttempts))
+	}
+	headers := setHeader(removeHeader(d.msg.Headers, headerRetryAt), headerAttempts, strconv.Itoa(attempts))
+	return d.requeue(ctx, headers, requeueAfter)
+}
+
+// Defer 推迟任务，与 Nack 相同但不计入处理失败次数
+func (d *kafkaDelivery) Defer(ctx context.Context, after time.Duration) error {
+	return d.requeue(ctx, removeHeader(d.msg.Headers, headerRetryAt), after)
+}
+
+// requeue 将任务连同 headers 重新写入队列后确认原消息
+func (d *kafkaDelivery) requeue(ctx context.Context, headers []kafka.Header, requeueAfter time.Duration) error {
+	k := d.queue
+	if len(k.retryTopics) > 0 && requeueAfter > 0 {
+		if err := k.writeRetry(ctx, d.job, headers, requeueAfter); err != nil {
+			return err
+		}
+		return d.Ack(ctx)
+	}
+
+	requeue := func(ctx context.Context) error {
+		if err := k.write(ctx, d.lane.topic, d.job, headers); err != nil {
+			return err
+		}
+		return d.Ack(ctx)
+	}
+	if requeueAfter <= 0 {
+		return requeue(ctx)
+	}
+	time.AfterFunc(requeueAfter, func() {
+		if err := requeue(context.Background()); err != nil {
+			k.logger.Error("Failed to requeue delayed job",
+				"recipient", d.job.To,
+				"job_id", d.job.ID,
+				"error", err)
+		}
+	})
+	return nil
+}
+
+// Extend Kafka 消息在提交前不会被其他消费者取走，无需续期
+func (d *kafkaDelivery) Extend(ctx context.Context) error {
+	return nil
+}
+
+// deadLetter 将原消息写入死信主题并确认；未配置死信主题时只记录日志
+func (d *kafkaDelivery) deadLetter(ctx context.Context, reason string) error {
+	k := d.queue
+	if k.dlqTopic == "" {
+		k.logger.Warn("Dropping job without dead letter topic",
+			"job_id", d.job.ID,
+			"offset", d.msg.Offset,
+			"reason", reason)
+		return d.Ack(ctx)
+	}
+	err := k.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   k.dlqTopic,
+		Key:     d.msg.Key,
+		Value:   d.msg.Value,
+		Headers: setHeader(removeHeader(d.msg.Headers, headerRetryAt), headerDLQReason, reason),
+		Time:    time.Now(),
+	})
 	if err != nil {
-		return jobqueue.EmailJob{}, err
+		return err
 	}
-	var job jobqueue.EmailJob
-	if err := json.Unmarshal(m.Value, &job); err != nil {
-		return jobqueue.EmailJob{}, err
+	k.logger.Warn("Job moved to dead letter topic", "job_id", d.job.ID, "topic", k.dlqTopic, "reason", reason)
+	return d.Ack(ctx)
+}
+
+// commitTracker 记录各分区已取出但未确认的偏移量
+// 多个工人并发处理同一分区的消息，只有较早的消息都确认后才能提交较新的偏移量，否则进程退出时会丢失任务
+type commitTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type partitionOffsets struct {
+	pending []int64                 // 按取出顺序排列
+	acked   map[int64]kafka.Message // 已确认但尚未提交
+}
+
+func newCommitTracker() *commitTracker {
+	return &commitTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+// track 记录取出的消息
+func (t *commitTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{m.Topic, m.Partition}
+	p := t.partitions[key]
+	if p == nil || (len(p.pending) > 0 && m.Offset <= p.pending[len(p.pending)-1]) {
+		// 分区重新分配后读取器会从已提交位置重新读取，丢弃之前的记录
+		p = &partitionOffsets{acked: make(map[int64]kafka.Message)}
+		t.partitions[key] = p
 	}
-	return job, nil
+	p.pending = append(p.pending, m.Offset)
 }
 
-// Close 关闭Kafka队列
-func (k *KafkaQueue) Close() error {
-	err1 := k.writer.Close()
-	err2 := k.reader.Close()
-	if err1 != nil {
-		return err1
+// done 标记消息已确认，返回可以提交的最新消息
+func (t *commitTracker) done(m kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partitions[topicPartition{m.Topic, m.Partition}]
+	if p == nil || len(p.pending) == 0 || m.Offset < p.pending[0] {
+		return kafka.Message{}, false
 	}
-	return err2
+	p.acked[m.Offset] = m
+
+	var commit kafka.Message
+	ok := false
+	for len(p.pending) > 0 {
+		acked, found := p.acked[p.pending[0]]
+		if !found {
+			break
+		}
+		delete(p.acked, p.pending[0])
+		p.pending = p.pending[1:]
+		commit, ok = acked, true
+	}
+	return commit, ok
 }
 
-func (k *KafkaQueue) Size() (int, error) {
-	// Kafka 不支持直接获取队列长度，返回-1
-	return -1, nil
+// headerValue 返回消息头部的值
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// setHeader 返回设置了指定头部的新切片
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	return append(removeHeader(headers, key), kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// removeHeader 返回去掉指定头部的新切片
+func removeHeader(headers []kafka.Header, key string) []kafka.Header {
+	out := make([]kafka.Header, 0, len(headers)+1)
+	for _, h := range headers {
+		if h.Key != key {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// kafkaTLSConfig 根据配置生成 TLS 配置，未启用时返回 nil
+func kafkaTLSConfig(cfg *KafkaTLSConfig) (*tls.Config, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates found in %s", ErrKafkaConfig, cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// kafkaSASLMechanism 根据配置生成 SASL 认证机制，未配置时返回 nil
+func kafkaSASLMechanism(cfg *KafkaSASLConfig) (sasl.Mechanism, error) {
+	if cfg == nil || cfg.Mechanism == "" {
+		return nil, nil
+	}
+	switch strings.ToLower(cfg.Mechanism) {
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("%w: unsupported sasl mechanism %q", ErrKafkaConfig, cfg.Mechanism)
+	}
 }
@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"sync"
+
+	"email-service/pkg/jobqueue"
+)
+
+// 优先级通道的下标，数值越小优先级越高
+const (
+	laneHigh = iota
+	laneNormal
+	laneBulk
+	laneCount
+)
+
+// laneNames 各通道对应的优先级
+var laneNames = [laneCount]string{jobqueue.PriorityHigh, jobqueue.PriorityNormal, jobqueue.PriorityBulk}
+
+// laneWeights 各通道在所有通道都有任务时被选中的比例，high:normal:bulk = 6:3:1
+var laneWeights = [laneCount]int{6, 3, 1}
+
+// laneOf 返回任务所在的通道，未设置或未知的优先级归入 normal
+func laneOf(priority string) int {
+	switch priority {
+	case jobqueue.PriorityHigh:
+		return laneHigh
+	case jobqueue.PriorityBulk:
+		return laneBulk
+	default:
+		return laneNormal
+	}
+}
+
+// laneSelector 按权重轮询各通道（平滑加权轮询）
+// 每次取任务时先尝试选中的通道，为空时再按优先级尝试其余通道，
+// 因此高优先级任务多时低优先级通道仍能按比例得到处理，任何通道有任务时都不会空转
+type laneSelector struct {
+	mu      sync.Mutex
+	current [laneCount]int
+}
+
+// order 返回本次尝试各通道的顺序
+func (s *laneSelector) order() [laneCount]int {
+	s.mu.Lock()
+	total, best := 0, 0
+	for i, w := range laneWeights {
+		s.current[i] += w
+		total += w
+		if s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	s.current[best] -= total
+	s.mu.Unlock()
+
+	order := [laneCount]int{best}
+	n := 1
+	for i := 0; i < laneCount; i++ {
+		if i != best {
+			order[n] = i
+			n++
+		}
+	}
+	return order
+}
+
+// laneKeys 返回各通道使用的键名或主题名，normal 通道沿用原名以兼容已有数据
+func laneKeys(base, sep string) [laneCount]string {
+	return [laneCount]string{
+		laneHigh:   base + sep + jobqueue.PriorityHigh,
+		laneNormal: base,
+		laneBulk:   base + sep + jobqueue.PriorityBulk,
+	}
+}
@@ -6,22 +6,25 @@ import (
 	"email-service/pkg/jobqueue"
 )
 
-// MemoryQueue 内存队列实现
+// MemoryQueue 内存队列实现，每个优先级一个通道
 type MemoryQueue struct {
-	jobChan chan jobqueue.EmailJob
+	lanes    [laneCount]chan jobqueue.EmailJob
+	selector laneSelector
 }
 
-// NewMemoryQueue 创建新的内存队列
+// NewMemoryQueue 创建新的内存队列，每个优先级通道的容量均为 bufferSize
 func NewMemoryQueue(bufferSize int) *MemoryQueue {
-	return &MemoryQueue{
-		jobChan: make(chan jobqueue.EmailJob, bufferSize),
+	q := &MemoryQueue{}
+	for i := range q.lanes {
+		q.lanes[i] = make(chan jobqueue.EmailJob, bufferSize)
 	}
+	return q
 }
 
-// Push 将任务推入队列
+// Push 将任务推入对应优先级的通道
 func (m *MemoryQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
 	select {
-	case m.jobChan <- job:
+	case m.lanes[laneOf(job.Priority)] <- job:
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
@@ -31,23 +34,41 @@ func (m *MemoryQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
 	}
 }
 
-// Pop 从队列中弹出任务
-func (m *MemoryQueue) Pop(ctx context.Context) (jobqueue.EmailJob, error) {
+// Pop 按权重从各优先级通道取出任务，所有通道为空时阻塞
+func (m *MemoryQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	for _, lane := range m.selector.order() {
+		select {
+		case job := <-m.lanes[lane]:
+			return &requeueDelivery{job: job, queue: m}, nil
+		default:
+		}
+	}
+
 	select {
-	case job := <-m.jobChan:
-		return job, nil
+	case job := <-m.lanes[laneHigh]:
+		return &requeueDelivery{job: job, queue: m}, nil
+	case job := <-m.lanes[laneNormal]:
+		return &requeueDelivery{job: job, queue: m}, nil
+	case job := <-m.lanes[laneBulk]:
+		return &requeueDelivery{job: job, queue: m}, nil
 	case <-ctx.Done():
-		return jobqueue.EmailJob{}, ctx.Err()
+		return nil, ctx.Err()
 	}
 }
 
 // Close 关闭队列
 func (m *MemoryQueue) Close() error {
-	close(m.jobChan)
+	for _, lane := range m.lanes {
+		close(lane)
+	}
 	return nil
 }
 
 // Size 返回队列中待处理任务数量
 func (m *MemoryQueue) Size() (int, error) {
-	return len(m.jobChan), nil
+	n := 0
+	for _, lane := range m.lanes {
+		n += len(lane)
+	}
+	return n, nil
 }
@@ -6,16 +6,18 @@ import (
 	"time"
 
 	"email-service/internal/mailer"
+	"email-service/pkg/jobqueue"
 
 	"github.com/nats-io/nats.go"
 )
 
-// NATSQueue NATS队列实现
+// NATSQueue NATS队列实现，每个优先级订阅一个主题
 type NATSQueue struct {
-	conn    *nats.Conn
-	subject string
-	sub     *nats.Subscription
-	msgChan chan *nats.Msg
+	conn     *nats.Conn
+	subjects [laneCount]string
+	subs     [laneCount]*nats.Subscription
+	msgChans [laneCount]chan *nats.Msg
+	selector laneSelector
 }
 
 // NewNATSQueue 创建新的NATS队列
@@ -30,21 +32,22 @@ func NewNATSQueue(config *NATSConfig) (*NATSQueue, error) {
 		subject = "email.jobs"
 	}
 
-	msgChan := make(chan *nats.Msg, 1000)
-
-	// 创建订阅
-	sub, err := conn.ChanSubscribe(subject, msgChan)
-	if err != nil {
-		conn.Close()
-		return nil, err
+	q := &NATSQueue{
+		conn:     conn,
+		subjects: laneKeys(subject, "."),
 	}
+	for i, subj := range q.subjects {
+		q.msgChans[i] = make(chan *nats.Msg, 1000)
 
-	return &NATSQueue{
-		conn:    conn,
-		subject: subject,
-		sub:     sub,
-		msgChan: msgChan,
-	}, nil
+		// 创建订阅
+		sub, err := conn.ChanSubscribe(subj, q.msgChans[i])
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		q.subs[i] = sub
+	}
+	return q, nil
 }
 
 // Push 将任务推入队列
@@ -54,45 +57,57 @@ func (n *NATSQueue) Push(ctx context.Context, job mailer.EmailJob) error {
 		return err
 	}
 
-	return n.conn.Publish(n.subject, data)
+	return n.conn.Publish(n.subjects[laneOf(job.Priority)], data)
 }
 
-// Pop 从队列中弹出任务
-func (n *NATSQueue) Pop(ctx context.Context) (mailer.EmailJob, error) {
-	var job mailer.EmailJob
+// Pop 按权重从各优先级主题取出任务
+func (n *NATSQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	for _, lane := range n.selector.order() {
+		select {
+		case msg := <-n.msgChans[lane]:
+			return n.delivery(msg)
+		default:
+		}
+	}
 
 	select {
-	case msg := <-n.msgChan:
-		err := json.Unmarshal(msg.Data, &job)
-		if err != nil {
-			return job, err
-		}
-		// 确认消息已处理
-		err = msg.Ack()
-		if err != nil {
-			return mailer.EmailJob{}, err
-		}
-		return job, nil
+	case msg := <-n.msgChans[laneHigh]:
+		return n.delivery(msg)
+	case msg := <-n.msgChans[laneNormal]:
+		return n.delivery(msg)
+	case msg := <-n.msgChans[laneBulk]:
+		return n.delivery(msg)
 	case <-ctx.Done():
-		return job, ctx.Err()
+		return nil, ctx.Err()
 	case <-time.After(1 * time.Second):
 		// 超时返回，让调用者重试
-		return job, ErrTimeout
+		return nil, ErrTimeout
+	}
+}
+
+func (n *NATSQueue) delivery(msg *nats.Msg) (jobqueue.Delivery, error) {
+	var job mailer.EmailJob
+	if err := json.Unmarshal(msg.Data, &job); err != nil {
+		return nil, err
 	}
+	return &natsDelivery{requeueDelivery: requeueDelivery{job: job, queue: n}, msg: msg}, nil
 }
 
 // Close 关闭连接
 func (n *NATSQueue) Close() error {
-	if n.sub != nil {
-		err := n.sub.Unsubscribe()
-		if err != nil {
-			return err
+	for _, sub := range n.subs {
+		if sub != nil {
+			if err := sub.Unsubscribe(); err != nil {
+				return err
+			}
 		}
 	}
 	if n.conn != nil {
 		n.conn.Close()
 	}
-	close(n.msgChan)
+	for _, ch := range n.msgChans {
+		close(ch)
+	}
 	return nil
 }
 
@@ -100,3 +115,26 @@ func (n *NATSQueue) Close() error {
 func (n *NATSQueue) Size() (int, error) {
 	return -1, ErrNotSupported
 }
+
+// natsDelivery NATS 核心订阅取出的任务
+// 核心订阅的消息没有确认机制，只有带回复地址的消息才会发送确认
+type natsDelivery struct {
+	requeueDelivery
+	msg *nats.Msg
+}
+
+// Ack 确认消息
+func (d *natsDelivery) Ack(ctx context.Context) error {
+	if d.msg.Reply == "" {
+		return nil
+	}
+	return d.msg.Ack()
+}
+
+// Extend 通知服务端消息仍在处理
+func (d *natsDelivery) Extend(ctx context.Context) error {
+	if d.msg.Reply == "" {
+		return nil
+	}
+	return d.msg.InProgress()
+}
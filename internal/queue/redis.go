@@ -7,14 +7,16 @@ import (
 	"time"
 
 	"email-service/internal/mailer"
+	"email-service/pkg/jobqueue"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisQueue Redis队列实现
+// RedisQueue Redis队列实现，每个优先级一个列表
 type RedisQueue struct {
 	client   *redis.Client
-	queueKey string
+	keys     [laneCount]string
+	selector laneSelector
 }
 
 // NewRedisQueue 创建新的Redis队列
@@ -39,8 +41,8 @@ func NewRedisQueue(config *RedisConfig) (*RedisQueue, error) {
 	}
 
 	return &RedisQueue{
-		client:   client,
-		queueKey: queueKey,
+		client: client,
+		keys:   laneKeys(queueKey, ":"),
 	}, nil
 }
 
@@ -51,47 +53,62 @@ func (r *RedisQueue) Push(ctx context.Context, job mailer.EmailJob) error {
 		return err
 	}
 
-	return r.client.LPush(ctx, r.queueKey, data).Err()
+	return r.client.LPush(ctx, r.keys[laneOf(job.Priority)], data).Err()
 }
 
 // Pop 从队列中弹出任务（阻塞式）
-func (r *RedisQueue) Pop(ctx context.Context) (mailer.EmailJob, error) {
+// 任务弹出后即从 Redis 删除，需要至少一次投递时使用可靠模式
+func (r *RedisQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
 	var job mailer.EmailJob
 
-	// 使用BRPOP进行阻塞式弹出，超时时间设为1秒
-	result, err := r.client.BRPop(ctx, 1*time.Second, r.queueKey).Result()
+	// 使用BRPOP进行阻塞式弹出，超时时间设为1秒；BRPOP 按键的顺序检查，因此按权重排列各通道
+	result, err := r.client.BRPop(ctx, 1*time.Second, r.laneOrder()...).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			// 超时，返回空任务和nil错误，让调用者重试
-			return job, ErrTimeout
+			// 超时，返回ErrTimeout，让调用者重试
+			return nil, ErrTimeout
 		}
-		return job, err
+		return nil, err
 	}
 
 	// result[0]是key, result[1]是value
 	if len(result) < 2 {
-		return job, ErrInvalidResult
+		return nil, ErrInvalidResult
 	}
 
-	err = json.Unmarshal([]byte(result[1]), &job)
-	return job, err
+	if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
+		return nil, err
+	}
+	return &requeueDelivery{job: job, queue: r}, nil
 }
 
 // PopNonBlocking 从队列中弹出任务（非阻塞式）
 func (r *RedisQueue) PopNonBlocking(ctx context.Context) (mailer.EmailJob, error) {
 	var job mailer.EmailJob
 
-	result, err := r.client.RPop(ctx, r.queueKey).Result()
-	if err != nil {
+	for _, key := range r.laneOrder() {
+		result, err := r.client.RPop(ctx, key).Result()
 		if errors.Is(err, redis.Nil) {
-			// 队列为空，返回空任务和nil错误，让调用者重试
-			return job, ErrTimeout
+			continue
+		}
+		if err != nil {
+			return job, err
 		}
+		err = json.Unmarshal([]byte(result), &job)
 		return job, err
 	}
+	// 队列为空，返回空任务和ErrTimeout，让调用者重试
+	return job, ErrTimeout
+}
 
-	err = json.Unmarshal([]byte(result), &job)
-	return job, err
+// laneOrder 返回本次尝试的列表顺序
+func (r *RedisQueue) laneOrder() []string {
+	order := r.selector.order()
+	keys := make([]string, 0, laneCount)
+	for _, lane := range order {
+		keys = append(keys, r.keys[lane])
+	}
+	return keys
 }
 
 // Close 关闭连接
@@ -102,6 +119,17 @@ func (r *RedisQueue) Close() error {
 // Size 返回队列中待处理任务数量
 func (r *RedisQueue) Size() (int, error) {
 	ctx := context.Background()
-	length, err := r.client.LLen(ctx, r.queueKey).Result()
-	return int(length), err
+	pipe := r.client.Pipeline()
+	var cmds [laneCount]*redis.IntCmd
+	for i, key := range r.keys {
+		cmds[i] = pipe.LLen(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, cmd := range cmds {
+		total += int(cmd.Val())
+	}
+	return total, nil
 }
@@ -0,0 +1,441 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// promoteScript 将到期的延迟任务移回 Stream，保证移动是原子的
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+	local sep = string.find(member, '\n', 1, true)
+	redis.call('XADD', KEYS[1], '*', 'job', string.sub(member, sep + 1))
+	redis.call('ZREM', KEYS[2], member)
+end
+return #due
+`)
+
+// ownedCheck 检查消息是否仍在本消费者的待确认列表中，不在时返回 0
+// KEYS[1] 为 Stream，ARGV[1..3] 为消费者组、消息ID和消费者
+const ownedCheck = `
+if #redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1, ARGV[3]) == 0 then return 0 end
+`
+
+// ackScript 消息仍属于本消费者时确认并删除
+var ackScript = redis.NewScript(ownedCheck + `
+redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+redis.call('XDEL', KEYS[1], ARGV[2])
+return 1
+`)
+
+// nackScript 消息仍属于本消费者时重新入队并删除原消息，ARGV[5] 大于 0 时以该分数放入延迟集合 KEYS[2]
+var nackScript = redis.NewScript(ownedCheck + `
+if tonumber(ARGV[5]) > 0 then
+	redis.call('ZADD', KEYS[2], ARGV[5], ARGV[2] .. '\n' .. ARGV[4])
+else
+	redis.call('XADD', KEYS[1], '*', 'job', ARGV[4])
+end
+redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+redis.call('XDEL', KEYS[1], ARGV[2])
+return 1
+`)
+
+// extendScript 消息仍属于本消费者时重置其空闲时间
+var extendScript = redis.NewScript(ownedCheck + `
+redis.call('XCLAIM', KEYS[1], ARGV[1], ARGV[3], 0, ARGV[2], 'JUSTID')
+return 1
+`)
+
+// ReliableRedisQueue 基于 Redis Streams 消费者组的可靠队列
+// 任务被取出后进入消费者组的待确认列表，Ack 后删除；超过可见性超时未确认的任务由回收协程通过
+// XAUTOCLAIM 认领并重新投递，延迟重投的任务暂存在有序集合中，到期后移回 Stream。
+// 每个优先级使用独立的 Stream 和延迟集合
+type ReliableRedisQueue struct {
+	client            *redis.Client
+	lanes             [laneCount]streamLane
+	group             string
+	consumer          string
+	visibilityTimeout time.Duration
+	selector          laneSelector
+	reclaimed         chan laneMessage
+	mu                sync.Mutex
+	pending           map[string]bool // 已分配给本消费者但尚未交给工人的任务，避免重复放入 reclaimed
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+	logger            *logger.Logger
+}
+
+// streamLane 一个优先级通道使用的 Stream 和延迟集合
+type streamLane struct {
+	stream  string
+	delayed string
+}
+
+// laneMessage 已分配给本消费者、等待交给工人的消息
+type laneMessage struct {
+	lane int
+	msg  redis.XMessage
+}
+
+// NewReliableRedisQueue 创建可靠 Redis 队列
+func NewReliableRedisQueue(config *RedisConfig) (*ReliableRedisQueue, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	stream := config.QueueKey
+	if stream == "" {
+		stream = "email:jobs:stream"
+	}
+	group := config.Group
+	if group == "" {
+		group = "email-workers"
+	}
+	consumer := config.Consumer
+	if consumer == "" {
+		host, _ := os.Hostname()
+		consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	visibilityTimeout := config.VisibilityTimeout
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 5 * time.Minute
+	}
+	reapInterval := config.ReapInterval
+	if reapInterval <= 0 {
+		reapInterval = 30 * time.Second
+	}
+
+	var lanes [laneCount]streamLane
+	for i, key := range laneKeys(stream, ":") {
+		lanes[i] = streamLane{stream: key, delayed: key + ":delayed"}
+
+		// 创建消费者组，从 Stream 开头消费以包含组创建前写入的任务
+		err := client.XGroupCreateMkStream(ctx, key, group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			client.Close()
+			return nil, fmt.Errorf("failed to create consumer group: %w", err)
+		}
+	}
+
+	reapCtx, reapCancel := context.WithCancel(context.Background())
+	q := &ReliableRedisQueue{
+		client:            client,
+		lanes:             lanes,
+		group:             group,
+		consumer:          consumer,
+		visibilityTimeout: visibilityTimeout,
+		reclaimed:         make(chan laneMessage, 100),
+		pending:           make(map[string]bool),
+		cancel:            reapCancel,
+		logger:            logger.GetDefault().WithComponent("redis-queue"),
+	}
+	q.wg.Add(1)
+	go q.reap(reapCtx, reapInterval)
+	return q, nil
+}
+
+// Push 将任务推入队列
+func (r *ReliableRedisQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.lanes[laneOf(job.Priority)].stream,
+		Values: map[string]any{"job": data},
+	}).Err()
+}
+
+// Pop 取出任务，优先返回被回收的超时任务，其次按权重依次尝试各优先级的 Stream，都为空时阻塞等待
+func (r *ReliableRedisQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	if lm, ok := r.nextReclaimed(ctx); ok {
+		return r.delivery(ctx, lm.lane, lm.msg)
+	}
+
+	for _, lane := range r.selector.order() {
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.group,
+			Consumer: r.consumer,
+			Streams:  []string{r.lanes[lane].stream, ">"},
+			Count:    1,
+			Block:    -1, // 不阻塞
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if len(streams) > 0 && len(streams[0].Messages) > 0 {
+			return r.delivery(ctx, lane, streams[0].Messages[0])
+		}
+	}
+
+	keys := make([]string, 0, laneCount*2)
+	for _, l := range r.lanes {
+		keys = append(keys, l.stream)
+	}
+	for range r.lanes {
+		keys = append(keys, ">")
+	}
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.consumer,
+		Streams:  keys,
+		Count:    1,
+		Block:    time.Second,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrTimeout
+		}
+		return nil, err
+	}
+
+	// 多个 Stream 同时有任务时每个 Stream 各返回一条，只处理优先级最高的一条，其余暂存等待下次取出
+	var received []laneMessage
+	for _, st := range streams {
+		for _, msg := range st.Messages {
+			received = append(received, laneMessage{lane: r.laneOfStream(st.Stream), msg: msg})
+		}
+	}
+	if len(received) == 0 {
+		return nil, ErrTimeout
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i].lane < received[j].lane })
+	for _, lm := range received[1:] {
+		r.hold(lm)
+	}
+	return r.delivery(ctx, received[0].lane, received[0].msg)
+}
+
+// laneOfStream 返回 Stream 对应的通道
+func (r *ReliableRedisQueue) laneOfStream(stream string) int {
+	for i, l := range r.lanes {
+		if l.stream == stream {
+			return i
+		}
+	}
+	return laneNormal
+}
+
+// hold 将已分配给本消费者的消息放入 reclaimed 等待交给工人；缓冲已满时留在待确认列表中，超时后再回收
+func (r *ReliableRedisQueue) hold(lm laneMessage) bool {
+	r.mu.Lock()
+	queued := r.pending[lm.msg.ID]
+	r.pending[lm.msg.ID] = true
+	r.mu.Unlock()
+	if queued {
+		return false
+	}
+
+	select {
+	case r.reclaimed <- lm:
+		return true
+	default:
+		r.mu.Lock()
+		delete(r.pending, lm.msg.ID)
+		r.mu.Unlock()
+		return false
+	}
+}
+
+// nextReclaimed 返回下一个仍未被确认的回收任务；原消费者在回收后才完成确认的任务会被跳过
+func (r *ReliableRedisQueue) nextReclaimed(ctx context.Context) (laneMessage, bool) {
+	for {
+		var lm laneMessage
+		select {
+		case lm = <-r.reclaimed:
+		default:
+			return laneMessage{}, false
+		}
+
+		r.mu.Lock()
+		delete(r.pending, lm.msg.ID)
+		r.mu.Unlock()
+
+		entries, err := r.client.XRange(ctx, r.lanes[lm.lane].stream, lm.msg.ID, lm.msg.ID).Result()
+		if err == nil && len(entries) == 0 {
+			continue
+		}
+		return lm, true
+	}
+}
+
+// Close 停止回收协程并关闭连接
+func (r *ReliableRedisQueue) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return r.client.Close()
+}
+
+// Size 返回各 Stream 中未确认的任务数与延迟任务数之和
+func (r *ReliableRedisQueue) Size() (int, error) {
+	ctx := context.Background()
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, laneCount*2)
+	for _, l := range r.lanes {
+		cmds = append(cmds, pipe.XLen(ctx, l.stream), pipe.ZCard(ctx, l.delayed))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, cmd := range cmds {
+		total += int(cmd.Val())
+	}
+	return total, nil
+}
+
+// delivery 将 Stream 消息转换为 Delivery，无法解析的消息直接确认丢弃
+func (r *ReliableRedisQueue) delivery(ctx context.Context, lane int, msg redis.XMessage) (jobqueue.Delivery, error) {
+	d := &redisDelivery{queue: r, lane: r.lanes[lane], id: msg.ID}
+	payload, _ := msg.Values["job"].(string)
+	d.payload = payload
+	if err := json.Unmarshal([]byte(payload), &d.job); err != nil {
+		r.logger.Error("Dropping malformed job", "id", msg.ID, "error", err)
+		if ackErr := d.Ack(ctx); ackErr != nil {
+			r.logger.Error("Failed to drop malformed job", "id", msg.ID, "error", ackErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResult, err)
+	}
+	return d, nil
+}
+
+// reap 周期性回收超时未确认的任务并移回到期的延迟任务
+func (r *ReliableRedisQueue) reap(ctx context.Context, interval time.Duration) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.promoteDelayed(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("Failed to promote delayed jobs", "error", err)
+		}
+		if err := r.reclaimStale(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("Failed to reclaim stale jobs", "error", err)
+		}
+	}
+}
+
+// promoteDelayed 将到期的延迟任务移回 Stream
+func (r *ReliableRedisQueue) promoteDelayed(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for _, l := range r.lanes {
+		if err := promoteScript.Run(ctx, r.client, []string{l.stream, l.delayed}, now, 100).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reclaimStale 认领各 Stream 中空闲超过可见性超时的待确认任务，交给本消费者重新处理
+func (r *ReliableRedisQueue) reclaimStale(ctx context.Context) error {
+	for lane := range r.lanes {
+		if err := r.reclaimLane(ctx, lane); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reclaimLane 认领一个 Stream 中的超时任务
+func (r *ReliableRedisQueue) reclaimLane(ctx context.Context, lane int) error {
+	start := "0-0"
+	for {
+		msgs, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   r.lanes[lane].stream,
+			Group:    r.group,
+			Consumer: r.consumer,
+			MinIdle:  r.visibilityTimeout,
+			Start:    start,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if r.hold(laneMessage{lane: lane, msg: msg}) {
+				r.logger.Warn("Reclaimed job after visibility timeout", "id", msg.ID)
+			}
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// redisDelivery 可靠 Redis 队列取出的任务
+type redisDelivery struct {
+	queue   *ReliableRedisQueue
+	lane    streamLane
+	id      string
+	payload string
+	job     jobqueue.EmailJob
+}
+
+// Job 返回任务内容
+func (d *redisDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 确认任务并从 Stream 中删除
+// 任务已超时并被其他消费者认领时返回 ErrLeaseLost，任务会被再次发送
+func (d *redisDelivery) Ack(ctx context.Context) error {
+	return d.run(ctx, ackScript, []string{d.lane.stream})
+}
+
+// Nack 确认本次投递并重新入队，requeueAfter 大于 0 时放入延迟集合
+// 任务已被其他消费者认领时返回 ErrLeaseLost，不会重复入队
+func (d *redisDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	var score int64
+	if requeueAfter > 0 {
+		score = time.Now().Add(requeueAfter).UnixMilli()
+	}
+	return d.run(ctx, nackScript, []string{d.lane.stream, d.lane.delayed}, d.payload, score)
+}
+
+// Extend 重置任务的空闲时间，推迟其被回收；任务已被其他消费者认领时返回 ErrLeaseLost
+func (d *redisDelivery) Extend(ctx context.Context) error {
+	return d.run(ctx, extendScript, []string{d.lane.stream})
+}
+
+// run 以消费者组、消息ID和本消费者为前三个参数执行脚本，脚本返回 0 表示消息已不属于本消费者
+func (d *redisDelivery) run(ctx context.Context, script *redis.Script, keys []string, args ...any) error {
+	r := d.queue
+	args = append([]any{r.group, d.id, r.consumer}, args...)
+	n, err := script.Run(ctx, r.client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/status"
+	"email-service/pkg/jobqueue"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// 任务表 status 列的取值
+const (
+	sqlStatusReady  = "ready"  // 等待投递
+	sqlStatusLeased = "leased" // 已被工人认领
+	sqlStatusDone   = "done"   // 已确认
+	sqlStatusDead   = "dead"   // 任务内容无法解析
+)
+
+// defaultSQLiteDSN 嵌入式 SQLite 的默认数据文件
+const defaultSQLiteDSN = "file:data/email.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// SQLQueue 基于 SQL 数据库的队列，支持 PostgreSQL、MySQL 8+ 和嵌入式 SQLite
+// 工人通过 SELECT ... FOR UPDATE SKIP LOCKED 认领任务并写入租约，租约过期未确认的任务会被重新认领。
+// 同一张表同时实现 status.Store，任务的投递状态与队列数据保存在同一行。
+// priority 列保存任务所在的优先级通道，认领时按权重依次尝试各通道
+type SQLQueue struct {
+	db                *sql.DB
+	dialect           *sqlDialect
+	selector          laneSelector
+	visibilityTimeout time.Duration
+	pollInterval      time.Duration
+	retention         time.Duration
+	notify            chan struct{}
+	logger            *logger.Logger
+
+	claimQuery string
+	leaseQuery string
+	pushQuery  string
+	saveQuery  string
+}
+
+// NewSQLQueue 连接数据库并执行迁移
+func NewSQLQueue(config *SQLConfig) (*SQLQueue, error) {
+	dialect, ok := sqlDialects[config.Driver]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported driver %q", ErrSQLConfig, config.Driver)
+	}
+	dsn := config.DSN
+	if dsn == "" {
+		if config.Driver != SQLDriverSQLite {
+			return nil, fmt.Errorf("%w: dsn is required", ErrSQLConfig)
+		}
+		dsn = defaultSQLiteDSN
+		if err := os.MkdirAll(filepath.Dir("data/email.db"), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	visibilityTimeout := config.VisibilityTimeout
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 5 * time.Minute
+	}
+	pollInterval := config.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if config.Driver == SQLDriverSQLite {
+		// SQLite 只有一个写入者，单连接避免 database is locked 错误
+		db.SetMaxOpenConns(1)
+	} else if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(ctx, db, dialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	q := &SQLQueue{
+		db:                db,
+		dialect:           dialect,
+		visibilityTimeout: visibilityTimeout,
+		pollInterval:      pollInterval,
+		retention:         status.DefaultConfig().Retention,
+		notify:            make(chan struct{}, 1),
+		logger:            logger.GetDefault().WithComponent("sql-queue"),
+	}
+	q.claimQuery = dialect.rebind(`SELECT job_id, payload FROM email_jobs
+WHERE priority = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND lease_until <= ?))
+ORDER BY run_at LIMIT 1` + dialect.lockClause)
+	q.leaseQuery = dialect.rebind(`UPDATE email_jobs
+SET status = ?, lease_token = ?, lease_until = ?, attempts = attempts + 1
+WHERE job_id = ?`)
+	q.pushQuery = dialect.rebind(`INSERT INTO email_jobs
+(job_id, payload, status, priority, run_at, lease_token, lease_until, attempts, created_at)
+VALUES (?, ?, ?, ?, ?, '', 0, 0, ?)` + dialect.pushUpsert())
+	q.saveQuery = dialect.rebind(`INSERT INTO email_jobs
+(job_id, state, recipient, subject, message_id, retry_count, last_error, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)` +
+		dialect.upsert([]string{"state", "recipient", "subject", "message_id", "retry_count", "last_error", "created_at", "updated_at"}))
+	return q, nil
+}
+
+// SetStatusRetention 设置状态记录的保留时长，需在 RunPrune 之前调用
+func (q *SQLQueue) SetStatusRetention(retention time.Duration) {
+	q.retention = retention
+}
+
+// Push 将任务写入表中，同一任务ID的记录会被覆盖为待投递
+// 记录仍被工人持有时保留其租约，持有者确认后任务在 run_at 之后再次投递
+func (q *SQLQueue) Push(ctx context.Context, job jobqueue.EmailJob) error {
+	if job.ID == "" {
+		job.ID = jobqueue.NewJobID()
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	runAt := time.Now()
+	if job.NextRetryAt.After(runAt) {
+		runAt = job.NextRetryAt
+	}
+	_, err = q.db.ExecContext(ctx, q.pushQuery,
+		job.ID, string(data), sqlStatusReady, laneOf(job.Priority), runAt.UnixMilli(), job.CreatedAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+	q.wake()
+	return nil
+}
+
+// Pop 认领一个到期任务，没有任务时按轮询间隔等待
+func (q *SQLQueue) Pop(ctx context.Context) (jobqueue.Delivery, error) {
+	for {
+		delivery, err := q.claim(ctx)
+		if err != nil || delivery != nil {
+			return delivery, err
+		}
+
+		timer := time.NewTimer(q.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-q.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// claim 按权重依次从各通道认领一个到期任务，没有任务时返回 nil
+func (q *SQLQueue) claim(ctx context.Context) (*sqlDelivery, error) {
+	for _, lane := range q.selector.order() {
+		delivery, err := q.claimLane(ctx, lane)
+		if err != nil || delivery != nil {
+			return delivery, err
+		}
+	}
+	return nil, nil
+}
+
+// claimLane 在事务中锁定一个通道中的到期任务并写入租约，没有任务时返回 nil
+func (q *SQLQueue) claimLane(ctx context.Context, lane int) (*sqlDelivery, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	var (
+		jobID   string
+		payload sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, q.claimQuery, lane, sqlStatusReady, now, sqlStatusLeased, now).Scan(&jobID, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job jobqueue.EmailJob
+	if err := json.Unmarshal([]byte(payload.String), &job); err != nil {
+		q.logger.Error("Dropping undecodable job", "job_id", jobID, "error", err)
+		dead := q.dialect.rebind(`UPDATE email_jobs SET status = ?, payload = NULL WHERE job_id = ?`)
+		if _, err := tx.ExecContext(ctx, dead, sqlStatusDead, jobID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	}
+
+	token := jobqueue.NewJobID()
+	leaseUntil := time.Now().Add(q.visibilityTimeout).UnixMilli()
+	if _, err := tx.ExecContext(ctx, q.leaseQuery, sqlStatusLeased, token, leaseUntil, jobID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &sqlDelivery{queue: q, jobID: jobID, token: token, job: job}, nil
+}
+
+// Close 关闭数据库连接
+func (q *SQLQueue) Close() error {
+	return q.db.Close()
+}
+
+// Size 返回待投递和处理中的任务数量
+func (q *SQLQueue) Size() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var n int
+	query := q.dialect.rebind(`SELECT COUNT(*) FROM email_jobs WHERE status IN (?, ?)`)
+	err := q.db.QueryRowContext(ctx, query, sqlStatusReady, sqlStatusLeased).Scan(&n)
+	return n, err
+}
+
+// Save 保存任务状态，只更新状态相关的列
+func (q *SQLQueue) Save(ctx context.Context, st *status.JobStatus) error {
+	updatedAt := st.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+	_, err := q.db.ExecContext(ctx, q.saveQuery,
+		st.JobID, string(st.State), st.Recipient, st.Subject, st.MessageID, st.RetryCount, st.LastError,
+		st.CreatedAt.UnixMilli(), updatedAt.UnixMilli())
+	return err
+}
+
+// Get 按任务ID查询状态
+func (q *SQLQueue) Get(ctx context.Context, jobID string) (*status.JobStatus, error) {
+	return q.getStatus(ctx, "job_id", jobID)
+}
+
+// GetByMessageID 按 Message-ID 查询状态
+func (q *SQLQueue) GetByMessageID(ctx context.Context, messageID string) (*status.JobStatus, error) {
+	return q.getStatus(ctx, "message_id", messageID)
+}
+
+func (q *SQLQueue) getStatus(ctx context.Context, column, value string) (*status.JobStatus, error) {
+	query := q.dialect.rebind(`SELECT job_id, state, recipient, subject, message_id, retry_count, last_error, created_at, updated_at
+FROM email_jobs WHERE ` + column + ` = ? AND state <> '' LIMIT 1`)
+
+	var (
+		st                   status.JobStatus
+		state                string
+		subject, lastError   sql.NullString
+		createdAt, updatedAt int64
+	)
+	err := q.db.QueryRowContext(ctx, query, value).Scan(
+		&st.JobID, &state, &st.Recipient, &subject, &st.MessageID, &st.RetryCount, &lastError, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.State = status.State(state)
+	st.Subject = subject.String
+	st.LastError = lastError.String
+	st.CreatedAt = time.UnixMilli(createdAt)
+	st.UpdatedAt = time.UnixMilli(updatedAt)
+	return &st, nil
+}
+
+// Prune 删除超过保留时长且不在队列中的记录，返回删除数量
+func (q *SQLQueue) Prune(ctx context.Context) (int64, error) {
+	if q.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-q.retention).UnixMilli()
+	query := q.dialect.rebind(`DELETE FROM email_jobs WHERE status NOT IN (?, ?) AND updated_at < ?`)
+	res, err := q.db.ExecContext(ctx, query, sqlStatusReady, sqlStatusLeased, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RunPrune 周期性清理过期记录，直到 ctx 结束
+func (q *SQLQueue) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := q.Prune(ctx); err != nil {
+				q.logger.Error("Failed to prune job records", "error", err)
+			}
+		}
+	}
+}
+
+// wake 通知本进程中等待的 Pop 立即检查新任务
+func (q *SQLQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// sqlDelivery SQL 队列认领的任务，所有操作都以租约令牌为条件，租约被其他工人接管后不再生效
+type sqlDelivery struct {
+	queue *SQLQueue
+	jobID string
+	token string
+	job   jobqueue.EmailJob
+}
+
+// Job 返回任务内容
+func (d *sqlDelivery) Job() jobqueue.EmailJob {
+	return d.job
+}
+
+// Ack 将任务标记为已确认并清除任务内容，状态列保留到过期清理
+// 处理期间同一任务ID被重新写入（如重试任务）时只释放租约；
+// 租约已过期并被其他工人认领时返回 ErrLeaseLost，任务会被再次发送
+func (d *sqlDelivery) Ack(ctx context.Context) error {
+	q := d.queue
+	query := q.dialect.rebind(`UPDATE email_jobs SET status = ?, payload = NULL, lease_token = '', updated_at = ?
+WHERE job_id = ? AND lease_token = ? AND status = ?`)
+	res, err := q.db.ExecContext(ctx, query, sqlStatusDone, time.Now().UnixMilli(), d.jobID, d.token, sqlStatusLeased)
+	if err := leaseResult(res, err); !errors.Is(err, ErrLeaseLost) {
+		return err
+	}
+	return d.release(ctx)
+}
+
+// Nack 释放租约，任务在 requeueAfter 之后才能再次认领；处理期间被重新写入的任务保留其 run_at
+func (d *sqlDelivery) Nack(ctx context.Context, requeueAfter time.Duration) error {
+	q := d.queue
+	query := q.dialect.rebind(`UPDATE email_jobs SET status = ?, run_at = ?, lease_token = '', lease_until = 0
+WHERE job_id = ? AND lease_token = ? AND status = ?`)
+	res, err := q.db.ExecContext(ctx, query, sqlStatusReady, time.Now().Add(requeueAfter).UnixMilli(), d.jobID, d.token, sqlStatusLeased)
+	err = leaseResult(res, err)
+	if errors.Is(err, ErrLeaseLost) {
+		err = d.release(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	q.wake()
+	return nil
+}
+
+// release 释放处理期间被重新写入的任务的租约
+func (d *sqlDelivery) release(ctx context.Context) error {
+	q := d.queue
+	query := q.dialect.rebind(`UPDATE email_jobs SET lease_token = '', lease_until = 0 WHERE job_id = ? AND lease_token = ?`)
+	res, err := q.db.ExecContext(ctx, query, d.jobID, d.token)
+	return leaseResult(res, err)
+}
+
+// Extend 延长租约
+func (d *sqlDelivery) Extend(ctx context.Context) error {
+	q := d.queue
+	query := q.dialect.rebind(`UPDATE email_jobs SET lease_until = ? WHERE job_id = ? AND lease_token = ?`)
+	res, err := q.db.ExecContext(ctx, query, time.Now().Add(q.visibilityTimeout).UnixMilli(), d.jobID, d.token)
+	return leaseResult(res, err)
+}
+
+// leaseResult 以租约令牌为条件的更新没有命中任何行时返回 ErrLeaseLost
+func leaseResult(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect 不同数据库的语法差异
+type sqlDialect struct {
+	driver     string     // database/sql 驱动名
+	lockClause string     // 认领任务时附加的行锁子句
+	migrations [][]string // 按版本排列的建表语句
+	// onConflict 主键冲突时更新子句的开头
+	onConflict string
+	// inserted 返回冲突更新子句中引用待插入值的表达式
+	inserted func(column string) string
+	// rebind 将 ? 占位符转换为驱动使用的格式
+	rebind func(query string) string
+	// lockMigrations 在连接上获取会话级的迁移锁，避免多个实例同时执行迁移；为空时不加锁
+	lockMigrations func(ctx context.Context, conn *sql.Conn) (unlock func(), err error)
+}
+
+// migrationLockName 迁移锁的名称
+const migrationLockName = "email_jobs_migrations"
+
+// postgresMigrationLock 使用 PostgreSQL 会话级 advisory lock，等待其他实例完成迁移
+func postgresMigrationLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, migrationLockName); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, migrationLockName)
+	}, nil
+}
+
+// mysqlMigrationLock 使用 MySQL 命名锁；MySQL 的 DDL 会隐式提交，不能依赖事务回滚避免重复迁移
+func mysqlMigrationLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 60)`, migrationLockName).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		return nil, errors.New("timed out waiting for migration lock")
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLockName)
+	}, nil
+}
+
+// 数据库类型
+const (
+	SQLDriverPostgres = "postgres"
+	SQLDriverMySQL    = "mysql"
+	SQLDriverSQLite   = "sqlite"
+)
+
+// emailJobsTable 任务表的列，PostgreSQL 与 SQLite 通用
+const emailJobsTable = `CREATE TABLE IF NOT EXISTS email_jobs (
+	job_id      VARCHAR(64) PRIMARY KEY,
+	payload     TEXT,
+	status      VARCHAR(16) NOT NULL DEFAULT '',
+	run_at      BIGINT NOT NULL DEFAULT 0,
+	lease_token VARCHAR(64) NOT NULL DEFAULT '',
+	lease_until BIGINT NOT NULL DEFAULT 0,
+	attempts    INTEGER NOT NULL DEFAULT 0,
+	state       VARCHAR(16) NOT NULL DEFAULT '',
+	recipient   VARCHAR(320) NOT NULL DEFAULT '',
+	subject     TEXT,
+	message_id  VARCHAR(255) NOT NULL DEFAULT '',
+	retry_count INTEGER NOT NULL DEFAULT 0,
+	last_error  TEXT,
+	created_at  BIGINT NOT NULL DEFAULT 0,
+	updated_at  BIGINT NOT NULL DEFAULT 0
+)`
+
+var portableMigrations = [][]string{
+	{
+		emailJobsTable,
+		`CREATE INDEX IF NOT EXISTS idx_email_jobs_ready ON email_jobs (status, run_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_jobs_lease ON email_jobs (status, lease_until)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_jobs_message_id ON email_jobs (message_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_jobs_updated_at ON email_jobs (updated_at)`,
+	},
+	// 2: 优先级通道，旧任务归入 normal
+	{
+		`ALTER TABLE email_jobs ADD COLUMN priority SMALLINT NOT NULL DEFAULT 1`,
+		`CREATE INDEX IF NOT EXISTS idx_email_jobs_priority ON email_jobs (status, priority, run_at)`,
+	},
+}
+
+var mysqlMigrations = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS email_jobs (
+	job_id      VARCHAR(64) NOT NULL PRIMARY KEY,
+	payload     LONGTEXT,
+	status      VARCHAR(16) NOT NULL DEFAULT '',
+	run_at      BIGINT NOT NULL DEFAULT 0,
+	lease_token VARCHAR(64) NOT NULL DEFAULT '',
+	lease_until BIGINT NOT NULL DEFAULT 0,
+	attempts    INT NOT NULL DEFAULT 0,
+	state       VARCHAR(16) NOT NULL DEFAULT '',
+	recipient   VARCHAR(320) NOT NULL DEFAULT '',
+	subject     TEXT,
+	message_id  VARCHAR(255) NOT NULL DEFAULT '',
+	retry_count INT NOT NULL DEFAULT 0,
+	last_error  TEXT,
+	created_at  BIGINT NOT NULL DEFAULT 0,
+	updated_at  BIGINT NOT NULL DEFAULT 0,
+	INDEX idx_email_jobs_ready (status, run_at),
+	INDEX idx_email_jobs_lease (status, lease_until),
+	INDEX idx_email_jobs_message_id (message_id),
+	INDEX idx_email_jobs_updated_at (updated_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+	},
+	{
+		`ALTER TABLE email_jobs
+	ADD COLUMN priority SMALLINT NOT NULL DEFAULT 1,
+	ADD INDEX idx_email_jobs_priority (status, priority, run_at)`,
+	},
+}
+
+// upsert 返回主键冲突时以待插入值更新指定列的子句
+func (d *sqlDialect) upsert(columns []string) string {
+	sets := make([]string, len(columns))
+	for i, c := range columns {
+		sets[i] = c + " = " + d.inserted(c)
+	}
+	return d.onConflict + strings.Join(sets, ", ")
+}
+
+// pushUpsert 返回重新写入任务时的冲突更新子句
+// 记录仍被工人持有时（重试任务在原任务确认前写入）保留租约和投递次数，持有者确认时只释放租约。
+// MySQL 按顺序求值赋值，保留的列需写在 status 之前
+func (d *sqlDialect) pushUpsert() string {
+	var sets []string
+	for _, c := range []string{"lease_token", "lease_until", "attempts"} {
+		sets = append(sets, c+" = CASE WHEN email_jobs.status = '"+sqlStatusLeased+"' THEN email_jobs."+c+" ELSE "+d.inserted(c)+" END")
+	}
+	for _, c := range []string{"payload", "status", "priority", "run_at"} {
+		sets = append(sets, c+" = "+d.inserted(c))
+	}
+	return d.onConflict + strings.Join(sets, ", ")
+}
+
+// excluded PostgreSQL 与 SQLite 的 ON CONFLICT 语法中引用待插入值
+func excluded(column string) string {
+	return "excluded." + column
+}
+
+// noRebind 驱动直接使用 ? 占位符
+func noRebind(query string) string {
+	return query
+}
+
+var sqlDialects = map[string]*sqlDialect{
+	SQLDriverPostgres: {
+		driver:         "pgx",
+		lockClause:     " FOR UPDATE SKIP LOCKED",
+		migrations:     portableMigrations,
+		onConflict:     " ON CONFLICT (job_id) DO UPDATE SET ",
+		inserted:       excluded,
+		lockMigrations: postgresMigrationLock,
+		rebind: func(query string) string {
+			var b strings.Builder
+			n := 0
+			for _, r := range query {
+				if r == '?' {
+					n++
+					b.WriteString("$" + strconv.Itoa(n))
+					continue
+				}
+				b.WriteRune(r)
+			}
+			return b.String()
+		},
+	},
+	SQLDriverMySQL: {
+		driver:     "mysql",
+		lockClause: " FOR UPDATE SKIP LOCKED",
+		migrations: mysqlMigrations,
+		onConflict: " ON DUPLICATE KEY UPDATE ",
+		inserted: func(column string) string {
+			return "VALUES(" + column + ")"
+		},
+		rebind:         noRebind,
+		lockMigrations: mysqlMigrationLock,
+	},
+	// SQLite 只允许一个写连接，认领任务的事务和迁移天然串行，不需要行锁
+	SQLDriverSQLite: {
+		driver:     "sqlite",
+		migrations: portableMigrations,
+		onConflict: " ON CONFLICT (job_id) DO UPDATE SET ",
+		inserted:   excluded,
+		rebind:     noRebind,
+	},
+}
+
+// migrate 依次执行尚未应用的迁移，已应用的版本记录在 schema_migrations 表中
+// 多个实例同时启动时，迁移锁保证只有一个实例执行迁移，其余实例等待后跳过已应用的版本
+func migrate(ctx context.Context, db *sql.DB, dialect *sqlDialect) error {
+	// 会话级锁只对获取它的连接有效，整个迁移使用同一个连接
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if dialect.lockMigrations != nil {
+		unlock, err := dialect.lockMigrations(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer unlock()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER NOT NULL PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, statements := range dialect.migrations {
+		version := i + 1
+		if applied[version] {
+			continue
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d failed: %w", version, err)
+			}
+		}
+		insert := dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`)
+		if _, err := tx.ExecContext(ctx, insert, version, time.Now().UnixMilli()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
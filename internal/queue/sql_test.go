@@ -0,0 +1,454 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/internal/status"
+	"email-service/pkg/jobqueue"
+)
+
+// newTestSQLQueue 在临时目录中创建 SQLite 队列，测试结束时关闭
+func newTestSQLQueue(t *testing.T, visibilityTimeout time.Duration) *SQLQueue {
+	t.Helper()
+	return openTestSQLQueue(t, sqliteTestDSN(t), visibilityTimeout)
+}
+
+// sqliteTestDSN 返回临时目录中的 SQLite 数据文件
+func sqliteTestDSN(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "email.db")
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+func openTestSQLQueue(t *testing.T, dsn string, visibilityTimeout time.Duration) *SQLQueue {
+	t.Helper()
+	q, err := NewSQLQueue(&SQLConfig{
+		Driver:            SQLDriverSQLite,
+		DSN:               dsn,
+		VisibilityTimeout: visibilityTimeout,
+		PollInterval:      50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.Close() })
+	return q
+}
+
+// popTimeout 在 timeout 内取出一个任务，没有任务时返回 nil
+func popTimeout(t *testing.T, q jobqueue.JobQueue, timeout time.Duration) jobqueue.Delivery {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	d, err := q.Pop(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("Pop: %v", err)
+	}
+	return d
+}
+
+// jobColumns 直接读取任务行的队列列
+func jobColumns(t *testing.T, q *SQLQueue, jobID string) (state string, runAt int64, attempts int) {
+	t.Helper()
+	err := q.db.QueryRow(`SELECT status, run_at, attempts FROM email_jobs WHERE job_id = ?`, jobID).Scan(&state, &runAt, &attempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state, runAt, attempts
+}
+
+func TestSQLQueueClaimsEachJobOnce(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	const total = 40
+	for i := 0; i < total; i++ {
+		pushJob(t, q, fmt.Sprintf("job-%02d", i), "")
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]int)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+				d, err := q.Pop(ctx)
+				cancel()
+				if errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				seen[d.Job().ID]++
+				mu.Unlock()
+				// 认领后不确认：其他工人不会再认领租约中的任务
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != total {
+		t.Errorf("claimed %d distinct jobs, want %d", len(seen), total)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %s claimed %d times", id, n)
+		}
+	}
+	if size, err := q.Size(); err != nil || size != total {
+		t.Errorf("Size = %d, %v; want %d", size, err, total)
+	}
+}
+
+func TestSQLQueueReclaimsExpiredLease(t *testing.T) {
+	q := newTestSQLQueue(t, 300*time.Millisecond)
+	pushJob(t, q, "lease", "")
+
+	stale := popTimeout(t, q, time.Second)
+	if stale == nil {
+		t.Fatal("no job")
+	}
+	if d := popTimeout(t, q, 100*time.Millisecond); d != nil {
+		t.Fatal("leased job claimed again before lease expired")
+	}
+
+	// 租约过期后被重新认领，旧的租约令牌失效
+	fresh := popTimeout(t, q, 2*time.Second)
+	if fresh == nil || fresh.Job().ID != "lease" {
+		t.Fatalf("expired job not reclaimed: %v", fresh)
+	}
+	if _, _, attempts := jobColumns(t, q, "lease"); attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+	if err := stale.Extend(context.Background()); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Extend with stale lease = %v, want ErrLeaseLost", err)
+	}
+	if err := stale.Ack(context.Background()); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Ack with stale lease = %v, want ErrLeaseLost", err)
+	}
+	if err := stale.Nack(context.Background(), 0); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Nack with stale lease = %v, want ErrLeaseLost", err)
+	}
+	if state, _, _ := jobColumns(t, q, "lease"); state != sqlStatusLeased {
+		t.Errorf("stale delivery changed job status to %s", state)
+	}
+
+	if err := fresh.Extend(context.Background()); err != nil {
+		t.Errorf("Extend: %v", err)
+	}
+	if err := fresh.Ack(context.Background()); err != nil {
+		t.Errorf("Ack: %v", err)
+	}
+	if state, _, _ := jobColumns(t, q, "lease"); state != sqlStatusDone {
+		t.Errorf("status after ack = %s, want done", state)
+	}
+	if size, err := q.Size(); err != nil || size != 0 {
+		t.Errorf("Size = %d, %v; want 0", size, err)
+	}
+}
+
+func TestSQLQueueExtendKeepsLease(t *testing.T) {
+	q := newTestSQLQueue(t, 300*time.Millisecond)
+	pushJob(t, q, "slow", "")
+
+	d := popTimeout(t, q, time.Second)
+	for i := 0; i < 4; i++ {
+		time.Sleep(150 * time.Millisecond)
+		if err := d.Extend(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if other := popTimeout(t, q, 100*time.Millisecond); other != nil {
+		t.Fatal("extended job was reclaimed")
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLQueueNackSetsRunAt(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	pushJob(t, q, "retry", "")
+
+	d := popTimeout(t, q, time.Second)
+	before := time.Now()
+	if err := d.Nack(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	state, runAt, _ := jobColumns(t, q, "retry")
+	if state != sqlStatusReady {
+		t.Errorf("status after nack = %s, want ready", state)
+	}
+	if runAt < before.Add(time.Second).UnixMilli() {
+		t.Errorf("run_at = %d, want at least %d", runAt, before.Add(time.Second).UnixMilli())
+	}
+
+	if d := popTimeout(t, q, 500*time.Millisecond); d != nil {
+		t.Fatal("job claimed before run_at")
+	}
+	d = popTimeout(t, q, 2*time.Second)
+	if d == nil || d.Job().ID != "retry" {
+		t.Fatal("job not claimed after run_at")
+	}
+	if time.Since(before) < time.Second {
+		t.Errorf("job claimed after %v, want at least 1s", time.Since(before))
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLQueuePushWhileLeased(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	pushJob(t, q, "retried", "")
+	d := popTimeout(t, q, time.Second)
+
+	// 调度器在原任务确认前写入同一任务ID的重试任务
+	retry := d.Job()
+	retry.RetryCount = 1
+	retry.NextRetryAt = time.Now().Add(300 * time.Millisecond)
+	if err := q.Push(context.Background(), retry); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Extend(context.Background()); err != nil {
+		t.Errorf("Extend after re-push = %v", err)
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatalf("Ack after re-push = %v", err)
+	}
+	state, _, attempts := jobColumns(t, q, "retried")
+	if state != sqlStatusReady || attempts != 1 {
+		t.Errorf("after ack: status = %s, attempts = %d; want ready, 1", state, attempts)
+	}
+
+	d = popTimeout(t, q, 2*time.Second)
+	if d == nil || d.Job().RetryCount != 1 {
+		t.Fatal("re-pushed job not delivered")
+	}
+	if err := d.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state, _, attempts := jobColumns(t, q, "retried"); state != sqlStatusDone || attempts != 2 {
+		t.Errorf("after retry: status = %s, attempts = %d; want done, 2", state, attempts)
+	}
+}
+
+func TestSQLQueuePushDelaysUntilNextRetry(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	job := jobqueue.EmailJob{ID: "later", To: "later@example.com", NextRetryAt: time.Now().Add(time.Hour)}
+	if err := q.Push(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if d := popTimeout(t, q, 200*time.Millisecond); d != nil {
+		t.Fatal("job claimed before NextRetryAt")
+	}
+	if size, err := q.Size(); err != nil || size != 1 {
+		t.Errorf("Size = %d, %v; want 1", size, err)
+	}
+}
+
+func TestSQLQueueLanePriority(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	pushJob(t, q, "bulk", jobqueue.PriorityBulk)
+	pushJob(t, q, "normal", "")
+	pushJob(t, q, "high", jobqueue.PriorityHigh)
+
+	d := popTimeout(t, q, time.Second)
+	if d == nil || d.Job().ID != "high" {
+		t.Fatalf("first job = %v, want high", d)
+	}
+}
+
+func TestSQLQueueStatusStore(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	ctx := context.Background()
+
+	if _, err := q.Get(ctx, "missing"); !errors.Is(err, status.ErrNotFound) {
+		t.Errorf("Get missing = %v, want ErrNotFound", err)
+	}
+
+	// 任务入队后尚无状态记录
+	pushJob(t, q, "job-1", "")
+	if _, err := q.Get(ctx, "job-1"); !errors.Is(err, status.ErrNotFound) {
+		t.Errorf("Get before Save = %v, want ErrNotFound", err)
+	}
+
+	created := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	st := &status.JobStatus{
+		JobID:      "job-1",
+		Recipient:  "job-1@example.com",
+		Subject:    "hello",
+		State:      status.StateSent,
+		MessageID:  "<job-1@example.com>",
+		RetryCount: 2,
+		LastError:  "earlier failure",
+		CreatedAt:  created,
+	}
+	if err := q.Save(ctx, st); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := q.Get(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != status.StateSent || got.Recipient != st.Recipient || got.Subject != st.Subject ||
+		got.MessageID != st.MessageID || got.RetryCount != 2 || got.LastError != st.LastError ||
+		!got.CreatedAt.Equal(created) || got.UpdatedAt.IsZero() {
+		t.Errorf("Get = %+v", got)
+	}
+	byMessage, err := q.GetByMessageID(ctx, "<job-1@example.com>")
+	if err != nil || byMessage.JobID != "job-1" {
+		t.Errorf("GetByMessageID = %+v, %v", byMessage, err)
+	}
+	if _, err := q.GetByMessageID(ctx, "<missing@example.com>"); !errors.Is(err, status.ErrNotFound) {
+		t.Errorf("GetByMessageID missing = %v, want ErrNotFound", err)
+	}
+
+	// 保存状态不影响队列列，任务仍可认领
+	d := popTimeout(t, q, time.Second)
+	if d == nil || d.Job().ID != "job-1" {
+		t.Fatal("job not claimable after status save")
+	}
+	if err := d.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 确认后状态列保留
+	st.State = status.StateBounced
+	if err := q.Save(ctx, st); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := q.Get(ctx, "job-1"); err != nil || got.State != status.StateBounced {
+		t.Errorf("Get after ack = %+v, %v", got, err)
+	}
+
+	// 只有状态没有任务内容的记录不会被认领
+	if err := q.Save(ctx, &status.JobStatus{JobID: "status-only", State: status.StateQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if d := popTimeout(t, q, 200*time.Millisecond); d != nil {
+		t.Errorf("claimed status-only record %s", d.Job().ID)
+	}
+}
+
+func TestSQLQueuePrune(t *testing.T) {
+	q := newTestSQLQueue(t, time.Minute)
+	q.SetStatusRetention(time.Hour)
+	ctx := context.Background()
+	old := time.Now().Add(-2 * time.Hour)
+
+	// 已确认且过期的记录被删除
+	pushJob(t, q, "done-old", "")
+	d := popTimeout(t, q, time.Second)
+	if err := d.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Save(ctx, &status.JobStatus{JobID: "done-old", State: status.StateSent, UpdatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未过期的记录和仍在队列中的任务保留
+	if err := q.Save(ctx, &status.JobStatus{JobID: "recent", State: status.StateSent}); err != nil {
+		t.Fatal(err)
+	}
+	pushJob(t, q, "pending-old", "")
+	if err := q.Save(ctx, &status.JobStatus{JobID: "pending-old", State: status.StateQueued, UpdatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		q.RunPrune(runCtx, 50*time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := q.Get(ctx, "done-old"); errors.Is(err, status.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired record not pruned")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if _, err := q.Get(ctx, "recent"); err != nil {
+		t.Errorf("recent record pruned: %v", err)
+	}
+	if _, err := q.Get(ctx, "pending-old"); err != nil {
+		t.Errorf("pending job pruned: %v", err)
+	}
+	if n, err := q.Prune(ctx); err != nil || n != 0 {
+		t.Errorf("second Prune = %d, %v; want 0", n, err)
+	}
+}
+
+func TestSQLQueueMigrationIsIdempotent(t *testing.T) {
+	dsn := sqliteTestDSN(t)
+	first := openTestSQLQueue(t, dsn, time.Minute)
+	pushJob(t, first, "kept", "")
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := openTestSQLQueue(t, dsn, time.Minute)
+	var versions int
+	if err := second.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions != len(sqlDialects[SQLDriverSQLite].migrations) {
+		t.Errorf("schema_migrations has %d versions, want %d", versions, len(sqlDialects[SQLDriverSQLite].migrations))
+	}
+	if d := popTimeout(t, second, time.Second); d == nil || d.Job().ID != "kept" {
+		t.Error("job lost after reopening the database")
+	}
+}
+
+func TestSQLDialects(t *testing.T) {
+	pg := sqlDialects[SQLDriverPostgres]
+	if got := pg.rebind(`UPDATE t SET a = ? WHERE b = ? AND c = ?`); got != `UPDATE t SET a = $1 WHERE b = $2 AND c = $3` {
+		t.Errorf("postgres rebind = %s", got)
+	}
+	// PostgreSQL 和 MySQL 多个实例共享任务表，认领时跳过其他事务锁定的行
+	for _, driver := range []string{SQLDriverPostgres, SQLDriverMySQL} {
+		d := sqlDialects[driver]
+		if d.lockClause != " FOR UPDATE SKIP LOCKED" {
+			t.Errorf("%s lock clause = %q", driver, d.lockClause)
+		}
+		if d.lockMigrations == nil {
+			t.Errorf("%s migrations are not locked", driver)
+		}
+	}
+	if got := sqlDialects[SQLDriverMySQL].upsert([]string{"a", "b"}); got != " ON DUPLICATE KEY UPDATE a = VALUES(a), b = VALUES(b)" {
+		t.Errorf("mysql upsert = %s", got)
+	}
+	if got := sqlDialects[SQLDriverSQLite].upsert([]string{"a"}); got != " ON CONFLICT (job_id) DO UPDATE SET a = excluded.a" {
+		t.Errorf("sqlite upsert = %s", got)
+	}
+	// MySQL 按顺序求值，保留租约的判断必须在 status 更新之前
+	push := sqlDialects[SQLDriverMySQL].pushUpsert()
+	if !strings.HasPrefix(push, " ON DUPLICATE KEY UPDATE lease_token = CASE WHEN email_jobs.status = 'leased'") ||
+		strings.Index(push, "status = VALUES(status)") < strings.Index(push, "attempts = CASE") {
+		t.Errorf("mysql push upsert = %s", push)
+	}
+}
@@ -0,0 +1,236 @@
+package quiethours
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound 收件人档案不存在
+var ErrNotFound = errors.New("recipient profile not found")
+
+// Profile 收件人档案
+type Profile struct {
+	Address   string    `json:"address"`
+	Timezone  string    `json:"timezone"` // IANA 时区，如 Asia/Shanghai
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProfileStore 定义收件人档案存储接口
+type ProfileStore interface {
+	// Get 按地址查询档案，地址不区分大小写
+	Get(ctx context.Context, address string) (*Profile, error)
+
+	// Put 保存（新增或覆盖）档案
+	Put(ctx context.Context, profile Profile) error
+
+	// Delete 删除档案
+	Delete(ctx context.Context, address string) error
+
+	// Close 关闭存储
+	Close() error
+}
+
+// ProfileConfig 收件人档案存储配置
+type ProfileConfig struct {
+	Store string       `mapstructure:"store"` // bolt 或 redis
+	Path  string       `mapstructure:"path"`  // bolt 类型的数据文件
+	Redis *RedisConfig `mapstructure:"redis"`
+}
+
+// RedisConfig Redis 档案存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	StoreBolt  = "bolt"
+	StoreRedis = "redis"
+)
+
+// DefaultProfileConfig 返回默认档案存储配置
+func DefaultProfileConfig() *ProfileConfig {
+	return &ProfileConfig{
+		Store: StoreBolt,
+		Path:  "data/profiles.db",
+	}
+}
+
+// NewProfileStore 根据配置创建档案存储
+func NewProfileStore(cfg *ProfileConfig) (ProfileStore, error) {
+	switch cfg.Store {
+	case StoreBolt, "":
+		path := cfg.Path
+		if path == "" {
+			path = DefaultProfileConfig().Path
+		}
+		return NewBoltProfileStore(path)
+	case StoreRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("quiethours: redis config is required")
+		}
+		return NewRedisProfileStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("quiethours: unsupported profile store type %q", cfg.Store)
+	}
+}
+
+// NormalizeAddress 规范化地址，用作存储的键
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+var bucketProfiles = []byte("profiles") // 地址 -> Profile
+
+// BoltProfileStore 基于 bbolt 的档案存储，只在单实例内有效
+type BoltProfileStore struct {
+	db *bolt.DB
+}
+
+// NewBoltProfileStore 打开或创建 bolt 档案存储
+func NewBoltProfileStore(path string) (*BoltProfileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketProfiles)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltProfileStore{db: db}, nil
+}
+
+// Get 按地址查询档案
+func (s *BoltProfileStore) Get(ctx context.Context, address string) (*Profile, error) {
+	var profile *Profile
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketProfiles).Get([]byte(NormalizeAddress(address)))
+		if data == nil {
+			return ErrNotFound
+		}
+		profile = &Profile{}
+		return json.Unmarshal(data, profile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Put 保存档案
+func (s *BoltProfileStore) Put(ctx context.Context, profile Profile) error {
+	profile.Address = NormalizeAddress(profile.Address)
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketProfiles).Put([]byte(profile.Address), data)
+	})
+}
+
+// Delete 删除档案
+func (s *BoltProfileStore) Delete(ctx context.Context, address string) error {
+	key := []byte(NormalizeAddress(address))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketProfiles)
+		if b.Get(key) == nil {
+			return ErrNotFound
+		}
+		return b.Delete(key)
+	})
+}
+
+// Close 关闭数据库
+func (s *BoltProfileStore) Close() error {
+	return s.db.Close()
+}
+
+// RedisProfileStore 基于 Redis 的档案存储，多个实例共享
+type RedisProfileStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisProfileStore 创建 Redis 档案存储
+func NewRedisProfileStore(config *RedisConfig) (*RedisProfileStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:profile:"
+	}
+	return &RedisProfileStore{client: client, prefix: prefix}, nil
+}
+
+// Get 按地址查询档案
+func (s *RedisProfileStore) Get(ctx context.Context, address string) (*Profile, error) {
+	data, err := s.client.Get(ctx, s.prefix+NormalizeAddress(address)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile := &Profile{}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Put 保存档案
+func (s *RedisProfileStore) Put(ctx context.Context, profile Profile) error {
+	profile.Address = NormalizeAddress(profile.Address)
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+profile.Address, data, 0).Err()
+}
+
+// Delete 删除档案
+func (s *RedisProfileStore) Delete(ctx context.Context, address string) error {
+	n, err := s.client.Del(ctx, s.prefix+NormalizeAddress(address)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisProfileStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,217 @@
+// Package quiethours 按收件人所在时区限制非紧急邮件的投递时段，时段之外的任务推迟到下一个允许的时间
+package quiethours
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+)
+
+// Config 免打扰配置
+type Config struct {
+	Enabled         bool           `mapstructure:"enabled"`
+	DefaultTimezone string         `mapstructure:"default_timezone"` // 任务和收件人档案都没有时区时使用，为空时为 UTC
+	Windows         []WindowRule   `mapstructure:"windows"`
+	Bypass          []string       `mapstructure:"bypass"` // 不受投递时段限制的类别，如验证码、安全提醒
+	Profiles        *ProfileConfig `mapstructure:"profiles"`
+}
+
+// WindowRule 某个类别允许投递的时段，按收件人当地时间计算
+type WindowRule struct {
+	Category string   `mapstructure:"category"` // 为空时适用于没有单独配置的类别
+	Start    string   `mapstructure:"start"`    // 如 08:00
+	End      string   `mapstructure:"end"`      // 如 21:00，早于 start 时表示跨越午夜
+	Days     []string `mapstructure:"days"`     // 允许投递的星期，如 mon、tue，为空时为每天；跨午夜的时段按开始的那天计算
+}
+
+// DefaultConfig 返回默认免打扰配置，默认不启用
+func DefaultConfig() *Config {
+	return &Config{
+		Profiles: DefaultProfileConfig(),
+	}
+}
+
+// window 解析后的投递时段
+type window struct {
+	start, end int   // 一天中的分钟数
+	days       uint8 // 按位表示允许的星期，0 为周日
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Service 计算任务需要推迟的时长
+type Service struct {
+	windows    map[string]window
+	bypass     map[string]bool
+	defaultLoc *time.Location
+	profiles   ProfileStore
+	logger     *logger.Logger
+}
+
+// New 根据配置创建免打扰服务
+func New(cfg *Config) (*Service, error) {
+	loc, err := time.LoadLocation(cfg.DefaultTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("quiethours: invalid default timezone %q", cfg.DefaultTimezone)
+	}
+	s := &Service{
+		windows:    make(map[string]window, len(cfg.Windows)),
+		bypass:     make(map[string]bool, len(cfg.Bypass)),
+		defaultLoc: loc,
+		logger:     logger.GetDefault().WithComponent("quiethours"),
+	}
+	for _, rule := range cfg.Windows {
+		w, err := parseWindow(rule)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.windows[rule.Category]; ok {
+			return nil, fmt.Errorf("quiethours: duplicate window for category %q", rule.Category)
+		}
+		s.windows[rule.Category] = w
+	}
+	for _, category := range cfg.Bypass {
+		s.bypass[category] = true
+	}
+
+	profiles := cfg.Profiles
+	if profiles == nil {
+		profiles = DefaultProfileConfig()
+	}
+	if s.profiles, err = NewProfileStore(profiles); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Profiles 返回收件人档案存储
+func (s *Service) Profiles() ProfileStore {
+	return s.profiles
+}
+
+// Delay 返回任务需要推迟的时长，当前处于允许的时段、类别不受限制或没有适用的时段时返回 0
+func (s *Service) Delay(ctx context.Context, job jobqueue.EmailJob, now time.Time) time.Duration {
+	if s.bypass[job.Category] {
+		return 0
+	}
+	w, ok := s.windows[job.Category]
+	if !ok {
+		if w, ok = s.windows[""]; !ok {
+			return 0
+		}
+	}
+	next := w.next(now.In(s.location(ctx, job)))
+	return next.Sub(now)
+}
+
+// location 返回收件人的时区：任务指定的时区优先，其次为收件人档案，最后为默认时区
+func (s *Service) location(ctx context.Context, job jobqueue.EmailJob) *time.Location {
+	tz := job.Timezone
+	if tz == "" && s.profiles != nil {
+		profile, err := s.profiles.Get(ctx, job.To)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			s.logger.Warn("Failed to look up recipient profile", "recipient", job.To, "error", err)
+		}
+		if profile != nil {
+			tz = profile.Timezone
+		}
+	}
+	if tz == "" {
+		return s.defaultLoc
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		s.logger.Warn("Invalid recipient timezone, using default", "recipient", job.To, "timezone", tz)
+		return s.defaultLoc
+	}
+	return loc
+}
+
+// Close 关闭收件人档案存储
+func (s *Service) Close() error {
+	return s.profiles.Close()
+}
+
+// parseWindow 解析投递时段
+func parseWindow(rule WindowRule) (window, error) {
+	var (
+		w   window
+		err error
+	)
+	if w.start, err = parseClock(rule.Start); err != nil {
+		return w, fmt.Errorf("quiethours: window for category %q: %w", rule.Category, err)
+	}
+	if w.end, err = parseClock(rule.End); err != nil {
+		return w, fmt.Errorf("quiethours: window for category %q: %w", rule.Category, err)
+	}
+	if len(rule.Days) == 0 {
+		w.days = 0x7f
+	}
+	for _, day := range rule.Days {
+		wd, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return w, fmt.Errorf("quiethours: window for category %q: invalid day %q", rule.Category, day)
+		}
+		w.days |= 1 << uint(wd)
+	}
+	return w, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回一天中的分钟数
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	h, err1 := strconv.Atoi(hh)
+	m, err2 := strconv.Atoi(mm)
+	if !ok || err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// allows 判断星期是否允许投递
+func (w window) allows(day time.Weekday) bool {
+	return w.days&(1<<uint(day)) != 0
+}
+
+// contains 判断当地时间 t 是否处于时段内
+func (w window) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case w.start == w.end:
+		return w.allows(day)
+	case w.start < w.end:
+		return w.allows(day) && m >= w.start && m < w.end
+	default:
+		// 跨午夜的时段：当天开始的部分，或前一天开始、延续到当天凌晨的部分
+		return (w.allows(day) && m >= w.start) || (w.allows((day+6)%7) && m < w.end)
+	}
+}
+
+// next 返回不早于 t 的第一个允许投递的时间
+func (w window) next(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	begin := w.start
+	if w.start == w.end {
+		begin = 0
+	}
+	for i := 0; i <= 7; i++ {
+		start := time.Date(t.Year(), t.Month(), t.Day()+i, begin/60, begin%60, 0, 0, t.Location())
+		if start.After(t) && w.allows(start.Weekday()) {
+			return start
+		}
+	}
+	// 没有允许的星期，不做限制
+	return t
+}
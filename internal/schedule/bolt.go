@@ -0,0 +1,215 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketSchedules = []byte("schedules") // ID -> Schedule
+	bucketQueued    = []byte("queued")    // ID -> 时间点 -> 已入队的任务ID集合
+)
+
+// BoltStore 基于 bbolt 的定时任务存储，只能由单个实例打开
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开或创建 bolt 定时任务存储
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schedule store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketSchedules, bucketQueued} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Create 保存新的定时任务
+func (s *BoltStore) Create(ctx context.Context, sch *Schedule) error {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSchedules).Put([]byte(sch.ID), data)
+	})
+}
+
+// Get 按ID查询定时任务
+func (s *BoltStore) Get(ctx context.Context, id string) (*Schedule, error) {
+	var sch *Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketSchedules).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		sch = &Schedule{}
+		return json.Unmarshal(data, sch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// List 按创建时间顺序返回全部定时任务
+func (s *BoltStore) List(ctx context.Context) ([]*Schedule, error) {
+	var schedules []*Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSchedules).ForEach(func(_, data []byte) error {
+			sch := &Schedule{}
+			if err := json.Unmarshal(data, sch); err != nil {
+				return err
+			}
+			schedules = append(schedules, sch)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// Update 在一个事务中读取并修改定时任务
+func (s *BoltStore) Update(ctx context.Context, id string, fn func(*Schedule) error) (*Schedule, error) {
+	var sch *Schedule
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSchedules)
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		sch = &Schedule{}
+		if err := json.Unmarshal(data, sch); err != nil {
+			return err
+		}
+		if err := fn(sch); err != nil {
+			return err
+		}
+		data, err := json.Marshal(sch)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// Delete 删除定时任务
+func (s *BoltStore) Delete(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSchedules)
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		if err := b.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return deleteQueued(tx, id)
+	})
+}
+
+// QueuedJobs 返回定时任务在某个时间点已入队的任务ID
+func (s *BoltStore) QueuedJobs(ctx context.Context, id string, occurrence time.Time) (map[string]bool, error) {
+	queued := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueued).Bucket([]byte(id))
+		if b != nil {
+			b = b.Bucket(occurrenceKey(occurrence))
+		}
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			queued[string(k)] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queued, nil
+}
+
+// MarkQueued 记录定时任务在某个时间点已入队的任务ID
+func (s *BoltStore) MarkQueued(ctx context.Context, id string, occurrence time.Time, jobID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bucketQueued).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		if b, err = b.CreateBucketIfNotExists(occurrenceKey(occurrence)); err != nil {
+			return err
+		}
+		return b.Put([]byte(jobID), nil)
+	})
+}
+
+// ClearQueued 清除定时任务在某个时间点的入队记录
+func (s *BoltStore) ClearQueued(ctx context.Context, id string, occurrence time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueued).Bucket([]byte(id))
+		if b == nil {
+			return nil
+		}
+		err := b.DeleteBucket(occurrenceKey(occurrence))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// occurrenceKey 返回时间点在入队记录中的键
+func occurrenceKey(occurrence time.Time) []byte {
+	return []byte(occurrence.UTC().Format(time.RFC3339))
+}
+
+// deleteQueued 删除定时任务全部时间点的入队记录，记录不存在时忽略
+func deleteQueued(tx *bolt.Tx, id string) error {
+	err := tx.Bucket(bucketQueued).DeleteBucket([]byte(id))
+	if errors.Is(err, bolt.ErrBucketNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Close 关闭数据库
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// sortSchedules 按创建时间排序
+func sortSchedules(schedules []*Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+}
@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"email-service/pkg/jobqueue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Elector 决定当前实例是否负责触发定时任务
+type Elector interface {
+	// Elect 获取或续期主节点身份，返回当前实例是否为主节点
+	Elect(ctx context.Context) (bool, error)
+
+	// Resign 主动放弃主节点身份
+	Resign(ctx context.Context) error
+}
+
+// localElector 单实例部署时始终为主节点
+type localElector struct{}
+
+func (localElector) Elect(ctx context.Context) (bool, error) { return true, nil }
+
+func (localElector) Resign(ctx context.Context) error { return nil }
+
+// electScript 租约由本实例持有时续期，无人持有时获取
+// KEYS[1] 租约；ARGV: 实例令牌、租约毫秒数
+var electScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// resignScript 仅当租约由本实例持有时删除
+var resignScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisElector 基于 Redis 租约的选主，主节点需在租约到期前续期
+type RedisElector struct {
+	client *redis.Client
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+// NewRedisElector 创建 Redis 选主，每个实例使用随机令牌标识
+func NewRedisElector(client *redis.Client, key string, ttl time.Duration) *RedisElector {
+	return &RedisElector{client: client, key: key, token: jobqueue.NewJobID(), ttl: ttl}
+}
+
+// Elect 获取或续期租约
+func (e *RedisElector) Elect(ctx context.Context) (bool, error) {
+	n, err := electScript.Run(ctx, e.client, []string{e.key}, e.token, e.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Resign 释放租约，使其他实例无需等待租约到期即可接管
+func (e *RedisElector) Resign(ctx context.Context) error {
+	return resignScript.Run(ctx, e.client, []string{e.key}, e.token).Err()
+}
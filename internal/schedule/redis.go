@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxUpdateAttempts 并发修改同一定时任务时的最大重试次数
+const maxUpdateAttempts = 10
+
+// RedisStore 基于 Redis 的定时任务存储，多个实例共享
+// 每个定时任务保存为一个 JSON 字符串，ID 集合用于列出全部任务，每个时间点已入队的任务ID保存在集合 queued:<id>:<时间点> 中
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 定时任务存储
+func NewRedisStore(config *RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:schedule:"
+	}
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+// key 返回定时任务的键
+func (s *RedisStore) key(id string) string {
+	return s.prefix + "item:" + id
+}
+
+// queuedKey 返回定时任务在某个时间点的入队记录的键
+func (s *RedisStore) queuedKey(id string, occurrence time.Time) string {
+	return s.prefix + "queued:" + id + ":" + strconv.FormatInt(occurrence.Unix(), 10)
+}
+
+// Create 保存新的定时任务
+func (s *RedisStore) Create(ctx context.Context, sch *Schedule) error {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, s.key(sch.ID), data, 0)
+		p.SAdd(ctx, s.prefix+"ids", sch.ID)
+		return nil
+	})
+	return err
+}
+
+// Get 按ID查询定时任务
+func (s *RedisStore) Get(ctx context.Context, id string) (*Schedule, error) {
+	data, err := s.client.Get(ctx, s.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sch := &Schedule{}
+	if err := json.Unmarshal(data, sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// List 按创建时间顺序返回全部定时任务
+func (s *RedisStore) List(ctx context.Context) ([]*Schedule, error) {
+	ids, err := s.client.SMembers(ctx, s.prefix+"ids").Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]*Schedule, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		sch := &Schedule{}
+		if err := json.Unmarshal([]byte(data), sch); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sch)
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// Update 通过 WATCH 乐观锁读取并修改定时任务，并发修改时重试
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(*Schedule) error) (*Schedule, error) {
+	key := s.key(id)
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var sch *Schedule
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if errors.Is(err, redis.Nil) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			sch = &Schedule{}
+			if err := json.Unmarshal(data, sch); err != nil {
+				return err
+			}
+			if err := fn(sch); err != nil {
+				return err
+			}
+			data, err = json.Marshal(sch)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Set(ctx, key, data, 0)
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sch, nil
+	}
+	return nil, redis.TxFailedErr
+}
+
+// Delete 删除定时任务
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	var del *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		del = p.Del(ctx, s.key(id))
+		p.SRem(ctx, s.prefix+"ids", id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrNotFound
+	}
+
+	// 清除未完成触发遗留的入队记录
+	iter := s.client.Scan(ctx, 0, s.prefix+"queued:"+id+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// QueuedJobs 返回定时任务在某个时间点已入队的任务ID
+func (s *RedisStore) QueuedJobs(ctx context.Context, id string, occurrence time.Time) (map[string]bool, error) {
+	ids, err := s.client.SMembers(ctx, s.queuedKey(id, occurrence)).Result()
+	if err != nil {
+		return nil, err
+	}
+	queued := make(map[string]bool, len(ids))
+	for _, jobID := range ids {
+		queued[jobID] = true
+	}
+	return queued, nil
+}
+
+// MarkQueued 记录定时任务在某个时间点已入队的任务ID
+func (s *RedisStore) MarkQueued(ctx context.Context, id string, occurrence time.Time, jobID string) error {
+	return s.client.SAdd(ctx, s.queuedKey(id, occurrence), jobID).Err()
+}
+
+// ClearQueued 清除定时任务在某个时间点的入队记录
+func (s *RedisStore) ClearQueued(ctx context.Context, id string, occurrence time.Time) error {
+	return s.client.Del(ctx, s.queuedKey(id, occurrence)).Err()
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
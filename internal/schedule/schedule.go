@@ -0,0 +1,147 @@
+// Package schedule 按 cron 表达式周期性地生成邮件任务，多实例部署时通过 Redis 选主保证每个时间点只触发一次
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"email-service/pkg/jobqueue"
+)
+
+// ErrNotFound 定时任务不存在
+var ErrNotFound = errors.New("schedule not found")
+
+// Schedule 定时发送任务
+type Schedule struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Cron          string         `json:"cron"`     // 五段式 cron 表达式，或 @daily 等预定义表达式
+	Timezone      string         `json:"timezone"` // IANA 时区，如 Asia/Shanghai，为空时为 UTC
+	Subject       string         `json:"subject"`
+	Category      string         `json:"category,omitempty"`
+	Priority      string         `json:"priority,omitempty"`
+	TemplateID    string         `json:"template_id"`
+	TemplateData  map[string]any `json:"template_data,omitempty"`
+	Recipients    []string       `json:"recipients,omitempty"`
+	RecipientsURL string         `json:"recipients_url,omitempty"` // 每次触发时从该地址获取收件人
+	Paused        bool           `json:"paused"`
+	NextRunAt     time.Time      `json:"next_run_at"`
+	LastRunAt     *time.Time     `json:"last_run_at,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+	Firing        *time.Time     `json:"firing,omitempty"`     // 正在触发的时间点，触发完成后清空
+	ClaimedAt     *time.Time     `json:"claimed_at,omitempty"` // 开始触发的时间
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Validate 校验定时任务的配置
+func (s *Schedule) Validate() error {
+	if s.Subject == "" {
+		return errors.New("subject is required")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", s.Timezone)
+	}
+	if _, err := s.Next(time.Now()); err != nil {
+		return err
+	}
+	if !jobqueue.ValidPriority(s.Priority) {
+		return fmt.Errorf("unsupported priority: %s", s.Priority)
+	}
+	switch {
+	case len(s.Recipients) > 0 && s.RecipientsURL != "":
+		return errors.New("recipients and recipients_url cannot both be set")
+	case len(s.Recipients) == 0 && s.RecipientsURL == "":
+		return errors.New("recipients or recipients_url is required")
+	case s.RecipientsURL != "":
+		u, err := url.Parse(s.RecipientsURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid recipients_url: %s", s.RecipientsURL)
+		}
+	}
+	return nil
+}
+
+// Next 返回 after 之后的下一个触发时间，按定时任务的时区计算
+func (s *Schedule) Next(after time.Time) (time.Time, error) {
+	c, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never fires", s.Cron)
+	}
+	return next.UTC(), nil
+}
+
+// Store 定义定时任务存储接口
+type Store interface {
+	// Create 保存新的定时任务
+	Create(ctx context.Context, s *Schedule) error
+
+	// Get 按ID查询定时任务
+	Get(ctx context.Context, id string) (*Schedule, error)
+
+	// List 按创建时间顺序返回全部定时任务
+	List(ctx context.Context) ([]*Schedule, error)
+
+	// Update 原子地读取并修改定时任务，fn 返回错误时放弃修改并返回该错误
+	Update(ctx context.Context, id string, fn func(*Schedule) error) (*Schedule, error)
+
+	// Delete 删除定时任务及其入队记录
+	Delete(ctx context.Context, id string) error
+
+	// QueuedJobs 返回定时任务在某个时间点已入队的任务ID
+	QueuedJobs(ctx context.Context, id string, occurrence time.Time) (map[string]bool, error)
+
+	// MarkQueued 记录定时任务在某个时间点已入队的任务ID
+	MarkQueued(ctx context.Context, id string, occurrence time.Time, jobID string) error
+
+	// ClearQueued 清除定时任务在某个时间点的入队记录，触发完成后调用
+	ClearQueued(ctx context.Context, id string, occurrence time.Time) error
+
+	// Close 关闭存储
+	Close() error
+}
+
+// Config 定时任务配置
+type Config struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Store     string        `mapstructure:"store"` // bolt 或 redis，多实例部署时使用 redis
+	Path      string        `mapstructure:"path"`  // bolt 类型的数据文件
+	Redis     *RedisConfig  `mapstructure:"redis"`
+	Interval  time.Duration `mapstructure:"interval"`   // 检查到期任务的间隔
+	LeaderTTL time.Duration `mapstructure:"leader_ttl"` // 主节点租约时长，主节点失联后其他实例最迟在该时长后接管
+}
+
+// RedisConfig Redis 定时任务存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	StoreBolt  = "bolt"
+	StoreRedis = "redis"
+)
+
+// DefaultConfig 返回默认定时任务配置，默认不启用
+func DefaultConfig() *Config {
+	return &Config{
+		Store:     StoreBolt,
+		Path:      "data/schedules.db",
+		Interval:  5 * time.Second,
+		LeaderTTL: 30 * time.Second,
+	}
+}
@@ -0,0 +1,452 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/internal/suppression"
+	"email-service/pkg/jobqueue"
+)
+
+const (
+	fetchTimeout     = 30 * time.Second // 获取收件人列表的超时时间
+	maxRecipientBody = 10 << 20         // 收件人列表响应的最大字节数
+)
+
+// errNotDue 定时任务已被触发、暂停或修改，放弃本次修改
+var errNotDue = errors.New("schedule is not due")
+
+// JobPusher 接收定时生成的邮件任务，由 mailer.Dispatcher 实现
+type JobPusher interface {
+	PushJob(job jobqueue.EmailJob) error
+}
+
+// Scheduler 维护定时任务，并由主节点在到期时生成邮件任务
+type Scheduler struct {
+	store       Store
+	elector     Elector
+	pusher      JobPusher
+	suppression suppression.Store
+	client      *http.Client
+	config      *Config
+	logger      *logger.Logger
+}
+
+// New 根据配置创建调度器；redis 存储同时用于选主
+func New(cfg *Config, pusher JobPusher) (*Scheduler, error) {
+	s := &Scheduler{
+		pusher: pusher,
+		client: &http.Client{Timeout: fetchTimeout},
+		config: cfg,
+		logger: logger.GetDefault().WithComponent("scheduler"),
+	}
+	switch cfg.Store {
+	case StoreBolt, "":
+		path := cfg.Path
+		if path == "" {
+			path = DefaultConfig().Path
+		}
+		store, err := NewBoltStore(path)
+		if err != nil {
+			return nil, err
+		}
+		s.store, s.elector = store, localElector{}
+	case StoreRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("schedule: redis config is required")
+		}
+		store, err := NewRedisStore(cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+		s.store = store
+		s.elector = NewRedisElector(store.client, store.prefix+"leader", s.leaderTTL())
+	default:
+		return nil, fmt.Errorf("schedule: unsupported store type %q", cfg.Store)
+	}
+	return s, nil
+}
+
+// SetSuppressionStore 设置屏蔽名单，被屏蔽的收件人不会入队，需在 Run 之前调用
+func (s *Scheduler) SetSuppressionStore(store suppression.Store) {
+	s.suppression = store
+}
+
+// Create 校验并保存新的定时任务，计算首次触发时间
+func (s *Scheduler) Create(ctx context.Context, sch *Schedule) (*Schedule, error) {
+	if err := sch.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	next, err := sch.Next(now)
+	if err != nil {
+		return nil, err
+	}
+	created := *sch
+	created.ID = jobqueue.NewJobID()
+	created.NextRunAt = next
+	created.LastRunAt, created.LastError = nil, ""
+	created.Firing, created.ClaimedAt = nil, nil
+	created.CreatedAt, created.UpdatedAt = now, now
+	if err := s.store.Create(ctx, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Replace 用 sch 的配置替换定时任务，并按新的表达式重新计算下次触发时间
+func (s *Scheduler) Replace(ctx context.Context, id string, sch *Schedule) (*Schedule, error) {
+	if err := sch.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	next, err := sch.Next(now)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Update(ctx, id, func(cur *Schedule) error {
+		cur.Name = sch.Name
+		cur.Cron = sch.Cron
+		cur.Timezone = sch.Timezone
+		cur.Subject = sch.Subject
+		cur.Category = sch.Category
+		cur.Priority = sch.Priority
+		cur.TemplateID = sch.TemplateID
+		cur.TemplateData = sch.TemplateData
+		cur.Recipients = sch.Recipients
+		cur.RecipientsURL = sch.RecipientsURL
+		cur.Paused = sch.Paused
+		cur.NextRunAt = next
+		cur.UpdatedAt = now
+		return nil
+	})
+}
+
+// Get 按ID查询定时任务
+func (s *Scheduler) Get(ctx context.Context, id string) (*Schedule, error) {
+	return s.store.Get(ctx, id)
+}
+
+// List 返回全部定时任务
+func (s *Scheduler) List(ctx context.Context) ([]*Schedule, error) {
+	return s.store.List(ctx)
+}
+
+// Delete 删除定时任务
+func (s *Scheduler) Delete(ctx context.Context, id string) error {
+	return s.store.Delete(ctx, id)
+}
+
+// Run 周期性竞选主节点，主节点触发到期的定时任务，直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.store.Close()
+
+	interval := s.config.Interval
+	if interval <= 0 {
+		interval = DefaultConfig().Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	leader := false
+	for {
+		select {
+		case <-ctx.Done():
+			if leader {
+				resignCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := s.elector.Resign(resignCtx); err != nil {
+					s.logger.Warn("Failed to resign leadership", "error", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		elected, err := s.elector.Elect(ctx)
+		if err != nil {
+			s.logger.Error("Failed to elect scheduler leader", "error", err)
+			elected = false
+		}
+		if elected != leader {
+			leader = elected
+			s.logger.Info("Scheduler leadership changed", "leader", leader)
+		}
+		if leader {
+			s.RunDue(ctx)
+		}
+	}
+}
+
+// RunDue 触发全部到期的定时任务，并接管其他实例未完成的触发
+func (s *Scheduler) RunDue(ctx context.Context) {
+	schedules, err := s.store.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list schedules", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, sch := range schedules {
+		if sch.Firing != nil {
+			// 触发者失联超过租约时长，由当前主节点补完
+			if sch.ClaimedAt != nil && now.Sub(*sch.ClaimedAt) > s.leaderTTL() && s.takeOver(ctx, sch, now) {
+				s.logger.Warn("Resuming interrupted schedule run", "schedule_id", sch.ID, "occurrence", *sch.Firing)
+				s.fire(ctx, sch, *sch.Firing)
+			}
+			continue
+		}
+		if sch.Paused || sch.NextRunAt.After(now) {
+			continue
+		}
+
+		claimed, err := s.claim(ctx, sch.ID, sch.NextRunAt, now)
+		if err != nil {
+			s.logger.Error("Failed to claim schedule run", "schedule_id", sch.ID, "error", err)
+			continue
+		}
+		if claimed != nil {
+			s.fire(ctx, claimed, *claimed.Firing)
+		}
+	}
+}
+
+// claim 将到期的时间点标记为正在触发并推进下次触发时间
+// 时间点已被其他实例触发或定时任务已被修改时返回 nil
+func (s *Scheduler) claim(ctx context.Context, id string, occurrence, now time.Time) (*Schedule, error) {
+	sch, err := s.store.Update(ctx, id, func(cur *Schedule) error {
+		if cur.Paused || cur.Firing != nil || !cur.NextRunAt.Equal(occurrence) {
+			return errNotDue
+		}
+		// 停机期间错过的多个时间点只补发一次
+		next, err := cur.Next(now)
+		if err != nil {
+			return err
+		}
+		cur.NextRunAt = next
+		cur.Firing = &occurrence
+		cur.ClaimedAt = &now
+		cur.LastRunAt = &occurrence
+		return nil
+	})
+	if errors.Is(err, errNotDue) || errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return sch, err
+}
+
+// takeOver 接管未完成的触发；开始时间已被其他实例刷新时返回 false
+func (s *Scheduler) takeOver(ctx context.Context, sch *Schedule, now time.Time) bool {
+	_, err := s.store.Update(ctx, sch.ID, func(cur *Schedule) error {
+		if cur.Firing == nil || !cur.Firing.Equal(*sch.Firing) ||
+			cur.ClaimedAt == nil || !cur.ClaimedAt.Equal(*sch.ClaimedAt) {
+			return errNotDue
+		}
+		cur.ClaimedAt = &now
+		return nil
+	})
+	if err != nil && !errors.Is(err, errNotDue) && !errors.Is(err, ErrNotFound) {
+		s.logger.Error("Failed to take over schedule run", "schedule_id", sch.ID, "error", err)
+	}
+	return err == nil
+}
+
+// fire 为时间点的每个收件人生成任务，任务ID由定时任务、时间点和收件人确定
+// 每个收件人入队后记录到定时任务存储，接管未完成的触发时跳过已入队的收件人
+func (s *Scheduler) fire(ctx context.Context, sch *Schedule, occurrence time.Time) {
+	var lastError string
+	queued, failed := 0, 0
+
+	done, err := s.store.QueuedJobs(ctx, sch.ID, occurrence)
+	if err != nil {
+		// 无法确认哪些收件人已入队，保留触发标记由下次接管时重试
+		s.logger.Error("Failed to load schedule progress", "schedule_id", sch.ID, "error", err)
+		return
+	}
+
+	recipients, err := s.recipients(ctx, sch)
+	if err != nil {
+		s.logger.Error("Failed to resolve schedule recipients", "schedule_id", sch.ID, "error", err)
+		lastError = err.Error()
+	}
+	seen := make(map[string]bool, len(recipients))
+	lastBeat := time.Now()
+	for _, email := range recipients {
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+
+		// 收件人较多时定期续期，失去主节点身份后停止，由新的主节点补完
+		if time.Since(lastBeat) > s.leaderTTL()/3 {
+			if !s.heartbeat(ctx, sch.ID, occurrence) {
+				s.logger.Warn("Lost leadership during schedule run", "schedule_id", sch.ID, "occurrence", occurrence)
+				return
+			}
+			lastBeat = time.Now()
+		}
+
+		job := buildJob(sch, occurrence, email)
+		if done[job.ID] {
+			continue
+		}
+		if suppression.Suppressed(ctx, s.suppression, email, sch.Category, s.logger) {
+			continue
+		}
+		if err := s.pusher.PushJob(job); err != nil {
+			s.logger.Error("Failed to push scheduled job", "schedule_id", sch.ID, "recipient", email, "error", err)
+			failed++
+			lastError = err.Error()
+			continue
+		}
+		queued++
+		// 已入队的任务即使 ctx 已取消也要记录
+		if err := s.store.MarkQueued(context.Background(), sch.ID, occurrence, job.ID); err != nil {
+			s.logger.Error("Failed to record schedule progress", "schedule_id", sch.ID, "job_id", job.ID, "error", err)
+		}
+	}
+	if failed > 0 {
+		lastError = fmt.Sprintf("%d of %d recipients failed: %s", failed, len(seen), lastError)
+	}
+
+	s.logger.Info("Schedule fired",
+		"schedule_id", sch.ID, "occurrence", occurrence, "queued", queued, "failed", failed)
+	s.finish(ctx, sch.ID, occurrence, lastError)
+}
+
+// heartbeat 续期主节点租约并刷新开始触发的时间，返回是否仍为主节点
+func (s *Scheduler) heartbeat(ctx context.Context, id string, occurrence time.Time) bool {
+	elected, err := s.elector.Elect(ctx)
+	if err != nil || !elected {
+		return false
+	}
+	_, err = s.store.Update(ctx, id, func(cur *Schedule) error {
+		if cur.Firing == nil || !cur.Firing.Equal(occurrence) {
+			return errNotDue
+		}
+		now := time.Now().UTC()
+		cur.ClaimedAt = &now
+		return nil
+	})
+	return err == nil
+}
+
+// finish 清除正在触发的标记并记录本次触发的错误
+func (s *Scheduler) finish(ctx context.Context, id string, occurrence time.Time, lastError string) {
+	// 任务已入队，即使 ctx 已取消也要保存结果
+	_, err := s.store.Update(context.Background(), id, func(cur *Schedule) error {
+		if cur.Firing == nil || !cur.Firing.Equal(occurrence) {
+			return errNotDue
+		}
+		cur.Firing, cur.ClaimedAt = nil, nil
+		cur.LastError = lastError
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errNotDue) && !errors.Is(err, ErrNotFound) {
+			s.logger.Error("Failed to finish schedule run", "schedule_id", id, "error", err)
+		}
+		return
+	}
+	if err := s.store.ClearQueued(context.Background(), id, occurrence); err != nil {
+		s.logger.Warn("Failed to clear schedule progress", "schedule_id", id, "error", err)
+	}
+}
+
+// recipients 返回定时任务的收件人，设置了 recipients_url 时从该地址获取
+// 响应可以是 JSON 数组、带 recipients 字段的 JSON 对象，或每行一个地址的文本（忽略空行和 # 开头的行）
+func (s *Scheduler) recipients(ctx context.Context, sch *Schedule) ([]string, error) {
+	if sch.RecipientsURL == "" {
+		return sch.Recipients, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sch.RecipientsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recipients: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch recipients: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRecipientBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recipients: %w", err)
+	}
+	if len(body) > maxRecipientBody {
+		return nil, fmt.Errorf("recipient list exceeds %d bytes", maxRecipientBody)
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var list []string
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("invalid recipient list: %w", err)
+		}
+		return list, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var obj struct {
+			Recipients []string `json:"recipients"`
+		}
+		if err := json.Unmarshal(trimmed, &obj); err != nil {
+			return nil, fmt.Errorf("invalid recipient list: %w", err)
+		}
+		return obj.Recipients, nil
+	}
+
+	var list []string
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			list = append(list, line)
+		}
+	}
+	return list, scanner.Err()
+}
+
+// buildJob 生成定时任务在某个时间点发给收件人的邮件任务
+func buildJob(sch *Schedule, occurrence time.Time, email string) jobqueue.EmailJob {
+	now := time.Now()
+	return jobqueue.EmailJob{
+		ID:           occurrenceJobID(sch.ID, occurrence, email),
+		To:           email,
+		Subject:      sch.Subject,
+		Category:     sch.Category,
+		Priority:     sch.Priority,
+		TemplateID:   sch.TemplateID,
+		TemplateData: sch.TemplateData,
+		MaxRetries:   3,
+		NextRetryAt:  now,
+		CreatedAt:    now,
+	}
+}
+
+// occurrenceJobID 由定时任务、时间点和收件人确定任务ID
+func occurrenceJobID(id string, occurrence time.Time, email string) string {
+	sum := sha256.Sum256([]byte(id + "\n" + occurrence.UTC().Format(time.RFC3339) + "\n" + strings.ToLower(email)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// leaderTTL 返回主节点租约时长
+func (s *Scheduler) leaderTTL() time.Duration {
+	if s.config.LeaderTTL <= 0 {
+		return DefaultConfig().LeaderTTL
+	}
+	return s.config.LeaderTTL
+}
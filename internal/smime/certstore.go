@@ -0,0 +1,49 @@
+package smime
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CertStore 按邮箱地址查找收件人证书
+type CertStore interface {
+	Lookup(address string) (*x509.Certificate, error)
+}
+
+// DirCertStore 基于本地目录的证书存储，证书文件名为 <邮箱地址>.pem
+type DirCertStore struct {
+	dir string
+}
+
+// NewDirCertStore 创建目录证书存储
+func NewDirCertStore(dir string) *DirCertStore {
+	return &DirCertStore{dir: dir}
+}
+
+// Lookup 查找并校验收件人证书
+func (s *DirCertStore) Lookup(address string) (*x509.Certificate, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" || strings.ContainsAny(address, `/\`) || strings.HasPrefix(address, ".") {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrNoRecipientCert, address)
+	}
+
+	certs, err := loadCertificates(filepath.Join(s.dir, address+".pem"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w %s", ErrNoRecipientCert, address)
+		}
+		return nil, err
+	}
+
+	cert := certs[0]
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("%w %s: certificate not valid at %s", ErrNoRecipientCert, address, now.Format(time.RFC3339))
+	}
+	return cert, nil
+}
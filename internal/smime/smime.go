@@ -0,0 +1,207 @@
+// Package smime 实现 S/MIME (RFC 8551) 邮件签名与加密
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"email-service/internal/mimeutil"
+
+	"github.com/smallstep/pkcs7"
+)
+
+var (
+	// ErrNoSigningCert 未配置签名证书
+	ErrNoSigningCert = errors.New("smime: signing certificate not configured")
+
+	// ErrNoRecipientCert 收件人没有可用的加密证书
+	ErrNoRecipientCert = errors.New("smime: no certificate for recipient")
+)
+
+func init() {
+	// 使用主流邮件客户端均支持的 AES-256-CBC 作为内容加密算法
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+}
+
+// Config S/MIME 配置
+type Config struct {
+	CertFile     string `mapstructure:"cert_file"`      // 签名证书（PEM，可附带中间证书链）
+	KeyFile      string `mapstructure:"key_file"`       // 签名私钥（PEM）
+	CertStoreDir string `mapstructure:"cert_store_dir"` // 收件人证书目录，文件名为 <邮箱地址>.pem
+}
+
+// Enabled 是否配置了 S/MIME
+func (c *Config) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.CertStoreDir != "")
+}
+
+// Service 提供 S/MIME 签名和加密
+type Service struct {
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+	key   crypto.PrivateKey
+	store CertStore
+}
+
+// New 根据配置创建 S/MIME 服务
+func New(cfg *Config) (*Service, error) {
+	s := &Service{}
+
+	if cfg.CertFile != "" {
+		certs, err := loadCertificates(cfg.CertFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := loadPrivateKey(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.cert, s.chain, s.key = certs[0], certs[1:], key
+	}
+
+	if cfg.CertStoreDir != "" {
+		s.store = NewDirCertStore(cfg.CertStoreDir)
+	}
+	return s, nil
+}
+
+// CanSign 是否配置了签名证书
+func (s *Service) CanSign() bool {
+	return s.cert != nil
+}
+
+// CanEncrypt 是否配置了收件人证书目录
+func (s *Service) CanEncrypt() bool {
+	return s.store != nil
+}
+
+// Sign 将邮件内容签名为 multipart/signed 格式
+func (s *Service) Sign(raw []byte) ([]byte, error) {
+	if s.cert == nil {
+		return nil, ErrNoSigningCert
+	}
+
+	outer, entity, err := mimeutil.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pkcs7.NewSignedData(entity)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err = sd.AddSignerChain(s.cert, s.key, s.chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("smime: failed to sign: %w", err)
+	}
+	sd.Detach()
+	signature, err := sd.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("smime: failed to sign: %w", err)
+	}
+
+	boundary := mimeutil.Boundary()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256;\r\n boundary=\"%s\"\r\n\r\n", boundary)
+	buf.WriteString("This is a cryptographically signed message in MIME format.\r\n\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.Write(entity)
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"smime.p7s\"\r\n\r\n")
+	mimeutil.WriteBase64(&buf, signature)
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return mimeutil.Join(outer, buf.Bytes()), nil
+}
+
+// Encrypt 使用收件人证书将邮件内容加密为 application/pkcs7-mime 格式
+// 任一收件人缺少证书时返回 ErrNoRecipientCert
+func (s *Service) Encrypt(raw []byte, recipients []string) ([]byte, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("%w: certificate store not configured", ErrNoRecipientCert)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(recipients))
+	for _, addr := range recipients {
+		cert, err := s.store.Lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	outer, entity, err := mimeutil.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	enveloped, err := pkcs7.Encrypt(entity, certs)
+	if err != nil {
+		return nil, fmt.Errorf("smime: failed to encrypt: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=\"smime.p7m\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"smime.p7m\"\r\n\r\n")
+	mimeutil.WriteBase64(&buf, enveloped)
+
+	return mimeutil.Join(outer, buf.Bytes()), nil
+}
+
+// loadCertificates 读取 PEM 文件中的所有证书，第一个为终端证书
+func loadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("smime: failed to read certificate: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("smime: invalid certificate in %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("smime: no certificate found in %s", path)
+	}
+	return certs, nil
+}
+
+// loadPrivateKey 读取 PEM 格式的私钥
+func loadPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("smime: failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("smime: no PEM block found in %s", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
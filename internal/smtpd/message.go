@@ -0,0 +1,169 @@
+package smtpd
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"email-service/internal/mimeutil"
+	"email-service/pkg/jobqueue"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// CategoryHeader 提交的邮件通过该头部指定类别
+const CategoryHeader = "X-Email-Category"
+
+// PriorityHeader 提交的邮件通过该头部指定优先级，取值不合法时按 normal 处理
+const PriorityHeader = "X-Email-Priority"
+
+// message 从提交的邮件中提取的任务内容
+type message struct {
+	Subject     string
+	Category    string
+	Priority    string
+	Body        string // HTML 正文
+	InReplyTo   string
+	References  []string
+	Attachments []attachmentPart
+}
+
+// attachmentPart 邮件中的附件
+type attachmentPart struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// wordDecoder 解码 RFC 2047 编码的头部，支持 GBK 等非 UTF-8 字符集
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseMessage 解析提交的邮件，优先使用 HTML 正文，纯文本正文会转换为 HTML
+func parseMessage(r io.Reader) (*message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &message{
+		Subject:    decodeHeader(msg.Header.Get("Subject")),
+		Category:   strings.TrimSpace(msg.Header.Get(CategoryHeader)),
+		Priority:   strings.ToLower(strings.TrimSpace(msg.Header.Get(PriorityHeader))),
+		InReplyTo:  strings.TrimSpace(msg.Header.Get("In-Reply-To")),
+		References: strings.Fields(msg.Header.Get("References")),
+	}
+
+	if !jobqueue.ValidPriority(m.Priority) {
+		m.Priority = ""
+	}
+
+	var htmlBody, textBody string
+	if err := walk(textproto.MIMEHeader(msg.Header), msg.Body, m, &htmlBody, &textBody); err != nil {
+		return nil, err
+	}
+	switch {
+	case htmlBody != "":
+		m.Body = htmlBody
+	case textBody != "":
+		m.Body = textToHTML(textBody)
+	}
+	return m, nil
+}
+
+// walk 遍历 MIME 结构，收集第一个 HTML 和纯文本正文，其余部分作为附件
+func walk(header textproto.MIMEHeader, body io.Reader, m *message, htmlBody, textBody *string) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walk(part.Header, part, m, htmlBody, textBody); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(mimeutil.DecodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode part: %w", err)
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(dparams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	isAttachment := disposition == "attachment" || filename != ""
+
+	switch {
+	case !isAttachment && mediaType == "text/html" && *htmlBody == "":
+		*htmlBody, err = decodeCharset(params["charset"], data)
+	case !isAttachment && mediaType == "text/plain" && *textBody == "":
+		*textBody, err = decodeCharset(params["charset"], data)
+	default:
+		if filename == "" {
+			filename = "attachment"
+			if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+				filename += exts[0]
+			}
+		}
+		m.Attachments = append(m.Attachments, attachmentPart{Filename: filename, ContentType: mediaType, Data: data})
+	}
+	return err
+}
+
+// decodeHeader 解码 RFC 2047 编码的头部，失败时返回原文
+func decodeHeader(v string) string {
+	decoded, err := wordDecoder.DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return decoded
+}
+
+// decodeCharset 将正文按声明的字符集转换为 UTF-8
+func decodeCharset(charset string, data []byte) (string, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(data), nil
+	}
+	r, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// charsetReader 返回将指定字符集转换为 UTF-8 的 Reader
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// textToHTML 将纯文本正文转换为保留换行的 HTML
+func textToHTML(text string) string {
+	return `<pre style="white-space: pre-wrap; font-family: inherit;">` + html.EscapeString(text) + `</pre>`
+}
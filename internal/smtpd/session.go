@@ -0,0 +1,264 @@
+package smtpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"time"
+
+	"email-service/internal/attachment"
+	"email-service/internal/suppression"
+	"email-service/pkg/jobqueue"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+// errAttachmentTooLarge 附件超过附件存储的大小限制
+var errAttachmentTooLarge = &smtp.SMTPError{
+	Code:         552,
+	EnhancedCode: smtp.EnhancedCode{5, 3, 4},
+	Message:      "Attachment exceeds the size limit",
+}
+
+// errQueueUnavailable 任务无法入队时返回的临时错误，客户端会稍后重试
+var errQueueUnavailable = &smtp.SMTPError{
+	Code:         451,
+	EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+	Message:      "Unable to queue message, try again later",
+}
+
+// session 单个 SMTP 连接的会话
+type session struct {
+	server   *Server
+	remote   string
+	username string
+	from     string
+	to       []string
+}
+
+// AuthMechanisms 实现 smtp.AuthSession
+func (s *session) AuthMechanisms() []string {
+	return []string{sasl.Plain, sasl.Login}
+}
+
+// Auth 实现 smtp.AuthSession
+func (s *session) Auth(mech string) (sasl.Server, error) {
+	authenticate := func(username, password string) error {
+		if !s.server.authenticate(username, password) {
+			s.server.logger.Warn("SMTP authentication failed", "username", username, "remote_addr", s.remote)
+			return smtp.ErrAuthFailed
+		}
+		s.username = username
+		return nil
+	}
+
+	switch mech {
+	case sasl.Plain:
+		return sasl.NewPlainServer(func(identity, username, password string) error {
+			if identity != "" && identity != username {
+				return smtp.ErrAuthFailed
+			}
+			return authenticate(username, password)
+		}), nil
+	case sasl.Login:
+		return &loginServer{authenticate: authenticate}, nil
+	}
+	return nil, smtp.ErrAuthUnknownMechanism
+}
+
+// Mail 实现 smtp.Session
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	if s.username == "" {
+		return smtp.ErrAuthRequired
+	}
+	s.from = from
+	return nil
+}
+
+// Rcpt 实现 smtp.Session
+func (s *session) Rcpt(to string, opts *smtp.RcptOptions) error {
+	if s.username == "" {
+		return smtp.ErrAuthRequired
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return &smtp.SMTPError{
+			Code:         553,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 3},
+			Message:      "Invalid recipient address",
+		}
+	}
+	s.to = append(s.to, to)
+	return nil
+}
+
+// Data 实现 smtp.Session：解析邮件并为每个收件人推入一个任务
+func (s *session) Data(r io.Reader) error {
+	if s.username == "" {
+		return smtp.ErrAuthRequired
+	}
+
+	msg, err := parseMessage(r)
+	if err != nil {
+		if errors.Is(err, smtp.ErrDataTooLarge) {
+			return err
+		}
+		s.server.logger.Warn("Rejected unparsable message", "username", s.username, "error", err)
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "Unable to parse message",
+		}
+	}
+
+	queued, skipped := 0, 0
+	var attachments []jobqueue.Attachment
+	stored := false
+	for _, to := range s.to {
+		if suppression.Suppressed(context.Background(), s.server.suppression, to, msg.Category, s.server.logger) {
+			skipped++
+			continue
+		}
+
+		// 附件只保存一次，所有收件人的任务通过ID引用
+		if !stored {
+			if attachments, err = s.storeAttachments(msg.Attachments); err != nil {
+				if errors.Is(err, attachment.ErrTooLarge) {
+					return errAttachmentTooLarge
+				}
+				s.server.logger.Error("Failed to store attachments", "username", s.username, "error", err)
+				return errQueueUnavailable
+			}
+			stored = true
+		}
+
+		job := jobqueue.EmailJob{
+			ID:          jobqueue.NewJobID(),
+			To:          to,
+			Subject:     msg.Subject,
+			Category:    msg.Category,
+			Priority:    msg.Priority,
+			Body:        msg.Body,
+			MaxRetries:  3,
+			NextRetryAt: time.Now(),
+			CreatedAt:   time.Now(),
+			Attachments: attachments,
+			InReplyTo:   msg.InReplyTo,
+			References:  msg.References,
+		}
+		if err := s.retainAttachments(attachments); err != nil {
+			s.server.logger.Error("Failed to retain attachments", "recipient", to, "error", err)
+			continue
+		}
+		if err := s.server.pusher.PushJob(job); err != nil {
+			s.server.logger.Error("Failed to push job to queue", "recipient", to, "error", err)
+			s.releaseAttachments(attachments)
+			continue
+		}
+		queued++
+	}
+
+	// 全部入队失败时返回临时错误让客户端重试；部分失败时重试会造成重复发送，只记录日志
+	if queued == 0 && skipped < len(s.to) {
+		return errQueueUnavailable
+	}
+
+	s.server.logger.Info("Message accepted over SMTP",
+		"username", s.username,
+		"envelope_from", s.from,
+		"recipients", len(s.to),
+		"queued", queued,
+		"suppressed", skipped,
+		"remote_addr", s.remote)
+	return nil
+}
+
+// Reset 实现 smtp.Session
+func (s *session) Reset() {
+	s.from = ""
+	s.to = nil
+}
+
+// Logout 实现 smtp.Session
+func (s *session) Logout() error {
+	return nil
+}
+
+// storeAttachments 将附件保存到附件存储并返回按ID引用的附件，未配置附件存储时内联到任务中
+func (s *session) storeAttachments(parts []attachmentPart) ([]jobqueue.Attachment, error) {
+	attachments := make([]jobqueue.Attachment, 0, len(parts))
+	for _, p := range parts {
+		if s.server.attachments == nil {
+			attachments = append(attachments, jobqueue.Attachment{
+				Filename: p.Filename,
+				Content:  base64.StdEncoding.EncodeToString(p.Data),
+			})
+			continue
+		}
+		meta, err := s.server.attachments.Save(context.Background(), p.Filename, p.ContentType, bytes.NewReader(p.Data), 0)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, jobqueue.Attachment{ID: meta.ID, Filename: meta.Filename})
+	}
+	return attachments, nil
+}
+
+// retainAttachments 为任务引用的每个已保存附件增加一次引用计数
+func (s *session) retainAttachments(attachments []jobqueue.Attachment) error {
+	for i, att := range attachments {
+		if att.ID == "" {
+			continue
+		}
+		if err := s.server.attachments.Retain(context.Background(), att.ID); err != nil {
+			s.releaseAttachments(attachments[:i])
+			return fmt.Errorf("attachment %s: %w", att.ID, err)
+		}
+	}
+	return nil
+}
+
+// releaseAttachments 释放未能入队的任务对已保存附件的引用
+func (s *session) releaseAttachments(attachments []jobqueue.Attachment) {
+	for _, att := range attachments {
+		if att.ID == "" {
+			continue
+		}
+		if err := s.server.attachments.Release(context.Background(), att.ID); err != nil {
+			s.server.logger.Warn("Failed to release attachment", "id", att.ID, "error", err)
+		}
+	}
+}
+
+// loginServer 实现 AUTH LOGIN 的服务端，go-sasl 只提供了客户端
+type loginServer struct {
+	authenticate func(username, password string) error
+	username     string
+	step         int
+}
+
+// Next 实现 sasl.Server
+func (l *loginServer) Next(response []byte) (challenge []byte, done bool, err error) {
+	switch l.step {
+	case 0:
+		l.step++
+		if response == nil {
+			return []byte("Username:"), false, nil
+		}
+		l.username = string(response)
+		l.step++
+		return []byte("Password:"), false, nil
+	case 1:
+		l.username = string(response)
+		l.step++
+		return []byte("Password:"), false, nil
+	case 2:
+		l.step++
+		return nil, true, l.authenticate(l.username, string(response))
+	}
+	return nil, true, sasl.ErrUnexpectedClientResponse
+}
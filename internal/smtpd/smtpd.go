@@ -0,0 +1,143 @@
+// Package smtpd 内嵌的 SMTP 提交服务，将收到的邮件转换为任务推入队列
+package smtpd
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"email-service/internal/attachment"
+	"email-service/internal/logger"
+	"email-service/internal/suppression"
+	"email-service/pkg/jobqueue"
+
+	"github.com/emersion/go-smtp"
+)
+
+// Config SMTP 提交服务配置
+type Config struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	Addr              string        `mapstructure:"addr"`                // 监听地址
+	Domain            string        `mapstructure:"domain"`              // 问候语中的主机名
+	TLSCertFile       string        `mapstructure:"tls_cert_file"`       // 配置证书后支持 STARTTLS
+	TLSKeyFile        string        `mapstructure:"tls_key_file"`        //
+	AllowInsecureAuth bool          `mapstructure:"allow_insecure_auth"` // 允许在未加密连接上认证
+	MaxMessageBytes   int64         `mapstructure:"max_message_bytes"`   // 单封邮件最大字节数
+	MaxRecipients     int           `mapstructure:"max_recipients"`      // 单封邮件最大收件人数
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	Users             []User        `mapstructure:"users"` // 允许提交邮件的账号
+}
+
+// User 提交账号
+type User struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// DefaultConfig 返回默认 SMTP 提交服务配置
+func DefaultConfig() *Config {
+	return &Config{
+		Addr:            ":2525",
+		Domain:          "localhost",
+		MaxMessageBytes: 25 << 20,
+		MaxRecipients:   100,
+		ReadTimeout:     time.Minute,
+		WriteTimeout:    time.Minute,
+	}
+}
+
+// JobPusher 接收转换后的任务，由 mailer.Dispatcher 实现
+type JobPusher interface {
+	PushJob(job jobqueue.EmailJob) error
+}
+
+// Server SMTP 提交服务
+type Server struct {
+	server      *smtp.Server
+	pusher      JobPusher
+	users       map[string]string
+	suppression suppression.Store
+	attachments attachment.Store
+	logger      *logger.Logger
+}
+
+// NewServer 创建 SMTP 提交服务
+func NewServer(cfg *Config, pusher JobPusher) (*Server, error) {
+	if len(cfg.Users) == 0 {
+		return nil, errors.New("smtpd: at least one user is required")
+	}
+	users := make(map[string]string, len(cfg.Users))
+	for _, u := range cfg.Users {
+		if u.Username == "" || u.Password == "" {
+			return nil, errors.New("smtpd: username and password are required")
+		}
+		users[u.Username] = u.Password
+	}
+
+	s := &Server{
+		pusher: pusher,
+		users:  users,
+		logger: logger.GetDefault().WithComponent("smtpd"),
+	}
+
+	server := smtp.NewServer(s)
+	server.Addr = cfg.Addr
+	server.Domain = cfg.Domain
+	server.MaxMessageBytes = cfg.MaxMessageBytes
+	server.MaxRecipients = cfg.MaxRecipients
+	server.ReadTimeout = cfg.ReadTimeout
+	server.WriteTimeout = cfg.WriteTimeout
+	server.AllowInsecureAuth = cfg.AllowInsecureAuth
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("smtpd: failed to load tls certificate: %w", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	} else if !cfg.AllowInsecureAuth {
+		return nil, errors.New("smtpd: tls certificate is required unless allow_insecure_auth is set")
+	}
+
+	s.server = server
+	return s, nil
+}
+
+// SetSuppressionStore 设置屏蔽名单，被屏蔽的收件人不会入队
+func (s *Server) SetSuppressionStore(store suppression.Store) {
+	s.suppression = store
+}
+
+// SetAttachmentStore 设置附件存储，邮件中的附件保存一次后由各收件人的任务按ID引用
+func (s *Server) SetAttachmentStore(store attachment.Store) {
+	s.attachments = store
+}
+
+// ListenAndServe 开始监听，直到 Close 被调用
+func (s *Server) ListenAndServe() error {
+	s.logger.Info("SMTP submission server listening", "addr", s.server.Addr)
+	return s.server.ListenAndServe()
+}
+
+// Close 关闭服务
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// NewSession 实现 smtp.Backend
+func (s *Server) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &session{server: s, remote: c.Conn().RemoteAddr().String()}, nil
+}
+
+// authenticate 校验账号密码
+func (s *Server) authenticate(username, password string) bool {
+	expected, ok := s.users[username]
+	if !ok {
+		// 账号不存在时同样做一次比较，避免通过耗时区分账号是否存在
+		expected = password + "x"
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 && ok
+}
@@ -0,0 +1,49 @@
+// Package smtpstatus 解析 SMTP 响应码和 RFC 3463 增强状态码
+package smtpstatus
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	enhancedPattern = regexp.MustCompile(`\b[245]\.\d{1,3}\.\d{1,3}\b`)
+	codePattern     = regexp.MustCompile(`\b([245]\d\d)\b`)
+)
+
+// Enhanced 返回文本中第一个增强状态码（如 5.1.1），不存在时返回空串
+func Enhanced(text string) string {
+	return enhancedPattern.FindString(text)
+}
+
+// Code 返回文本中第一个三位 SMTP 响应码，不存在时返回 0
+func Code(text string) int {
+	m := codePattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(m[1])
+	return code
+}
+
+// IsPermanent 判断响应是否为永久性失败（5xx 或 5.x.x）
+func IsPermanent(code int, enhanced string) bool {
+	if enhanced != "" {
+		return strings.HasPrefix(enhanced, "5.")
+	}
+	return code >= 500 && code <= 599
+}
+
+// IsAddressFailure 判断永久性失败是否指向收件地址本身（硬退信）
+// 有增强状态码时以 5.1.x（地址错误）和 5.2.1（邮箱停用）为准，否则以 550/551/553 为准
+func IsAddressFailure(code int, enhanced string) bool {
+	if enhanced != "" {
+		return strings.HasPrefix(enhanced, "5.1.") || enhanced == "5.2.1"
+	}
+	switch code {
+	case 550, 551, 553:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 内存状态存储，超过保留时长的记录会被清理
+type MemoryStore struct {
+	mu        sync.RWMutex
+	jobs      map[string]*JobStatus
+	messages  map[string]string // Message-ID -> 任务ID
+	retention time.Duration
+}
+
+// NewMemoryStore 创建内存状态存储
+func NewMemoryStore(config *Config) *MemoryStore {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &MemoryStore{
+		jobs:      make(map[string]*JobStatus),
+		messages:  make(map[string]string),
+		retention: config.Retention,
+	}
+}
+
+// Save 保存任务状态
+func (s *MemoryStore) Save(ctx context.Context, st *JobStatus) error {
+	copied := *st
+	if copied.UpdatedAt.IsZero() {
+		copied.UpdatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[copied.JobID] = &copied
+	if copied.MessageID != "" {
+		s.messages[copied.MessageID] = copied.JobID
+	}
+	return nil
+}
+
+// Get 按任务ID查询状态
+func (s *MemoryStore) Get(ctx context.Context, jobID string) (*JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.jobs[jobID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *st
+	return &copied, nil
+}
+
+// GetByMessageID 按 Message-ID 查询状态
+func (s *MemoryStore) GetByMessageID(ctx context.Context, messageID string) (*JobStatus, error) {
+	s.mu.RLock()
+	jobID, ok := s.messages[messageID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.Get(ctx, jobID)
+}
+
+// Prune 删除超过保留时长的记录，返回删除数量
+func (s *MemoryStore) Prune() int {
+	if s.retention <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-s.retention)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, st := range s.jobs {
+		if st.UpdatedAt.Before(cutoff) {
+			delete(s.jobs, id)
+			if st.MessageID != "" {
+				delete(s.messages, st.MessageID)
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunPrune 周期性清理过期记录，直到 ctx 结束
+func (s *MemoryStore) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package status 记录邮件任务的投递状态
+package status
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"email-service/internal/logger"
+)
+
+// ErrNotFound 任务状态不存在
+var ErrNotFound = errors.New("job status not found")
+
+// State 任务状态
+type State string
+
+const (
+	StateQueued     State = "queued"     // 已入队
+	StateRetrying   State = "retrying"   // 发送失败，等待重试
+	StateSent       State = "sent"       // SMTP 服务器已接收
+	StateFailed     State = "failed"     // 永久失败
+	StateBounced    State = "bounced"    // 已被接收，但之后收到退信
+	StateComplained State = "complained" // 收件人投诉为垃圾邮件
+)
+
+// JobStatus 任务状态记录
+type JobStatus struct {
+	JobID      string    `json:"job_id"`
+	Recipient  string    `json:"recipient"`
+	Subject    string    `json:"subject"`
+	State      State     `json:"state"`
+	MessageID  string    `json:"message_id,omitempty"`
+	RetryCount int       `json:"retry_count"`
+	LastError  string    `json:"last_error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Config 状态存储配置
+type Config struct {
+	Retention time.Duration `mapstructure:"retention"` // 状态记录保留时长
+}
+
+// DefaultConfig 返回默认状态存储配置
+func DefaultConfig() *Config {
+	return &Config{
+		Retention: 72 * time.Hour,
+	}
+}
+
+// Store 定义任务状态存储接口
+type Store interface {
+	// Save 保存（新增或覆盖）任务状态
+	Save(ctx context.Context, st *JobStatus) error
+
+	// Get 按任务ID查询状态
+	Get(ctx context.Context, jobID string) (*JobStatus, error)
+
+	// GetByMessageID 按 Message-ID 查询状态
+	GetByMessageID(ctx context.Context, messageID string) (*JobStatus, error)
+}
+
+// Queued 判断任务是否已经入队过，store 为 nil 时返回 false，查询失败时记录日志并按未入队处理
+func Queued(ctx context.Context, store Store, jobID string, log *logger.Logger) bool {
+	if store == nil {
+		return false
+	}
+	_, err := store.Get(ctx, jobID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Warn("Failed to look up job status", "job_id", jobID, "error", err)
+	}
+	return err == nil
+}
@@ -0,0 +1,86 @@
+package suppression
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore 基于 JSON 文件的名单存储，每次修改后整体写回文件
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex // 串行化写文件
+}
+
+// NewFileStore 创建文件名单存储，并加载已有记录
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("suppression: failed to create dir: %w", err)
+	}
+
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("suppression: failed to read %s: %w", path, err)
+	}
+	if len(data) > 0 {
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("suppression: invalid file %s: %w", path, err)
+		}
+		if err := s.MemoryStore.AddBatch(context.Background(), entries); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加记录
+func (s *FileStore) Add(ctx context.Context, entry Entry) error {
+	return s.AddBatch(ctx, []Entry{entry})
+}
+
+// AddBatch 批量添加记录
+func (s *FileStore) AddBatch(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.AddBatch(ctx, entries); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// Remove 删除记录
+func (s *FileStore) Remove(ctx context.Context, address, category string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.Remove(ctx, address, category); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// persist 将全部记录写回文件，调用方需持有 s.mu
+func (s *FileStore) persist() error {
+	s.MemoryStore.mu.RLock()
+	entries := s.MemoryStore.snapshot()
+	s.MemoryStore.mu.RUnlock()
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,100 @@
+package suppression
+
+import (
+	"context"
+	"sync"
+)
+
+type entryKey struct {
+	address  string
+	category string
+}
+
+// MemoryStore 内存名单存储
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[entryKey]Entry
+}
+
+// NewMemoryStore 创建内存名单存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[entryKey]Entry),
+	}
+}
+
+// Add 添加记录
+func (s *MemoryStore) Add(ctx context.Context, entry Entry) error {
+	return s.AddBatch(ctx, []Entry{entry})
+}
+
+// AddBatch 批量添加记录
+func (s *MemoryStore) AddBatch(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		entry = normalize(entry)
+		s.entries[entryKey{entry.Address, entry.Category}] = entry
+	}
+	return nil
+}
+
+// Check 检查地址在指定类别下是否被屏蔽
+func (s *MemoryStore) Check(ctx context.Context, address, category string) (*Entry, error) {
+	address = NormalizeAddress(address)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.entries[entryKey{address, ""}]; ok {
+		return &entry, nil
+	}
+	if category != "" {
+		if entry, ok := s.entries[entryKey{address, category}]; ok {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// List 列出记录
+func (s *MemoryStore) List(ctx context.Context, address string) ([]Entry, error) {
+	address = NormalizeAddress(address)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]Entry, 0)
+	for key, entry := range s.entries {
+		if address == "" || key.address == address {
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// Remove 删除记录
+func (s *MemoryStore) Remove(ctx context.Context, address, category string) error {
+	key := entryKey{NormalizeAddress(address), category}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, key)
+	return nil
+}
+
+// snapshot 返回全部记录，供文件存储持久化
+func (s *MemoryStore) snapshot() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries
+}
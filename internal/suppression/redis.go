@@ -0,0 +1,135 @@
+package suppression
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于 Redis 哈希的名单存储，字段为 "<地址>|<类别>"
+type RedisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore 创建 Redis 名单存储
+func NewRedisStore(config *RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	key := config.Key
+	if key == "" {
+		key = "email:suppression"
+	}
+	return &RedisStore{client: client, key: key}, nil
+}
+
+// Add 添加记录
+func (s *RedisStore) Add(ctx context.Context, entry Entry) error {
+	return s.AddBatch(ctx, []Entry{entry})
+}
+
+// AddBatch 批量添加记录
+func (s *RedisStore) AddBatch(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	values := make([]any, 0, len(entries)*2)
+	for _, entry := range entries {
+		entry = normalize(entry)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		values = append(values, field(entry.Address, entry.Category), data)
+	}
+	return s.client.HSet(ctx, s.key, values...).Err()
+}
+
+// Check 检查地址在指定类别下是否被屏蔽
+func (s *RedisStore) Check(ctx context.Context, address, category string) (*Entry, error) {
+	address = NormalizeAddress(address)
+
+	fields := []string{field(address, "")}
+	if category != "" {
+		fields = append(fields, field(address, category))
+	}
+	values, err := s.client.HMGet(ctx, s.key, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			var entry Entry
+			if err := json.Unmarshal([]byte(str), &entry); err != nil {
+				return nil, fmt.Errorf("suppression: invalid entry for %s: %w", address, err)
+			}
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// List 列出记录
+func (s *RedisStore) List(ctx context.Context, address string) ([]Entry, error) {
+	address = NormalizeAddress(address)
+
+	match := "*"
+	if address != "" {
+		match = escapePattern(address) + "|*"
+	}
+
+	entries := make([]Entry, 0)
+	iter := s.client.HScan(ctx, s.key, 0, match, 500).Iterator()
+	for iter.Next(ctx) {
+		// HSCAN 交替返回字段和值
+		if !iter.Next(ctx) {
+			break
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(iter.Val()), &entry); err != nil {
+			return nil, fmt.Errorf("suppression: invalid entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// Remove 删除记录
+func (s *RedisStore) Remove(ctx context.Context, address, category string) error {
+	n, err := s.client.HDel(ctx, s.key, field(NormalizeAddress(address), category)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func field(address, category string) string {
+	return address + "|" + category
+}
+
+// escapePattern 转义 Redis glob 模式中的特殊字符
+func escapePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
@@ -0,0 +1,155 @@
+// Package suppression 维护禁止发送的收件人名单
+package suppression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"email-service/internal/logger"
+)
+
+// ErrNotFound 名单记录不存在
+var ErrNotFound = errors.New("suppression entry not found")
+
+// Reason 加入名单的原因
+type Reason string
+
+const (
+	ReasonUnsubscribe Reason = "unsubscribe" // 收件人退订
+	ReasonHardBounce  Reason = "hard_bounce" // 硬退信（地址不存在等）
+	ReasonComplaint   Reason = "complaint"   // 收件人投诉为垃圾邮件
+	ReasonManual      Reason = "manual"      // 管理员手动添加
+)
+
+// ValidReason 判断原因是否合法
+func ValidReason(r Reason) bool {
+	switch r {
+	case ReasonUnsubscribe, ReasonHardBounce, ReasonComplaint, ReasonManual:
+		return true
+	}
+	return false
+}
+
+// Entry 名单记录
+type Entry struct {
+	Address   string    `json:"address"`
+	Category  string    `json:"category,omitempty"` // 为空表示屏蔽全部类别
+	Reason    Reason    `json:"reason"`
+	Detail    string    `json:"detail,omitempty"` // 补充信息，如退信原文
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store 定义名单存储接口
+type Store interface {
+	// Add 添加记录，相同地址和类别的记录会被覆盖
+	Add(ctx context.Context, entry Entry) error
+
+	// AddBatch 批量添加记录
+	AddBatch(ctx context.Context, entries []Entry) error
+
+	// Check 检查地址在指定类别下是否被屏蔽，未屏蔽时返回 nil
+	// 类别为空的记录对所有类别生效
+	Check(ctx context.Context, address, category string) (*Entry, error)
+
+	// List 列出地址对应的记录，地址为空时列出全部记录
+	List(ctx context.Context, address string) ([]Entry, error)
+
+	// Remove 删除地址在指定类别下的记录，不存在时返回 ErrNotFound
+	Remove(ctx context.Context, address, category string) error
+}
+
+// Config 名单存储配置
+type Config struct {
+	Type                    string       `mapstructure:"type"`                       // memory, redis 或 file
+	File                    string       `mapstructure:"file"`                       // file 类型的存储文件
+	Redis                   *RedisConfig `mapstructure:"redis"`                      // redis 类型的连接配置
+	AutoSuppressHardBounces bool         `mapstructure:"auto_suppress_hard_bounces"` // 发送时遇到硬退信自动加入名单
+}
+
+// RedisConfig Redis 名单存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Key      string `mapstructure:"key"` // 存放名单的哈希键
+}
+
+// 存储类型
+const (
+	TypeMemory = "memory"
+	TypeRedis  = "redis"
+	TypeFile   = "file"
+)
+
+// DefaultConfig 返回默认名单存储配置
+func DefaultConfig() *Config {
+	return &Config{
+		Type: TypeMemory,
+		File: "data/suppression.json",
+	}
+}
+
+// NewStore 根据配置创建名单存储
+func NewStore(cfg *Config) (Store, error) {
+	switch cfg.Type {
+	case TypeMemory, "":
+		return NewMemoryStore(), nil
+	case TypeFile:
+		return NewFileStore(cfg.File)
+	case TypeRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("suppression: redis config is required")
+		}
+		return NewRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("suppression: unsupported store type %q", cfg.Type)
+	}
+}
+
+// Suppressed 判断收件人在类别下是否被屏蔽，store 为 nil 时返回 false，查询失败时记录日志并按未屏蔽处理
+func Suppressed(ctx context.Context, store Store, email, category string, log *logger.Logger) bool {
+	if store == nil {
+		return false
+	}
+	entry, err := store.Check(ctx, email, category)
+	if err != nil {
+		log.Warn("Failed to check suppression list", "recipient", email, "error", err)
+		return false
+	}
+	if entry != nil {
+		log.Info("Skipping suppressed recipient", "recipient", email, "category", category, "reason", entry.Reason)
+		return true
+	}
+	return false
+}
+
+// NormalizeAddress 规范化邮箱地址
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// normalize 规范化记录并补全时间
+func normalize(entry Entry) Entry {
+	entry.Address = NormalizeAddress(entry.Address)
+	if entry.Reason == "" {
+		entry.Reason = ReasonManual
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return entry
+}
+
+// sortEntries 按地址和类别排序
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Address != entries[j].Address {
+			return entries[i].Address < entries[j].Address
+		}
+		return entries[i].Category < entries[j].Category
+	})
+}
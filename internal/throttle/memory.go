@@ -0,0 +1,149 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryHash struct {
+	jobID     string
+	expiresAt time.Time
+}
+
+type memoryEvent struct {
+	jobID string
+	at    time.Time
+}
+
+type memoryCounter struct {
+	events []memoryEvent // 按时间升序
+	window time.Duration
+}
+
+// MemoryStore 内存限流记录存储，只在单实例内有效
+type MemoryStore struct {
+	mu       sync.Mutex
+	hashes   map[string]memoryHash
+	counters map[string]*memoryCounter
+}
+
+// NewMemoryStore 创建内存限流记录存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hashes:   make(map[string]memoryHash),
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+// Remember 记录内容摘要
+func (s *MemoryStore) Remember(ctx context.Context, key, jobID string, window time.Duration) (string, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.hashes[key]; ok && now.Before(h.expiresAt) {
+		return h.jobID, nil
+	}
+	s.hashes[key] = memoryHash{jobID: jobID, expiresAt: now.Add(window)}
+	return jobID, nil
+}
+
+// Forget 删除内容摘要
+func (s *MemoryStore) Forget(ctx context.Context, key, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.hashes[key]; ok && h.jobID == jobID {
+		delete(s.hashes, key)
+	}
+	return nil
+}
+
+// Take 按滑动窗口计数
+func (s *MemoryStore) Take(ctx context.Context, key, jobID string, limit int, window time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &memoryCounter{window: window}
+		s.counters[key] = c
+	}
+	c.expire(now)
+	if len(c.events) >= limit {
+		return false, nil
+	}
+	c.events = append(c.events, memoryEvent{jobID: jobID, at: now})
+	return true, nil
+}
+
+// Return 撤销发送记录
+func (s *MemoryStore) Return(ctx context.Context, key, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		return nil
+	}
+	for i, ev := range c.events {
+		if ev.jobID == jobID {
+			c.events = append(c.events[:i], c.events[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Prune 删除过期的记录，返回删除的键数量
+func (s *MemoryStore) Prune() int {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, h := range s.hashes {
+		if !now.Before(h.expiresAt) {
+			delete(s.hashes, key)
+			removed++
+		}
+	}
+	for key, c := range s.counters {
+		c.expire(now)
+		if len(c.events) == 0 {
+			delete(s.counters, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunPrune 周期性清理过期记录，直到 ctx 结束
+func (s *MemoryStore) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
+
+// expire 删除窗口之外的发送记录
+func (c *memoryCounter) expire(now time.Time) {
+	cutoff := now.Add(-c.window)
+	i := 0
+	for i < len(c.events) && !c.events[i].at.After(cutoff) {
+		i++
+	}
+	c.events = c.events[i:]
+}
@@ -0,0 +1,99 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript 按滑动窗口计数：删除窗口之外的记录，未达到上限时写入本次发送
+// KEYS[1] 有序集合；ARGV: 当前毫秒时间、窗口毫秒数、上限、任务ID
+var takeScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// forgetScript 仅当内容摘要仍由该任务记录时删除
+var forgetScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore 基于 Redis 的限流记录存储，多个实例共享
+// 内容摘要保存为带过期时间的字符串，发送记录保存为以时间为分数的有序集合
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 限流记录存储
+func NewRedisStore(config *RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "email:throttle:"
+	}
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+// Remember 通过 SET NX 记录内容摘要
+func (s *RedisStore) Remember(ctx context.Context, key, jobID string, window time.Duration) (string, error) {
+	for i := 0; i < 2; i++ {
+		ok, err := s.client.SetNX(ctx, s.prefix+key, jobID, window).Result()
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return jobID, nil
+		}
+		first, err := s.client.Get(ctx, s.prefix+key).Result()
+		if errors.Is(err, redis.Nil) {
+			// 恰好过期，重新记录
+			continue
+		}
+		return first, err
+	}
+	return jobID, nil
+}
+
+// Forget 删除内容摘要
+func (s *RedisStore) Forget(ctx context.Context, key, jobID string) error {
+	return forgetScript.Run(ctx, s.client, []string{s.prefix + key}, jobID).Err()
+}
+
+// Take 按滑动窗口计数
+func (s *RedisStore) Take(ctx context.Context, key, jobID string, limit int, window time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	n, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		now, window.Milliseconds(), strconv.Itoa(limit), jobID).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Return 撤销发送记录
+func (s *RedisStore) Return(ctx context.Context, key, jobID string) error {
+	return s.client.ZRem(ctx, s.prefix+key, jobID).Err()
+}
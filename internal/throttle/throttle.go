@@ -0,0 +1,261 @@
+// Package throttle 按收件人限制邮件入队：相同内容在窗口内重复出现时丢弃或合并，
+// 并限制每个收件人在窗口内按类别接收的邮件数量
+package throttle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"email-service/internal/logger"
+	"email-service/pkg/jobqueue"
+)
+
+// 重复内容的处理方式
+const (
+	ActionDrop  = "drop"  // 丢弃重复的邮件
+	ActionMerge = "merge" // 并入窗口内首次入队的任务，响应中返回该任务ID
+)
+
+// 未入队的原因
+const (
+	ReasonDuplicate   = "duplicate"    // 窗口内已发送过相同内容
+	ReasonMerged      = "merged"       // 并入了窗口内相同内容的任务
+	ReasonRateLimited = "rate_limited" // 超过收件人的频率上限
+)
+
+// Config 限流规则配置
+type Config struct {
+	Duplicates *DuplicateRule `mapstructure:"duplicates"`
+	Caps       []CapRule      `mapstructure:"caps"`
+	Store      string         `mapstructure:"store"` // memory 或 redis
+	Redis      *RedisConfig   `mapstructure:"redis"`
+}
+
+// DuplicateRule 重复内容规则，收件人、模板、主题和模板数据都相同视为重复
+type DuplicateRule struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Window  time.Duration `mapstructure:"window"`
+	Action  string        `mapstructure:"action"` // drop 或 merge
+}
+
+// CapRule 频率上限规则
+type CapRule struct {
+	Category string        `mapstructure:"category"` // 为空时统计全部类别
+	Limit    int           `mapstructure:"limit"`
+	Window   time.Duration `mapstructure:"window"`
+}
+
+// RedisConfig Redis 存储配置
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"` // 键前缀
+}
+
+// 存储类型
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// DefaultConfig 返回默认限流配置，默认不启用任何规则
+func DefaultConfig() *Config {
+	return &Config{
+		Duplicates: &DuplicateRule{
+			Window: 10 * time.Minute,
+			Action: ActionDrop,
+		},
+		Store: StoreMemory,
+	}
+}
+
+// Store 定义限流记录存储接口
+type Store interface {
+	// Remember 在窗口内记录内容摘要对应的任务；摘要已存在时不做修改，返回首次记录的任务ID
+	Remember(ctx context.Context, key, jobID string, window time.Duration) (string, error)
+
+	// Forget 删除由 jobID 记录的内容摘要
+	Forget(ctx context.Context, key, jobID string) error
+
+	// Take 窗口内的发送次数未达到上限时记录一次发送并返回 true
+	Take(ctx context.Context, key, jobID string, limit int, window time.Duration) (bool, error)
+
+	// Return 撤销 jobID 的发送记录
+	Return(ctx context.Context, key, jobID string) error
+}
+
+// Decision 任务未被允许入队的原因
+type Decision struct {
+	Reason string // ReasonDuplicate、ReasonMerged 或 ReasonRateLimited
+	Detail string
+	JobID  string // 合并时为窗口内首次入队的任务ID
+}
+
+// Engine 按规则判断任务能否入队
+type Engine struct {
+	store  Store
+	config *Config
+	logger *logger.Logger
+}
+
+// New 根据配置创建限流引擎
+func New(cfg *Config) (*Engine, error) {
+	if cfg.Duplicates == nil {
+		cfg.Duplicates = DefaultConfig().Duplicates
+	}
+	dup := cfg.Duplicates
+	if dup.Action == "" {
+		dup.Action = ActionDrop
+	}
+	if dup.Action != ActionDrop && dup.Action != ActionMerge {
+		return nil, fmt.Errorf("throttle: unsupported duplicate action %q", dup.Action)
+	}
+	if dup.Enabled && dup.Window <= 0 {
+		return nil, errors.New("throttle: duplicate window must be positive")
+	}
+	for _, c := range cfg.Caps {
+		if c.Limit <= 0 || c.Window <= 0 {
+			return nil, fmt.Errorf("throttle: cap for category %q requires limit and window", c.Category)
+		}
+	}
+
+	var store Store
+	switch cfg.Store {
+	case StoreMemory, "":
+		store = NewMemoryStore()
+	case StoreRedis:
+		if cfg.Redis == nil {
+			return nil, errors.New("throttle: redis config is required")
+		}
+		var err error
+		if store, err = NewRedisStore(cfg.Redis); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("throttle: unsupported store type %q", cfg.Store)
+	}
+
+	return &Engine{
+		store:  store,
+		config: cfg,
+		logger: logger.GetDefault().WithComponent("throttle"),
+	}, nil
+}
+
+// Store 返回限流记录存储
+func (e *Engine) Store() Store {
+	return e.store
+}
+
+// Enabled 判断是否配置了任何规则
+func (e *Engine) Enabled() bool {
+	return e.config.Duplicates.Enabled || len(e.config.Caps) > 0
+}
+
+// Admit 判断任务能否入队；允许时记录该任务并返回 nil，任务最终未入队时需调用 Revoke
+func (e *Engine) Admit(ctx context.Context, job jobqueue.EmailJob) (*Decision, error) {
+	recipient := strings.ToLower(job.To)
+
+	var dupKey string
+	if dup := e.config.Duplicates; dup.Enabled {
+		hash, err := contentHash(job)
+		if err != nil {
+			return nil, err
+		}
+		dupKey = "dup:" + recipient + ":" + hash
+		first, err := e.store.Remember(ctx, dupKey, job.ID, dup.Window)
+		if err != nil {
+			return nil, err
+		}
+		if first != job.ID {
+			if dup.Action == ActionMerge {
+				return &Decision{Reason: ReasonMerged, JobID: first}, nil
+			}
+			return &Decision{Reason: ReasonDuplicate, Detail: fmt.Sprintf("same content sent within %s", dup.Window)}, nil
+		}
+	}
+
+	var taken []string
+	for _, c := range e.config.Caps {
+		if c.Category != "" && c.Category != job.Category {
+			continue
+		}
+		key := capKey(c, recipient)
+		ok, err := e.store.Take(ctx, key, job.ID, c.Limit, c.Window)
+		if err == nil && ok {
+			taken = append(taken, key)
+			continue
+		}
+		// 被拒绝或出错时撤销之前的记录
+		e.undo(ctx, job.ID, dupKey, taken)
+		if err != nil {
+			return nil, err
+		}
+		detail := fmt.Sprintf("at most %d emails per %s", c.Limit, c.Window)
+		if c.Category != "" {
+			detail += " in category " + c.Category
+		}
+		return &Decision{Reason: ReasonRateLimited, Detail: detail}, nil
+	}
+	return nil, nil
+}
+
+// Revoke 撤销 Admit 为任务写入的记录
+func (e *Engine) Revoke(ctx context.Context, job jobqueue.EmailJob) {
+	recipient := strings.ToLower(job.To)
+
+	var dupKey string
+	if e.config.Duplicates.Enabled {
+		if hash, err := contentHash(job); err == nil {
+			dupKey = "dup:" + recipient + ":" + hash
+		}
+	}
+	var taken []string
+	for _, c := range e.config.Caps {
+		if c.Category == "" || c.Category == job.Category {
+			taken = append(taken, capKey(c, recipient))
+		}
+	}
+	e.undo(ctx, job.ID, dupKey, taken)
+}
+
+// undo 删除任务的内容摘要和发送记录
+func (e *Engine) undo(ctx context.Context, jobID, dupKey string, capKeys []string) {
+	if dupKey != "" {
+		if err := e.store.Forget(ctx, dupKey, jobID); err != nil {
+			e.logger.Warn("Failed to forget content hash", "job_id", jobID, "error", err)
+		}
+	}
+	for _, key := range capKeys {
+		if err := e.store.Return(ctx, key, jobID); err != nil {
+			e.logger.Warn("Failed to return rate limit slot", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+// capKey 返回收件人在频率规则下的计数键
+func capKey(c CapRule, recipient string) string {
+	return "cap:" + c.Category + ":" + c.Window.String() + ":" + recipient
+}
+
+// contentHash 计算任务内容的摘要
+func contentHash(job jobqueue.EmailJob) (string, error) {
+	data, err := json.Marshal(struct {
+		TemplateID   string         `json:"template_id"`
+		TemplateData map[string]any `json:"template_data"`
+		Subject      string         `json:"subject"`
+		Body         string         `json:"body"`
+	}{job.TemplateID, job.TemplateData, job.Subject, job.Body})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
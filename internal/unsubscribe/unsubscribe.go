@@ -0,0 +1,102 @@
+// Package unsubscribe 生成 List-Unsubscribe 头部及签名退订令牌 (RFC 2369, RFC 8058)
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidToken 退订令牌无效
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Config 退订配置
+type Config struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	BaseURL          string   `mapstructure:"base_url"`          // 服务对外地址，如 https://mail.example.com
+	Secret           string   `mapstructure:"secret"`            // 令牌签名密钥
+	ExemptCategories []string `mapstructure:"exempt_categories"` // 不添加退订头部的类别（如验证码、密码重置）
+}
+
+// Service 生成和校验退订令牌
+type Service struct {
+	baseURL string
+	secret  []byte
+	exempt  map[string]bool
+}
+
+// New 根据配置创建退订服务
+func New(cfg *Config) (*Service, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("unsubscribe: secret is required")
+	}
+	if _, err := url.ParseRequestURI(cfg.BaseURL); err != nil {
+		return nil, errors.New("unsubscribe: valid base_url is required")
+	}
+
+	exempt := make(map[string]bool, len(cfg.ExemptCategories))
+	for _, c := range cfg.ExemptCategories {
+		exempt[c] = true
+	}
+	return &Service{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		secret:  []byte(cfg.Secret),
+		exempt:  exempt,
+	}, nil
+}
+
+// Applies 判断类别是否需要添加退订头部
+func (s *Service) Applies(category string) bool {
+	return category != "" && !s.exempt[category]
+}
+
+// Token 生成地址和类别对应的签名令牌
+func (s *Service) Token(address, category string) string {
+	payload := []byte(strings.ToLower(address) + "\x00" + category)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// Parse 校验令牌并返回地址和类别
+func (s *Service) Parse(token string) (address, category string, err error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return "", "", ErrInvalidToken
+	}
+
+	address, category, ok = strings.Cut(string(payload), "\x00")
+	if !ok || address == "" {
+		return "", "", ErrInvalidToken
+	}
+	return address, category, nil
+}
+
+// URL 返回退订链接
+func (s *Service) URL(address, category string) string {
+	return s.baseURL + "/v1/unsubscribe?token=" + url.QueryEscape(s.Token(address, category))
+}
+
+// Headers 返回需要添加到邮件中的退订头部
+func (s *Service) Headers(address, category string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + s.URL(address, category) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func (s *Service) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,67 @@
+// Package verp 生成和解析按任务区分的信封发件人地址 (VERP)
+package verp
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Config VERP 配置
+type Config struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Prefix  string `mapstructure:"prefix"` // 地址前缀，默认 bounces
+	Domain  string `mapstructure:"domain"` // 接收退信的域名，为空时使用发件地址的域名
+}
+
+// DefaultConfig 返回默认 VERP 配置
+func DefaultConfig() *Config {
+	return &Config{Prefix: "bounces"}
+}
+
+// Encoder 生成形如 bounces+<任务ID>@<域名> 的信封发件人，并从退信收件地址解析出任务ID
+// 每个任务只有一个收件人，任务ID即可确定收件人
+type Encoder struct {
+	prefix string
+	domain string
+}
+
+// New 创建 VERP 编码器
+func New(cfg *Config) (*Encoder, error) {
+	prefix := strings.TrimSpace(cfg.Prefix)
+	if prefix == "" {
+		prefix = "bounces"
+	}
+	if strings.ContainsAny(prefix, "+@ ") {
+		return nil, fmt.Errorf("verp: invalid prefix %q", prefix)
+	}
+	domain := strings.ToLower(strings.TrimSpace(cfg.Domain))
+	if domain == "" {
+		return nil, errors.New("verp: domain is required")
+	}
+	return &Encoder{prefix: prefix, domain: domain}, nil
+}
+
+// Address 返回任务的信封发件人
+func (e *Encoder) Address(jobID string) string {
+	return fmt.Sprintf("%s+%s@%s", e.prefix, jobID, e.domain)
+}
+
+// Decode 从退信的收件地址解析任务ID，地址不是本编码器生成时返回 false
+func (e *Encoder) Decode(address string) (string, bool) {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 || !strings.EqualFold(address[at+1:], e.domain) {
+		return "", false
+	}
+
+	local := address[:at]
+	tag := e.prefix + "+"
+	if len(local) <= len(tag) || !strings.EqualFold(local[:len(tag)], tag) {
+		return "", false
+	}
+	return strings.ToLower(local[len(tag):]), true
+}
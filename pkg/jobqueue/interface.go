@@ -2,29 +2,70 @@ package jobqueue
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 )
 
 // EmailJob 表示一个邮件发送任务
 type EmailJob struct {
+	ID           string         `json:"id"` // 任务ID
 	To           string         `json:"to"`
 	Subject      string         `json:"subject"`
+	Category     string         `json:"category,omitempty"` // 邮件类别，用于退订和屏蔽
+	Priority     string         `json:"priority,omitempty"` // 优先级：high、normal 或 bulk，为空时视为 normal
 	Body         string         `json:"body"`
-	RetryCount   int            `json:"retry_count"`   // 当前重试次数
-	MaxRetries   int            `json:"max_retries"`   // 最大重试次数
-	NextRetryAt  time.Time      `json:"next_retry_at"` // 下次重试时间
-	CreatedAt    time.Time      `json:"created_at"`    // 任务创建时间
-	LastError    string         `json:"last_error"`    // 最后一次错误信息
-	TemplateID   string         `json:"template_id"`   // 模板ID
-	TemplateData map[string]any `json:"template_data"` // 模板数据
-	Attachments  []Attachment   `json:"attachments"`   // 附件
+	RetryCount   int            `json:"retry_count"`           // 当前重试次数
+	MaxRetries   int            `json:"max_retries"`           // 最大重试次数
+	NextRetryAt  time.Time      `json:"next_retry_at"`         // 下次重试时间
+	CreatedAt    time.Time      `json:"created_at"`            // 任务创建时间
+	LastError    string         `json:"last_error"`            // 最后一次错误信息
+	TemplateID   string         `json:"template_id"`           // 模板ID
+	TemplateData map[string]any `json:"template_data"`         // 模板数据
+	Attachments  []Attachment   `json:"attachments"`           // 附件
+	Sign         string         `json:"sign,omitempty"`        // 签名方式: SecuritySMIME 或 SecurityPGP
+	Encrypt      string         `json:"encrypt,omitempty"`     // 加密方式: SecuritySMIME 或 SecurityPGP
+	InReplyTo    string         `json:"in_reply_to,omitempty"` // 回复的 Message-ID
+	References   []string       `json:"references,omitempty"`  // 会话中此前邮件的 Message-ID
+	Timezone     string         `json:"timezone,omitempty"`    // 收件人所在的 IANA 时区，用于计算投递时段
+	Campaign     string         `json:"campaign,omitempty"`    // 所属的批量发送活动ID
 }
 
+// NewJobID 生成随机任务ID
+func NewJobID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 任务优先级，队列按优先级分道存放，高优先级的任务优先被取出
+const (
+	PriorityHigh   = "high"   // 验证码、密码重置等事务性邮件
+	PriorityNormal = "normal" // 默认
+	PriorityBulk   = "bulk"   // 营销、通知等批量邮件
+)
+
+// ValidPriority 判断优先级是否合法，空字符串视为 normal
+func ValidPriority(priority string) bool {
+	switch priority {
+	case "", PriorityHigh, PriorityNormal, PriorityBulk:
+		return true
+	}
+	return false
+}
+
+// 邮件签名与加密方式
+const (
+	SecuritySMIME = "smime"
+	SecurityPGP   = "pgp"
+)
+
 // Attachment 附件
 type Attachment struct {
-	Filename string `json:"filename"` // 附件文件名
-	Content  string `json:"content"`  // Base64编码的文件内容
-	URL      string `json:"url"`      // 附件下载URL
+	ID       string `json:"id,omitempty"` // 通过 /v1/attachments 上传后得到的附件ID
+	Filename string `json:"filename"`     // 附件文件名
+	Content  string `json:"content"`      // Base64编码的文件内容
+	URL      string `json:"url"`          // 附件下载URL
 }
 
 // JobQueue 定义任务队列的接口
@@ -32,8 +73,8 @@ type JobQueue interface {
 	// Push 将任务推入队列
 	Push(ctx context.Context, job EmailJob) error
 
-	// Pop 从队列中弹出任务（阻塞式）
-	Pop(ctx context.Context) (EmailJob, error)
+	// Pop 从队列中取出任务（阻塞式），任务处理结束后必须调用返回值的 Ack 或 Nack
+	Pop(ctx context.Context) (Delivery, error)
 
 	// Close 关闭队列连接
 	Close() error
@@ -41,3 +82,34 @@ type JobQueue interface {
 	// Size 返回队列中待处理任务数量（可选实现，返回-1表示不支持）
 	Size() (int, error)
 }
+
+// Delivery 从队列中取出的任务
+// 支持确认的后端在 Ack 之前不会删除任务，进程中途退出时任务会被重新投递
+type Delivery interface {
+	// Job 返回任务内容
+	Job() EmailJob
+
+	// Ack 确认任务已处理完毕，将其从队列中删除
+	Ack(ctx context.Context) error
+
+	// Nack 放弃本次处理，任务在 requeueAfter 之后重新投递
+	Nack(ctx context.Context, requeueAfter time.Duration) error
+
+	// Extend 重置任务的可见性超时，处理耗时较长时定期调用，避免任务被其他消费者重新取走
+	Extend(ctx context.Context) error
+}
+
+// Deferrer 由按处理次数放弃任务的 Delivery 实现，用于区分推迟处理与处理失败
+type Deferrer interface {
+	// Defer 推迟任务，在 after 之后重新投递，不计入处理次数
+	Defer(ctx context.Context, after time.Duration) error
+}
+
+// Defer 推迟任务：未到重试时间、活动暂停或处于免打扰时段
+// Delivery 未实现 Deferrer 时等同于 Nack
+func Defer(ctx context.Context, d Delivery, after time.Duration) error {
+	if deferrer, ok := d.(Deferrer); ok {
+		return deferrer.Defer(ctx, after)
+	}
+	return d.Nack(ctx, after)
+}
@@ -0,0 +1,229 @@
+// Package outbox 实现事务性发件箱：业务系统在自己的数据库事务中写入邮件任务，
+// 与业务数据同时提交或回滚，邮件服务的中继再从发件箱表读取任务推入队列
+//
+// 使用方式：
+//
+//	box, _ := outbox.New(outbox.Postgres, "")
+//	// 在迁移中执行 box.Schema() 返回的建表语句
+//	tx, _ := db.BeginTx(ctx, nil)
+//	// ... 写入业务数据
+//	jobID, err := box.Enqueue(ctx, tx, jobqueue.EmailJob{To: "user@example.com", TemplateID: "welcome.html"})
+//	tx.Commit()
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-service/pkg/jobqueue"
+)
+
+// 支持的数据库
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// DefaultTable 默认的发件箱表名
+const DefaultTable = "email_outbox"
+
+// defaultMaxRetries 与 HTTP 接口创建的任务一致
+const defaultMaxRetries = 3
+
+var (
+	// ErrUnsupportedDialect 不支持的数据库
+	ErrUnsupportedDialect = errors.New("outbox: unsupported dialect")
+	// ErrInvalidJob 任务缺少收件人或字段取值不合法
+	ErrInvalidJob = errors.New("outbox: invalid job")
+)
+
+// Execer 执行 SQL 语句，*sql.Tx、*sql.DB 和 *sql.Conn 都满足该接口
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Querier 执行查询，中继认领任务时使用
+type Querier interface {
+	Execer
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Outbox 发件箱表
+type Outbox struct {
+	dialect string
+	table   string
+}
+
+// New 创建发件箱，table 为空时使用 DefaultTable
+func New(dialect, table string) (*Outbox, error) {
+	switch dialect {
+	case Postgres, MySQL, SQLite:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedDialect, dialect)
+	}
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Outbox{dialect: dialect, table: table}, nil
+}
+
+// Schema 返回创建发件箱表的语句，可重复执行
+func (o *Outbox) Schema() []string {
+	if o.dialect == MySQL {
+		return []string{`CREATE TABLE IF NOT EXISTS ` + o.table + ` (
+	job_id          VARCHAR(64) NOT NULL PRIMARY KEY,
+	payload         LONGTEXT NOT NULL,
+	created_at      BIGINT NOT NULL,
+	relayed_at      BIGINT NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	attempts        INT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	INDEX idx_` + o.table + `_pending (relayed_at, next_attempt_at, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`}
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + o.table + ` (
+	job_id          VARCHAR(64) PRIMARY KEY,
+	payload         TEXT NOT NULL,
+	created_at      BIGINT NOT NULL,
+	relayed_at      BIGINT NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_` + o.table + `_pending ON ` + o.table + ` (relayed_at, next_attempt_at, created_at)`,
+	}
+}
+
+// Enqueue 在调用方的事务中写入一个邮件任务，返回任务ID
+// 任务ID为空时自动生成；已存在相同任务ID的记录时忽略本次写入，业务事务重试不会产生重复邮件
+func (o *Outbox) Enqueue(ctx context.Context, tx Execer, job jobqueue.EmailJob) (string, error) {
+	if job.To == "" {
+		return "", fmt.Errorf("%w: recipient is required", ErrInvalidJob)
+	}
+	if !jobqueue.ValidPriority(job.Priority) {
+		return "", fmt.Errorf("%w: unsupported priority %q", ErrInvalidJob, job.Priority)
+	}
+	if job.ID == "" {
+		job.ID = jobqueue.NewJobID()
+	}
+	if len(job.ID) > 64 {
+		return "", fmt.Errorf("%w: job id longer than 64 characters", ErrInvalidJob)
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.NextRetryAt.IsZero() {
+		job.NextRetryAt = job.CreatedAt
+	}
+	if job.MaxRetries == 0 {
+		job.MaxRetries = defaultMaxRetries
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	insert := "INSERT INTO "
+	conflict := " ON CONFLICT (job_id) DO NOTHING"
+	if o.dialect == MySQL {
+		insert = "INSERT IGNORE INTO "
+		conflict = ""
+	}
+	query := o.rebind(insert + o.table + ` (job_id, payload, created_at) VALUES (?, ?, ?)` + conflict)
+	if _, err := tx.ExecContext(ctx, query, job.ID, string(data), now.UnixMilli()); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Record 发件箱中等待转发的任务
+type Record struct {
+	JobID    string
+	Payload  []byte
+	Attempts int // 此前转发失败的次数
+}
+
+// Job 解析任务内容
+func (r Record) Job() (jobqueue.EmailJob, error) {
+	var job jobqueue.EmailJob
+	err := json.Unmarshal(r.Payload, &job)
+	return job, err
+}
+
+// Claim 锁定最多 limit 条到期的待转发任务，需在事务中调用
+// PostgreSQL 和 MySQL 使用 FOR UPDATE SKIP LOCKED，多个中继不会取到同一条记录
+func (o *Outbox) Claim(ctx context.Context, tx Querier, limit int) ([]Record, error) {
+	query := `SELECT job_id, payload, attempts FROM ` + o.table + `
+WHERE relayed_at = 0 AND next_attempt_at <= ?
+ORDER BY created_at LIMIT ?`
+	if o.dialect != SQLite {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := tx.QueryContext(ctx, o.rebind(query), time.Now().UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.JobID, &r.Payload, &r.Attempts); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// MarkRelayed 标记任务已转发
+func (o *Outbox) MarkRelayed(ctx context.Context, tx Execer, jobID string) error {
+	query := o.rebind(`UPDATE ` + o.table + ` SET relayed_at = ?, last_error = NULL WHERE job_id = ?`)
+	_, err := tx.ExecContext(ctx, query, time.Now().UnixMilli(), jobID)
+	return err
+}
+
+// MarkFailed 记录转发失败，任务在 retryAt 之后重新转发
+func (o *Outbox) MarkFailed(ctx context.Context, tx Execer, jobID, reason string, retryAt time.Time) error {
+	query := o.rebind(`UPDATE ` + o.table + `
+SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE job_id = ?`)
+	_, err := tx.ExecContext(ctx, query, reason, retryAt.UnixMilli(), jobID)
+	return err
+}
+
+// Prune 删除 before 之前已转发的记录，返回删除数量
+func (o *Outbox) Prune(ctx context.Context, db Execer, before time.Time) (int64, error) {
+	query := o.rebind(`DELETE FROM ` + o.table + ` WHERE relayed_at > 0 AND relayed_at < ?`)
+	res, err := db.ExecContext(ctx, query, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// rebind 将 ? 占位符转换为 PostgreSQL 的 $n 格式
+func (o *Outbox) rebind(query string) string {
+	if o.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}